@@ -32,18 +32,93 @@ limitations under the License.
 package v1alpha1
 
 import (
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // A ResourceTemplate is a map with templates for individual resources.
 type ResourceTemplate struct {
 	ResourceTemplates map[string]string `json:"resourceTemplates,omitempty"`
+
+	// Options for individual templates. Keys are template names
+	// from resourceTemplates.
+	//
+	// +optional
+	TemplateOptions map[string]ResourceTemplateOptions `json:"templateOptions,omitempty"`
+}
+
+// Options for an individual resource template
+type ResourceTemplateOptions struct {
+	// Names of templates this template depends on. Dependencies
+	// are also found from references to '.Resources' in the
+	// template, i.e. this is only needed for references that
+	// cannot be found from the template, e.g. when using
+	// variables.
+	//
+	// +optional
+	DependsOn []string `json:"dependsOn,omitempty"`
+
+	// Apply wave of the template. Templates are applied in
+	// waves in increasing order and a wave is only applied when
+	// all resources from previous waves are ready. A template is
+	// never applied in an earlier wave than the templates it
+	// depends on.
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	Wave int32 `json:"wave,omitempty"`
+}
+
+// Templates for the status of the parent resource. Templates are
+// rendered after child resources have been applied, i.e. child
+// resources are available as '.Resources'.
+type StatusTemplates struct {
+	// Template rendering a Gateway status with 'addresses'.
+	//
+	// Deprecated: Use 'addresses'.
+	//
+	// +optional
+	Template string `json:"template,omitempty"`
+
+	// Template rendering a list of Gateway status addresses,
+	// i.e. a list of objects with 'type' and 'value'. Only used
+	// for Gateways.
+	//
+	// +optional
+	Addresses string `json:"addresses,omitempty"`
+
+	// Template rendering a list of conditions for the Gateway
+	// status, i.e. a list of objects with 'type', 'status',
+	// 'reason' and 'message'. Conditions set by the controller,
+	// e.g. 'Accepted' and 'Programmed', cannot be set. Only used
+	// for Gateways.
+	//
+	// +optional
+	Conditions string `json:"conditions,omitempty"`
+
+	// Template rendering a list of conditions for the route
+	// status of each parent Gateway of a route. Conditions set
+	// by the controller, e.g. 'Accepted', cannot be set. Only used
+	// for routes.
+	//
+	// +optional
+	ParentConditions string `json:"parentConditions,omitempty"`
 }
 
 // A ResourceStatusSpec defines how the parent resource status should be updated
-// FIXME: Improve schema
 type ResourceStatusSpec struct {
-	Status map[string]string `json:"status,omitempty"`
+	// Templates for the status of the parent resource
+	//
+	// +optional
+	Status StatusTemplates `json:"status,omitempty"`
+
+	// Template for Gateway listener status, rendered once per
+	// listener with the listener available as '.Listener'. The
+	// template may set 'Programmed' and 'ResolvedRefs' listener
+	// conditions. Only used for Gateways.
+	//
+	// +optional
+	ListenerStatus string `json:"listenerStatus,omitempty"`
 }
 
 // A ResourceSpec defines how a gateway API resource like `Gateway`
@@ -54,12 +129,50 @@ type ResourceSpec struct {
 	ResourceTemplate   `json:",inline"`
 }
 
+// PrunePolicy defines how child resources are handled when they are
+// no longer rendered from the templates of a GatewayClassBlueprint
+//
+// +kubebuilder:validation:Enum=Delete;Orphan;Keep
+type PrunePolicy string
+
+const (
+	// Child resources no longer rendered are deleted
+	PrunePolicyDelete PrunePolicy = "Delete"
+
+	// Child resources no longer rendered are left in place and
+	// released from the parent, i.e. owner reference and
+	// labels/annotations identifying the parent are removed
+	PrunePolicyOrphan PrunePolicy = "Orphan"
+
+	// Child resources no longer rendered are left in place and
+	// annotated as pruned. They are still owned by the parent and
+	// will be deleted together with the parent
+	PrunePolicyKeep PrunePolicy = "Keep"
+)
+
 type GatewayClassBlueprintSpec struct {
 	// Template for hardcoded values
 	//
 	// +optional
 	Values TemplateValues `json:"values,omitempty"`
 
+	// OpenAPI v3 schema of values, i.e. the values merged from
+	// the blueprint and policies must be valid according to the
+	// schema. Gateways and routes are not rendered when values
+	// are invalid. Violations are reported on Gateways and on the
+	// policies which supplied invalid values.
+	//
+	// +optional
+	ValuesSchema *apiextensionsv1.JSON `json:"valuesSchema,omitempty"`
+
+	// Policy for child resources that are no longer rendered by
+	// the templates, e.g. because a template was removed or
+	// because it renders fewer resources than previously.
+	//
+	// +optional
+	// +kubebuilder:default=Delete
+	PrunePolicy PrunePolicy `json:"prunePolicy,omitempty"`
+
 	// Template for child resources created from Gateways
 	//
 	// +optional
@@ -69,8 +182,67 @@ type GatewayClassBlueprintSpec struct {
 	//
 	// +optional
 	HTTPRouteTemplate ResourceSpec `json:"httpRouteTemplate,omitempty"`
+
+	// Template for child resources created from GRPCRoutes
+	//
+	// +optional
+	GRPCRouteTemplate ResourceSpec `json:"grpcRouteTemplate,omitempty"`
+
+	// Template for child resources created from TLSRoutes
+	//
+	// +optional
+	TLSRouteTemplate ResourceSpec `json:"tlsRouteTemplate,omitempty"`
+
+	// Template for child resources created from TCPRoutes
+	//
+	// +optional
+	TCPRouteTemplate ResourceSpec `json:"tcpRouteTemplate,omitempty"`
+
+	// Template for child resources created from UDPRoutes
+	//
+	// +optional
+	UDPRouteTemplate ResourceSpec `json:"udpRouteTemplate,omitempty"`
 }
 
+const (
+	// This condition indicates whether the blueprint is valid,
+	// i.e. whether all templates can be parsed and values are
+	// JSON objects.
+	//
+	// Possible reasons for this condition to be True are:
+	//
+	// * "Accepted"
+	//
+	// Possible reasons for this condition to be False are:
+	//
+	// * "InvalidTemplates"
+	// * "InvalidValues"
+	GatewayClassBlueprintConditionAccepted = "Accepted"
+
+	GatewayClassBlueprintReasonAccepted      = "Accepted"
+	GatewayClassBlueprintReasonInvalidValues = "InvalidValues"
+
+	// This condition indicates whether dependencies between
+	// resource templates could be resolved, i.e. whether the
+	// templates can be rendered in dependency order.
+	//
+	// Possible reasons for this condition to be True are:
+	//
+	// * "ResolvedDependencies"
+	//
+	// Possible reasons for this condition to be False are:
+	//
+	// * "DependencyCycle"
+	// * "InvalidDependencies"
+	// * "InvalidTemplates"
+	GatewayClassBlueprintConditionResolvedDependencies = "ResolvedDependencies"
+
+	GatewayClassBlueprintReasonResolvedDependencies = "ResolvedDependencies"
+	GatewayClassBlueprintReasonDependencyCycle      = "DependencyCycle"
+	GatewayClassBlueprintReasonInvalidDependencies  = "InvalidDependencies"
+	GatewayClassBlueprintReasonInvalidTemplates     = "InvalidTemplates"
+)
+
 type GatewayClassBlueprintStatus struct {
 	// Conditions is the current status from the controller for
 	// this GatewayClassParameter. Updates follow the same
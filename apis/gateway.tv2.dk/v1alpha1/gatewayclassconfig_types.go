@@ -39,15 +39,22 @@ import (
 type GatewayClassConfigSpec struct {
 	TemplateValues `json:",inline"`
 
-	TargetRef gatewayv1a2.NamespacedPolicyTargetReference `json:"targetRef"`
+	// Target of the policy. Either targetRef or targetSelector must be set
+	//
+	// +optional
+	TargetRef *gatewayv1a2.NamespacedPolicyTargetReference `json:"targetRef,omitempty"`
+
+	// Targets of the policy selected by labels. Policies in the
+	// controller namespace may select Namespaces and Gateways in
+	// all namespaces, other policies may select Gateways in their
+	// own namespace
+	//
+	// +optional
+	TargetSelector *PolicyTargetSelector `json:"targetSelector,omitempty"`
 }
 
 type GatewayClassConfigStatus struct {
-	// +optional
-	// +listType=map
-	// +listMapKey=type
-	// +kubebuilder:validation:MaxItems=8
-	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	PolicyStatus `json:",inline"`
 }
 
 //+kubebuilder:object:root=true
@@ -69,6 +76,26 @@ type GatewayClassConfigList struct {
 	Items           []GatewayClassConfig `json:"items"`
 }
 
+func (p *GatewayClassConfig) GetTemplateValues() *TemplateValues {
+	return &p.Spec.TemplateValues
+}
+
+// Target reference of the policy. Empty if the policy targets objects by labels
+func (p *GatewayClassConfig) GetTargetRef() *gatewayv1a2.NamespacedPolicyTargetReference {
+	if p.Spec.TargetRef == nil {
+		return &gatewayv1a2.NamespacedPolicyTargetReference{}
+	}
+	return p.Spec.TargetRef
+}
+
+func (p *GatewayClassConfig) GetTargetSelector() *PolicyTargetSelector {
+	return p.Spec.TargetSelector
+}
+
+func (p *GatewayClassConfig) GetPolicyStatus() *PolicyStatus {
+	return &p.Status.PolicyStatus
+}
+
 func init() {
 	SchemeBuilder.Register(&GatewayClassConfig{}, &GatewayClassConfigList{})
 }
@@ -33,21 +33,38 @@ package v1alpha1
 
 import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
 	gatewayv1a2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
 )
 
 type GatewayConfigSpec struct {
 	TemplateValues `json:",inline"`
 
-	TargetRef gatewayv1a2.NamespacedPolicyTargetReference `json:"targetRef"`
+	// Target of the policy. Either targetRef or targetSelector must be set
+	//
+	// +optional
+	TargetRef *GatewayConfigTargetReference `json:"targetRef,omitempty"`
+
+	// Gateways in the namespace of the policy selected by labels
+	//
+	// +optional
+	TargetSelector *PolicyTargetSelector `json:"targetSelector,omitempty"`
 }
 
-type GatewayConfigStatus struct {
+// Target of a GatewayConfig, i.e. a Namespace, a Gateway, a listener
+// of a Gateway or a route, e.g. an HTTPRoute
+type GatewayConfigTargetReference struct {
+	gatewayv1a2.NamespacedPolicyTargetReference `json:",inline"`
+
+	// Name of a listener of the targeted Gateway. Values only
+	// apply to routes attached to the listener
+	//
 	// +optional
-	// +listType=map
-	// +listMapKey=type
-	// +kubebuilder:validation:MaxItems=8
-	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	SectionName *gatewayapi.SectionName `json:"sectionName,omitempty"`
+}
+
+type GatewayConfigStatus struct {
+	PolicyStatus `json:",inline"`
 }
 
 //+kubebuilder:object:root=true
@@ -69,6 +86,26 @@ type GatewayConfigList struct {
 	Items           []GatewayConfig `json:"items"`
 }
 
+func (p *GatewayConfig) GetTemplateValues() *TemplateValues {
+	return &p.Spec.TemplateValues
+}
+
+// Target reference of the policy. Empty if the policy targets objects by labels
+func (p *GatewayConfig) GetTargetRef() *gatewayv1a2.NamespacedPolicyTargetReference {
+	if p.Spec.TargetRef == nil {
+		return &gatewayv1a2.NamespacedPolicyTargetReference{}
+	}
+	return &p.Spec.TargetRef.NamespacedPolicyTargetReference
+}
+
+func (p *GatewayConfig) GetTargetSelector() *PolicyTargetSelector {
+	return p.Spec.TargetSelector
+}
+
+func (p *GatewayConfig) GetPolicyStatus() *PolicyStatus {
+	return &p.Status.PolicyStatus
+}
+
 func init() {
 	SchemeBuilder.Register(&GatewayConfig{}, &GatewayConfigList{})
 }
@@ -33,6 +33,8 @@ package v1alpha1
 
 import (
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
 )
 
 // Template values - values that will be made available for templates defined in GatewayClassBlueprint.
@@ -52,4 +54,180 @@ type TemplateValues struct {
 	//
 	// +optional
 	Default *apiextensionsv1.JSON `json:"default,omitempty"`
+
+	// Strategies for merging values with values of lower
+	// precedence. Values without a strategy are replaced.
+	// Strategies of a GatewayClassBlueprint apply to values from
+	// all policies, while strategies of a policy apply to the
+	// values of the policy only
+	//
+	// +optional
+	// +kubebuilder:validation:MaxItems=32
+	MergeStrategies []ValuesMergeStrategy `json:"mergeStrategies,omitempty"`
+
+	// Values from ConfigMaps and Secrets in the namespace of the
+	// policy, or the controller namespace for
+	// GatewayClassBlueprints. Values from a source have the
+	// precedence of the default or override values of the policy
+	// and are merged after these in the order listed
+	//
+	// +optional
+	// +kubebuilder:validation:MaxItems=16
+	ValuesFrom []ValuesFromSource `json:"valuesFrom,omitempty"`
+}
+
+// ValuesFromKind is the kind of object supplying values
+//
+// +kubebuilder:validation:Enum=ConfigMap;Secret
+type ValuesFromKind string
+
+const (
+	ValuesFromConfigMap ValuesFromKind = "ConfigMap"
+	ValuesFromSecret    ValuesFromKind = "Secret"
+)
+
+// Reference to a ConfigMap or Secret supplying values
+type ValuesFromSource struct {
+	// Kind of the object, i.e. 'ConfigMap' or 'Secret'. Values
+	// from Secrets are sensitive and never included in debug output
+	Kind ValuesFromKind `json:"kind"`
+
+	// Name of the object
+	Name string `json:"name"`
+
+	// Key of the object data supplying a single value. When
+	// unset, all keys of the object supply values
+	//
+	// +optional
+	Key string `json:"key,omitempty"`
+
+	// Path of the values with keys separated by dots,
+	// e.g. 'tls.certificateArn'. Required when key is set. When
+	// unset, keys of the object are top-level values
+	//
+	// +optional
+	TargetPath string `json:"targetPath,omitempty"`
+
+	// Whether values are override values rather than default values
+	//
+	// +optional
+	Override bool `json:"override,omitempty"`
+
+	// Whether a missing object or key is ignored rather than an error
+	//
+	// +optional
+	Optional bool `json:"optional,omitempty"`
+}
+
+// MergeStrategyType defines how a value is merged with a value of lower precedence
+//
+// +kubebuilder:validation:Enum=Replace;Append;MergeByKey
+type MergeStrategyType string
+
+const (
+	// The value replaces the value of lower precedence
+	MergeStrategyReplace MergeStrategyType = "Replace"
+
+	// Items of the list are appended to the list of lower precedence
+	MergeStrategyAppend MergeStrategyType = "Append"
+
+	// Items of the list are merged with items of the list of
+	// lower precedence with the same key, and appended otherwise
+	MergeStrategyMergeByKey MergeStrategyType = "MergeByKey"
+)
+
+// Strategy for merging a value with a value of lower precedence
+type ValuesMergeStrategy struct {
+	// Path of the value with keys separated by dots, e.g. 'tags' or 'healthCheck.headers'
+	Path string `json:"path"`
+
+	// Strategy for merging the value
+	//
+	// +kubebuilder:default=Replace
+	// +optional
+	Strategy MergeStrategyType `json:"strategy,omitempty"`
+
+	// Key identifying list items for strategy 'MergeByKey', e.g. 'name'
+	//
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// Selector of policy targets by labels
+type PolicyTargetSelector struct {
+	// Group of the targets, i.e. 'gateway.networking.k8s.io' for
+	// Gateways and '' for Namespaces
+	//
+	// +optional
+	Group gatewayapi.Group `json:"group"`
+
+	// Kind of the targets, i.e. 'Gateway' or 'Namespace'
+	//
+	// +kubebuilder:validation:Enum=Gateway;Namespace
+	Kind gatewayapi.Kind `json:"kind"`
+
+	// Labels of the targets
+	Selector metav1.LabelSelector `json:"selector"`
+}
+
+// Reference to an object affected by a policy
+type PolicyAffectedObject struct {
+	// Group of the object, e.g. 'gateway.networking.k8s.io'
+	Group string `json:"group"`
+
+	// Kind of the object, e.g. 'Gateway' or 'HTTPRoute'
+	Kind string `json:"kind"`
+
+	// Namespace of the object
+	Namespace string `json:"namespace"`
+
+	// Name of the object
+	Name string `json:"name"`
+}
+
+const (
+	// This condition indicates whether values supplied by the
+	// policy are valid according to the values schema of the
+	// GatewayClassBlueprints of affected Gateways. Only set when
+	// a values schema is defined.
+	//
+	// Possible reasons for this condition to be True are:
+	//
+	// * "Valid"
+	//
+	// Possible reasons for this condition to be False are:
+	//
+	// * "InvalidValues"
+	PolicyConditionValuesValid = "ValuesValid"
+
+	PolicyReasonValuesValid   = "Valid"
+	PolicyReasonInvalidValues = "InvalidValues"
+)
+
+// Maximum number of affected objects listed in policy status
+const PolicyStatusMaxAffected = 64
+
+// Status of GatewayClassConfig and GatewayConfig policies. Follows
+// the policy status defined in
+// https://gateway-api.sigs.k8s.io/geps/gep-713/#policy-status
+type PolicyStatus struct {
+	// Conditions describe the current state of the policy. The
+	// 'Accepted' condition is 'False' with reason 'TargetNotFound'
+	// when the target does not exist, 'Invalid' when the policy is
+	// invalid and 'Conflicted' when the policy conflicts with an
+	// older policy with the same target.
+	//
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	// +kubebuilder:validation:MaxItems=8
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Gateways and routes currently affected by the policy. The
+	// list is truncated if the policy affects more objects than
+	// can be listed.
+	//
+	// +optional
+	// +kubebuilder:validation:MaxItems=64
+	Affected []PolicyAffectedObject `json:"affected,omitempty"`
 }
@@ -36,9 +36,11 @@ limitations under the License.
 package v1alpha1
 
 import (
-	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
-	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
+	apisv1 "sigs.k8s.io/gateway-api/apis/v1"
+	"sigs.k8s.io/gateway-api/apis/v1alpha2"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -104,8 +106,17 @@ func (in *GatewayClassBlueprintList) DeepCopyObject() runtime.Object {
 func (in *GatewayClassBlueprintSpec) DeepCopyInto(out *GatewayClassBlueprintSpec) {
 	*out = *in
 	in.Values.DeepCopyInto(&out.Values)
+	if in.ValuesSchema != nil {
+		in, out := &in.ValuesSchema, &out.ValuesSchema
+		*out = new(v1.JSON)
+		(*in).DeepCopyInto(*out)
+	}
 	in.GatewayTemplate.DeepCopyInto(&out.GatewayTemplate)
 	in.HTTPRouteTemplate.DeepCopyInto(&out.HTTPRouteTemplate)
+	in.GRPCRouteTemplate.DeepCopyInto(&out.GRPCRouteTemplate)
+	in.TLSRouteTemplate.DeepCopyInto(&out.TLSRouteTemplate)
+	in.TCPRouteTemplate.DeepCopyInto(&out.TCPRouteTemplate)
+	in.UDPRouteTemplate.DeepCopyInto(&out.UDPRouteTemplate)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayClassBlueprintSpec.
@@ -123,7 +134,7 @@ func (in *GatewayClassBlueprintStatus) DeepCopyInto(out *GatewayClassBlueprintSt
 	*out = *in
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
-		*out = make([]v1.Condition, len(*in))
+		*out = make([]metav1.Condition, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
@@ -203,7 +214,16 @@ func (in *GatewayClassConfigList) DeepCopyObject() runtime.Object {
 func (in *GatewayClassConfigSpec) DeepCopyInto(out *GatewayClassConfigSpec) {
 	*out = *in
 	in.TemplateValues.DeepCopyInto(&out.TemplateValues)
-	in.TargetRef.DeepCopyInto(&out.TargetRef)
+	if in.TargetRef != nil {
+		in, out := &in.TargetRef, &out.TargetRef
+		*out = new(v1alpha2.NamespacedPolicyTargetReference)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TargetSelector != nil {
+		in, out := &in.TargetSelector, &out.TargetSelector
+		*out = new(PolicyTargetSelector)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayClassConfigSpec.
@@ -219,13 +239,7 @@ func (in *GatewayClassConfigSpec) DeepCopy() *GatewayClassConfigSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GatewayClassConfigStatus) DeepCopyInto(out *GatewayClassConfigStatus) {
 	*out = *in
-	if in.Conditions != nil {
-		in, out := &in.Conditions, &out.Conditions
-		*out = make([]v1.Condition, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
+	in.PolicyStatus.DeepCopyInto(&out.PolicyStatus)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayClassConfigStatus.
@@ -301,7 +315,16 @@ func (in *GatewayConfigList) DeepCopyObject() runtime.Object {
 func (in *GatewayConfigSpec) DeepCopyInto(out *GatewayConfigSpec) {
 	*out = *in
 	in.TemplateValues.DeepCopyInto(&out.TemplateValues)
-	in.TargetRef.DeepCopyInto(&out.TargetRef)
+	if in.TargetRef != nil {
+		in, out := &in.TargetRef, &out.TargetRef
+		*out = new(GatewayConfigTargetReference)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TargetSelector != nil {
+		in, out := &in.TargetSelector, &out.TargetSelector
+		*out = new(PolicyTargetSelector)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayConfigSpec.
@@ -316,22 +339,95 @@ func (in *GatewayConfigSpec) DeepCopy() *GatewayConfigSpec {
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GatewayConfigStatus) DeepCopyInto(out *GatewayConfigStatus) {
+	*out = *in
+	in.PolicyStatus.DeepCopyInto(&out.PolicyStatus)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayConfigStatus.
+func (in *GatewayConfigStatus) DeepCopy() *GatewayConfigStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GatewayConfigStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GatewayConfigTargetReference) DeepCopyInto(out *GatewayConfigTargetReference) {
+	*out = *in
+	in.NamespacedPolicyTargetReference.DeepCopyInto(&out.NamespacedPolicyTargetReference)
+	if in.SectionName != nil {
+		in, out := &in.SectionName, &out.SectionName
+		*out = new(apisv1.SectionName)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayConfigTargetReference.
+func (in *GatewayConfigTargetReference) DeepCopy() *GatewayConfigTargetReference {
+	if in == nil {
+		return nil
+	}
+	out := new(GatewayConfigTargetReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PolicyAffectedObject) DeepCopyInto(out *PolicyAffectedObject) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicyAffectedObject.
+func (in *PolicyAffectedObject) DeepCopy() *PolicyAffectedObject {
+	if in == nil {
+		return nil
+	}
+	out := new(PolicyAffectedObject)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PolicyStatus) DeepCopyInto(out *PolicyStatus) {
 	*out = *in
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
-		*out = make([]v1.Condition, len(*in))
+		*out = make([]metav1.Condition, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Affected != nil {
+		in, out := &in.Affected, &out.Affected
+		*out = make([]PolicyAffectedObject, len(*in))
+		copy(*out, *in)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayConfigStatus.
-func (in *GatewayConfigStatus) DeepCopy() *GatewayConfigStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicyStatus.
+func (in *PolicyStatus) DeepCopy() *PolicyStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(GatewayConfigStatus)
+	out := new(PolicyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PolicyTargetSelector) DeepCopyInto(out *PolicyTargetSelector) {
+	*out = *in
+	in.Selector.DeepCopyInto(&out.Selector)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicyTargetSelector.
+func (in *PolicyTargetSelector) DeepCopy() *PolicyTargetSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(PolicyTargetSelector)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -339,7 +435,7 @@ func (in *GatewayConfigStatus) DeepCopy() *GatewayConfigStatus {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ResourceSpec) DeepCopyInto(out *ResourceSpec) {
 	*out = *in
-	in.ResourceStatusSpec.DeepCopyInto(&out.ResourceStatusSpec)
+	out.ResourceStatusSpec = in.ResourceStatusSpec
 	in.ResourceTemplate.DeepCopyInto(&out.ResourceTemplate)
 }
 
@@ -356,13 +452,7 @@ func (in *ResourceSpec) DeepCopy() *ResourceSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ResourceStatusSpec) DeepCopyInto(out *ResourceStatusSpec) {
 	*out = *in
-	if in.Status != nil {
-		in, out := &in.Status, &out.Status
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
-	}
+	out.Status = in.Status
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceStatusSpec.
@@ -385,6 +475,13 @@ func (in *ResourceTemplate) DeepCopyInto(out *ResourceTemplate) {
 			(*out)[key] = val
 		}
 	}
+	if in.TemplateOptions != nil {
+		in, out := &in.TemplateOptions, &out.TemplateOptions
+		*out = make(map[string]ResourceTemplateOptions, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceTemplate.
@@ -397,19 +494,64 @@ func (in *ResourceTemplate) DeepCopy() *ResourceTemplate {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceTemplateOptions) DeepCopyInto(out *ResourceTemplateOptions) {
+	*out = *in
+	if in.DependsOn != nil {
+		in, out := &in.DependsOn, &out.DependsOn
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceTemplateOptions.
+func (in *ResourceTemplateOptions) DeepCopy() *ResourceTemplateOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceTemplateOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StatusTemplates) DeepCopyInto(out *StatusTemplates) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StatusTemplates.
+func (in *StatusTemplates) DeepCopy() *StatusTemplates {
+	if in == nil {
+		return nil
+	}
+	out := new(StatusTemplates)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TemplateValues) DeepCopyInto(out *TemplateValues) {
 	*out = *in
 	if in.Override != nil {
 		in, out := &in.Override, &out.Override
-		*out = new(apiextensionsv1.JSON)
+		*out = new(v1.JSON)
 		(*in).DeepCopyInto(*out)
 	}
 	if in.Default != nil {
 		in, out := &in.Default, &out.Default
-		*out = new(apiextensionsv1.JSON)
+		*out = new(v1.JSON)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.MergeStrategies != nil {
+		in, out := &in.MergeStrategies, &out.MergeStrategies
+		*out = make([]ValuesMergeStrategy, len(*in))
+		copy(*out, *in)
+	}
+	if in.ValuesFrom != nil {
+		in, out := &in.ValuesFrom, &out.ValuesFrom
+		*out = make([]ValuesFromSource, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TemplateValues.
@@ -421,3 +563,33 @@ func (in *TemplateValues) DeepCopy() *TemplateValues {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValuesFromSource) DeepCopyInto(out *ValuesFromSource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValuesFromSource.
+func (in *ValuesFromSource) DeepCopy() *ValuesFromSource {
+	if in == nil {
+		return nil
+	}
+	out := new(ValuesFromSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValuesMergeStrategy) DeepCopyInto(out *ValuesMergeStrategy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValuesMergeStrategy.
+func (in *ValuesMergeStrategy) DeepCopy() *ValuesMergeStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(ValuesMergeStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
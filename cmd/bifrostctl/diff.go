@@ -0,0 +1,121 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+	"github.com/tv2-oss/bifrost-gateway-controller/controllers"
+	"github.com/tv2-oss/bifrost-gateway-controller/pkg/manifest"
+)
+
+// Show the changes to child resources in a cluster from applying
+// modified GatewayClassBlueprints from manifest files
+func runDiff(args []string, out io.Writer) error {
+	var files stringsFlag
+	var kubeconfig, kubecontext string
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fs.Var(&files, "f", "Manifest file with modified blueprints. Other resources are ignored. May be repeated, '-' reads from stdin")
+	fs.StringVar(&kubeconfig, "kubeconfig", "", "Path to kubeconfig file. Defaults to $KUBECONFIG or ~/.kube/config")
+	fs.StringVar(&kubecontext, "context", "", "The kubeconfig context to use")
+	fs.StringVar(&controllers.ControllerNamespace, "controller-namespace", "bifrost-gateway-controller-system", "The namespace of global policies")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	controllers.RenderDebugOutput = io.Discard
+
+	objs, err := manifest.ReadFiles(files...)
+	if err != nil {
+		return err
+	}
+	blueprints := []*gwcapi.GatewayClassBlueprint{}
+	for _, obj := range objs {
+		gvk := obj.GroupVersionKind()
+		if gvk.Group != gwcapi.GroupVersion.Group || gvk.Kind != "GatewayClassBlueprint" {
+			continue
+		}
+		gwcb := &gwcapi.GatewayClassBlueprint{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, gwcb); err != nil {
+			return fmt.Errorf("cannot decode GatewayClassBlueprint %q: %w", obj.GetName(), err)
+		}
+		blueprints = append(blueprints, gwcb)
+	}
+	if len(blueprints) == 0 {
+		return errors.New("no GatewayClassBlueprints found")
+	}
+
+	cfg, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig, Precedence: clientcmd.NewDefaultClientConfigLoadingRules().Precedence},
+		&clientcmd.ConfigOverrides{CurrentContext: kubecontext}).ClientConfig()
+	if err != nil {
+		return fmt.Errorf("cannot load kubeconfig: %w", err)
+	}
+	c, err := client.New(cfg, client.Options{Scheme: controllers.NewRendererScheme()})
+	if err != nil {
+		return err
+	}
+	dynClient, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Changes are written also if some templates could not be rendered
+	var errs []error
+	changed := false
+	for _, gwcb := range blueprints {
+		changes, err := controllers.NewBlueprintDiffer(c, dynClient, gwcb).Diff(context.Background())
+		errs = append(errs, err)
+		for _, change := range changes {
+			changed = true
+			if change.Template == "" {
+				fmt.Fprintf(out, "# %s %s (%s)\n", change.Action, change.Resource, change.Parent)
+			} else {
+				fmt.Fprintf(out, "# %s %s (template %s, %s)\n", change.Action, change.Resource, change.Template, change.Parent)
+			}
+			fmt.Fprint(out, change.Diff)
+		}
+	}
+	if !changed {
+		fmt.Fprintln(out, "No changes")
+	}
+	return errors.Join(errs...)
+}
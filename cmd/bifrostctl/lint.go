@@ -0,0 +1,86 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"k8s.io/apimachinery/pkg/runtime"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+	"github.com/tv2-oss/bifrost-gateway-controller/controllers"
+	"github.com/tv2-oss/bifrost-gateway-controller/pkg/manifest"
+)
+
+// Check GatewayClassBlueprints from manifest files for problems and
+// write findings to out. Returns an error if problems were found
+func runLint(args []string, out io.Writer) error {
+	var files, clusterScoped stringsFlag
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	fs.Var(&files, "f", "Manifest file with blueprints. Other resources are ignored. May be repeated, '-' reads from stdin")
+	fs.Var(&clusterScoped, "cluster-scoped", "Kind of cluster scoped child resources as 'Kind.group', in addition to built-in kinds. May be repeated")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	objs, err := manifest.ReadFiles(files...)
+	if err != nil {
+		return err
+	}
+
+	blueprints, problems := 0, 0
+	for _, obj := range objs {
+		gvk := obj.GroupVersionKind()
+		if gvk.Group != gwcapi.GroupVersion.Group || gvk.Kind != "GatewayClassBlueprint" {
+			continue
+		}
+		var gwcb gwcapi.GatewayClassBlueprint
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &gwcb); err != nil {
+			return fmt.Errorf("cannot decode GatewayClassBlueprint %q: %w", obj.GetName(), err)
+		}
+		blueprints++
+		for _, finding := range controllers.LintBlueprint(&gwcb, parseGroupKinds(clusterScoped)) {
+			fmt.Fprintf(out, "%s: %s\n", gwcb.Name, finding)
+			problems++
+		}
+	}
+	if blueprints == 0 {
+		return errors.New("no GatewayClassBlueprints found")
+	}
+	if problems > 0 {
+		return fmt.Errorf("found %d problems in %d blueprints", problems, blueprints)
+	}
+	return nil
+}
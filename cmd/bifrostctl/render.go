@@ -0,0 +1,121 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/tv2-oss/bifrost-gateway-controller/controllers"
+	"github.com/tv2-oss/bifrost-gateway-controller/pkg/manifest"
+)
+
+// Render templates of a GatewayClassBlueprint for a Gateway or route
+// from manifest files and write the rendered resources to out
+func runRender(args []string, out io.Writer) error {
+	var files, currentFiles, clusterScoped stringsFlag
+	var gateway, route string
+	var debug bool
+	fs := flag.NewFlagSet("render", flag.ContinueOnError)
+	fs.Var(&files, "f", "Manifest file with blueprints, GatewayClasses, Gateways, routes, policies, ConfigMaps and Secrets. May be repeated, '-' reads from stdin")
+	fs.Var(&currentFiles, "current", "Manifest file with current child resources, e.g. for templates using '.Resources'. May be repeated")
+	fs.Var(&clusterScoped, "cluster-scoped", "Kind of cluster scoped child resources as 'Kind.group', in addition to built-in kinds. May be repeated")
+	fs.StringVar(&gateway, "gateway", "", "Gateway to render as 'namespace/name'")
+	fs.StringVar(&route, "route", "", "Route to render as 'Kind/namespace/name', e.g. 'HTTPRoute/default/foo'")
+	fs.StringVar(&controllers.ControllerNamespace, "controller-namespace", "bifrost-gateway-controller-system", "The namespace of global policies")
+	fs.BoolVar(&debug, "debug", false, "Write rendered templates and template values to stderr")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if (gateway == "") == (route == "") {
+		return errors.New("exactly one of --gateway and --route must be given")
+	}
+
+	controllers.RenderDebugOutput = io.Discard
+	if debug {
+		controllers.RenderDebugOutput = os.Stderr
+	}
+
+	objs, err := manifest.ReadFiles(files...)
+	if err != nil {
+		return err
+	}
+	current, err := manifest.ReadFiles(currentFiles...)
+	if err != nil {
+		return err
+	}
+	renderer, err := controllers.NewOfflineRenderer(objs, current, parseGroupKinds(clusterScoped))
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	var results []*controllers.OfflineRenderResult
+	if gateway != "" {
+		parts := strings.Split(gateway, "/")
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid gateway %q, expected 'namespace/name'", gateway)
+		}
+		result, err := renderer.RenderGateway(ctx, parts[0], parts[1])
+		if err != nil {
+			return err
+		}
+		results = append(results, result)
+	} else {
+		parts := strings.Split(route, "/")
+		if len(parts) != 3 {
+			return fmt.Errorf("invalid route %q, expected 'Kind/namespace/name'", route)
+		}
+		// Resources are written also if some parents could not be rendered
+		results, err = renderer.RenderRoute(ctx, parts[0], parts[1], parts[2])
+	}
+
+	// Resources are written also if some templates could not be rendered
+	errs := []error{err}
+	for _, result := range results {
+		for _, tmpl := range result.Templates {
+			for _, res := range tmpl.Resources {
+				comment := fmt.Sprintf("Source: %s (gateway %s)", tmpl.TemplateName, result.Gateway)
+				if err := manifest.Write(out, comment, res); err != nil {
+					return err
+				}
+			}
+		}
+		errs = append(errs, result.TemplateErrors)
+	}
+	return errors.Join(errs...)
+}
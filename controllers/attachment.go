@@ -0,0 +1,308 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+)
+
+// Attachment of routes to Gateway listeners. A route attaches to a
+// listener through a parentRef when the parentRef sectionName and
+// port match the listener, the listener allows the route kind and
+// namespace and the route and listener hostnames intersect. See
+// https://gateway-api.sigs.k8s.io/concepts/api-overview/#attaching-routes-to-gateways
+
+// Result of attaching a route to a Gateway through a single parentRef
+type routeAttachment struct {
+	// Listeners the route attaches to
+	Listeners []gatewayapi.SectionName
+
+	// Hostnames of the route restricted to the hostnames of the
+	// listeners the route attaches to. Empty for route kinds without
+	// hostnames
+	Hostnames []gatewayapi.Hostname
+
+	// Reason and message when the route does not attach to any listener
+	Reason  gatewayapi.RouteConditionReason
+	Message string
+}
+
+func (a *routeAttachment) Accepted() bool {
+	return len(a.Listeners) > 0
+}
+
+// Result of attaching all routes to a Gateway
+type gatewayAttachment struct {
+	// Number of routes attached to each listener
+	AttachedRoutes map[gatewayapi.SectionName]int32
+
+	// Routes attached to at least one listener
+	Routes []client.Object
+
+	// Hostnames of attached routes, see routeAttachment
+	Hostnames []gatewayapi.Hostname
+}
+
+// Attaches routes to the listeners of a Gateway
+type gatewayAttacher struct {
+	gw *gatewayapi.Gateway
+
+	// Route kinds supported by each listener
+	kinds map[gatewayapi.SectionName][]gatewayapi.RouteGroupKind
+
+	// Namespaces by name, only looked up when a listener selects namespaces by labels
+	namespaces map[string]*corev1.Namespace
+}
+
+func newGatewayAttacher(ctx context.Context, r ControllerClient, gw *gatewayapi.Gateway,
+	gwcb *gwcapi.GatewayClassBlueprint, rtTypes []*routeType) (*gatewayAttacher, error) {
+	a := &gatewayAttacher{
+		gw:         gw,
+		kinds:      map[gatewayapi.SectionName][]gatewayapi.RouteGroupKind{},
+		namespaces: map[string]*corev1.Namespace{},
+	}
+	for idx := range gw.Spec.Listeners {
+		l := &gw.Spec.Listeners[idx]
+		a.kinds[l.Name] = supportedKinds(l, gwcb, rtTypes)
+	}
+	if hasNamespaceSelector(gw) {
+		var nsList corev1.NamespaceList
+		if err := r.Client().List(ctx, &nsList); err != nil {
+			return nil, fmt.Errorf("cannot list namespaces: %w", err)
+		}
+		for idx := range nsList.Items {
+			a.namespaces[nsList.Items[idx].Name] = &nsList.Items[idx]
+		}
+	}
+	return a, nil
+}
+
+// Whether a listener of the Gateway allows routes from namespaces selected by labels
+func hasNamespaceSelector(gw *gatewayapi.Gateway) bool {
+	for idx := range gw.Spec.Listeners {
+		if namespacesFrom(&gw.Spec.Listeners[idx]) == gatewayapi.NamespacesFromSelector {
+			return true
+		}
+	}
+	return false
+}
+
+func namespacesFrom(l *gatewayapi.Listener) gatewayapi.FromNamespaces {
+	if l.AllowedRoutes == nil || l.AllowedRoutes.Namespaces == nil || l.AllowedRoutes.Namespaces.From == nil {
+		return gatewayapi.NamespacesFromSame
+	}
+	return *l.AllowedRoutes.Namespaces.From
+}
+
+// Whether a parentRef of a route refers to the Gateway
+func parentRefIsGateway(rt client.Object, pRef *gatewayapi.ParentReference, gw *gatewayapi.Gateway) bool {
+	namespace := rt.GetNamespace() // Unspecified namespace means use route namespace
+	if pRef.Namespace != nil {
+		namespace = string(*pRef.Namespace)
+	}
+	return (pRef.Group == nil || *pRef.Group == gatewayapi.Group(gatewayapi.GroupName)) &&
+		(pRef.Kind == nil || *pRef.Kind == gatewayapi.Kind("Gateway")) &&
+		namespace == gw.Namespace && string(pRef.Name) == gw.Name
+}
+
+// Attach a route to the listeners matching a parentRef. The parentRef must refer to the Gateway
+func (a *gatewayAttacher) attachRoute(rt client.Object, pRef *gatewayapi.ParentReference) routeAttachment {
+	att := routeAttachment{}
+	rtType := routeTypeOf(rt)
+	if rtType == nil {
+		att.Reason = gatewayapi.RouteReasonNotAllowedByListeners
+		att.Message = "unsupported route kind"
+		return att
+	}
+	rtHostnames := routeHostnames(rt)
+
+	parentMatched := false
+	hostnameMismatch := false
+	for idx := range a.gw.Spec.Listeners {
+		l := &a.gw.Spec.Listeners[idx]
+		if (pRef.SectionName != nil && *pRef.SectionName != l.Name) ||
+			(pRef.Port != nil && *pRef.Port != l.Port) {
+			continue
+		}
+		parentMatched = true
+		if !kindAllowed(a.kinds[l.Name], rtType) || !a.namespaceAllowed(l, rt.GetNamespace()) {
+			continue
+		}
+		hostnames, ok := intersectHostnames(l.Hostname, rtHostnames)
+		if !ok {
+			hostnameMismatch = true
+			continue
+		}
+		att.Listeners = append(att.Listeners, l.Name)
+		if rtType.HasHostnames {
+			att.Hostnames = appendHostnames(att.Hostnames, hostnames...)
+		}
+	}
+
+	switch {
+	case att.Accepted():
+	case !parentMatched:
+		att.Reason = gatewayapi.RouteReasonNoMatchingParent
+		att.Message = "no listener matches parentRef sectionName and port"
+	case hostnameMismatch:
+		att.Reason = gatewayapi.RouteReasonNoMatchingListenerHostname
+		att.Message = "no listener hostname matches route hostnames"
+	default:
+		att.Reason = gatewayapi.RouteReasonNotAllowedByListeners
+		att.Message = fmt.Sprintf("route kind %s or namespace %q not allowed by any listener", rtType.Kind, rt.GetNamespace())
+	}
+	return att
+}
+
+// Attach routes referring to the Gateway to its listeners
+func (a *gatewayAttacher) attachRoutes(routes []client.Object) *gatewayAttachment {
+	gwAtt := &gatewayAttachment{
+		AttachedRoutes: map[gatewayapi.SectionName]int32{},
+		Routes:         []client.Object{},
+	}
+	for _, rt := range routes {
+		// A route is counted once per listener, even if attached through multiple parentRefs
+		listeners := map[gatewayapi.SectionName]bool{}
+		for idx := range routeCommonSpec(rt).ParentRefs {
+			pRef := &routeCommonSpec(rt).ParentRefs[idx]
+			if !parentRefIsGateway(rt, pRef, a.gw) {
+				continue
+			}
+			att := a.attachRoute(rt, pRef)
+			for _, name := range att.Listeners {
+				listeners[name] = true
+			}
+			gwAtt.Hostnames = appendHostnames(gwAtt.Hostnames, att.Hostnames...)
+		}
+		for name := range listeners {
+			gwAtt.AttachedRoutes[name]++
+		}
+		if len(listeners) > 0 {
+			gwAtt.Routes = append(gwAtt.Routes, rt)
+		}
+	}
+	return gwAtt
+}
+
+func kindAllowed(kinds []gatewayapi.RouteGroupKind, rtType *routeType) bool {
+	for _, k := range kinds {
+		if k.Group != nil && string(*k.Group) == rtType.GroupVersion.Group && string(k.Kind) == rtType.Kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *gatewayAttacher) namespaceAllowed(l *gatewayapi.Listener, namespace string) bool {
+	switch namespacesFrom(l) {
+	case gatewayapi.NamespacesFromAll:
+		return true
+	case gatewayapi.NamespacesFromSame:
+		return namespace == a.gw.Namespace
+	case gatewayapi.NamespacesFromSelector:
+		ns, found := a.namespaces[namespace]
+		if !found {
+			return false
+		}
+		selector, err := metav1.LabelSelectorAsSelector(l.AllowedRoutes.Namespaces.Selector)
+		if err != nil {
+			return false
+		}
+		return selector.Matches(labels.Set(ns.Labels))
+	}
+	return false
+}
+
+// Intersect listener hostname with route hostnames. Returns the
+// hostnames matching both and whether the hostnames intersect. A
+// missing listener hostname or route hostnames match any hostname.
+func intersectHostnames(listenerHostname *gatewayapi.Hostname, rtHostnames []gatewayapi.Hostname) ([]gatewayapi.Hostname, bool) {
+	if listenerHostname == nil {
+		return rtHostnames, true
+	}
+	if len(rtHostnames) == 0 {
+		return []gatewayapi.Hostname{*listenerHostname}, true
+	}
+	hostnames := []gatewayapi.Hostname{}
+	for _, rtHostname := range rtHostnames {
+		if hostname, ok := intersectHostname(*listenerHostname, rtHostname); ok {
+			hostnames = append(hostnames, hostname)
+		}
+	}
+	return hostnames, len(hostnames) > 0
+}
+
+// Intersect two hostnames, either of which may be a wildcard. Returns the most specific hostname
+func intersectHostname(a, b gatewayapi.Hostname) (gatewayapi.Hostname, bool) {
+	if hostnameMatches(a, b) {
+		return b, true
+	}
+	if hostnameMatches(b, a) {
+		return a, true
+	}
+	return "", false
+}
+
+// Whether a hostname is matched by a pattern. A wildcard pattern,
+// e.g. '*.example.com', matches hostnames with one or more
+// additional labels, e.g. 'foo.example.com' and '*.foo.example.com'
+// but not 'example.com'
+func hostnameMatches(pattern, hostname gatewayapi.Hostname) bool {
+	if strings.HasPrefix(string(pattern), "*.") {
+		return strings.HasSuffix(string(hostname), string(pattern[1:]))
+	}
+	return pattern == hostname
+}
+
+// Append hostnames not already present
+func appendHostnames(hostnames []gatewayapi.Hostname, add ...gatewayapi.Hostname) []gatewayapi.Hostname {
+	for _, h := range add {
+		found := false
+		for _, existing := range hostnames {
+			found = found || existing == h
+		}
+		if !found {
+			hostnames = append(hostnames, h)
+		}
+	}
+	return hostnames
+}
@@ -0,0 +1,222 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1a2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+)
+
+func TestIntersectHostname(t *testing.T) {
+	testCases := []struct {
+		a, b     gatewayapi.Hostname
+		expected gatewayapi.Hostname
+		ok       bool
+	}{
+		{"foo.example.com", "foo.example.com", "foo.example.com", true},
+		{"foo.example.com", "bar.example.com", "", false},
+		{"*.example.com", "foo.example.com", "foo.example.com", true},
+		{"foo.example.com", "*.example.com", "foo.example.com", true},
+		{"*.example.com", "foo.bar.example.com", "foo.bar.example.com", true},
+		{"*.example.com", "example.com", "", false},
+		{"*.example.com", "*.foo.example.com", "*.foo.example.com", true},
+		{"*.example.com", "*.example.org", "", false},
+		{"*.example.com", "fooexample.com", "", false},
+	}
+
+	for _, tc := range testCases {
+		hostname, ok := intersectHostname(tc.a, tc.b)
+		if hostname != tc.expected || ok != tc.ok {
+			t.Errorf("intersectHostname(%q, %q) got %q/%v, expected %q/%v", tc.a, tc.b, hostname, ok, tc.expected, tc.ok)
+		}
+	}
+}
+
+func helperAttachmentGateway() *gatewayapi.Gateway {
+	fromAll := gatewayapi.NamespacesFromAll
+	fromSelector := gatewayapi.NamespacesFromSelector
+	return &gatewayapi.Gateway{
+		ObjectMeta: metav1.ObjectMeta{Name: "gw", Namespace: "default"},
+		Spec: gatewayapi.GatewaySpec{
+			Listeners: []gatewayapi.Listener{
+				{
+					Name:     "http",
+					Port:     80,
+					Protocol: gatewayapi.HTTPProtocolType,
+					Hostname: PtrTo(gatewayapi.Hostname("*.example.com")),
+				},
+				{
+					Name:     "https",
+					Port:     443,
+					Protocol: gatewayapi.HTTPSProtocolType,
+					Hostname: PtrTo(gatewayapi.Hostname("*.example.com")),
+					AllowedRoutes: &gatewayapi.AllowedRoutes{
+						Namespaces: &gatewayapi.RouteNamespaces{
+							From: &fromSelector,
+							Selector: &metav1.LabelSelector{
+								MatchLabels: map[string]string{"allowGateway": "gw"},
+							},
+						},
+					},
+				},
+				{
+					Name:     "tls",
+					Port:     8443,
+					Protocol: gatewayapi.TLSProtocolType,
+					AllowedRoutes: &gatewayapi.AllowedRoutes{
+						Namespaces: &gatewayapi.RouteNamespaces{From: &fromAll},
+					},
+				},
+			},
+		},
+	}
+}
+
+func helperAttacher(t *testing.T, gw *gatewayapi.Gateway) *gatewayAttacher {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	ns := func(name string, labels map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	r := &fakeReconciler{
+		client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			ns("default", nil), ns("allowed", map[string]string{"allowGateway": "gw"}), ns("other", nil)).Build(),
+		scheme: scheme,
+	}
+	gwcb := &gwcapi.GatewayClassBlueprint{}
+	gwcb.Spec.HTTPRouteTemplate.ResourceTemplates = map[string]string{"foo": "kind: ConfigMap"}
+	gwcb.Spec.TLSRouteTemplate.ResourceTemplates = map[string]string{"foo": "kind: ConfigMap"}
+
+	a, err := newGatewayAttacher(context.Background(), r, gw, gwcb, routeTypes)
+	if err != nil {
+		t.Fatalf("newGatewayAttacher() failed: %v", err)
+	}
+	return a
+}
+
+func helperHTTPRoute(name, namespace string, pRef gatewayapi.ParentReference, hostnames ...gatewayapi.Hostname) *gatewayapi.HTTPRoute {
+	rt := &gatewayapi.HTTPRoute{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+	rt.Spec.ParentRefs = []gatewayapi.ParentReference{pRef}
+	rt.Spec.Hostnames = hostnames
+	return rt
+}
+
+func TestAttachRoute(t *testing.T) {
+	a := helperAttacher(t, helperAttachmentGateway())
+	gwRef := gatewayapi.ParentReference{Name: "gw", Namespace: PtrTo(gatewayapi.Namespace("default"))}
+	sectionRef := func(name string) gatewayapi.ParentReference {
+		pRef := gwRef
+		pRef.SectionName = PtrTo(gatewayapi.SectionName(name))
+		return pRef
+	}
+	portRef := func(port gatewayapi.PortNumber) gatewayapi.ParentReference {
+		pRef := gwRef
+		pRef.Port = &port
+		return pRef
+	}
+	tlsRoute := &gatewayv1a2.TLSRoute{ObjectMeta: metav1.ObjectMeta{Name: "tls", Namespace: "other"}}
+	tlsRoute.Spec.ParentRefs = []gatewayapi.ParentReference{gwRef}
+	tlsRoute.Spec.Hostnames = []gatewayapi.Hostname{"sni.example.net"}
+
+	testCases := []struct {
+		name              string
+		rt                client.Object
+		expectedListeners []gatewayapi.SectionName
+		expectedHostnames []gatewayapi.Hostname
+		expectedReason    gatewayapi.RouteConditionReason
+	}{
+		{"same-namespace", helperHTTPRoute("rt", "default", gwRef, "foo.example.com", "foo.example.org"),
+			[]gatewayapi.SectionName{"http"}, []gatewayapi.Hostname{"foo.example.com"}, ""},
+		{"no-route-hostnames", helperHTTPRoute("rt", "default", gwRef),
+			[]gatewayapi.SectionName{"http"}, []gatewayapi.Hostname{"*.example.com"}, ""},
+		{"selected-namespace", helperHTTPRoute("rt", "allowed", gwRef, "foo.example.com"),
+			[]gatewayapi.SectionName{"https"}, []gatewayapi.Hostname{"foo.example.com"}, ""},
+		{"namespace-not-allowed", helperHTTPRoute("rt", "other", gwRef, "foo.example.com"),
+			nil, nil, gatewayapi.RouteReasonNotAllowedByListeners},
+		{"no-matching-hostname", helperHTTPRoute("rt", "default", gwRef, "foo.example.org"),
+			nil, nil, gatewayapi.RouteReasonNoMatchingListenerHostname},
+		{"section-name", helperHTTPRoute("rt", "allowed", sectionRef("https"), "foo.example.com"),
+			[]gatewayapi.SectionName{"https"}, []gatewayapi.Hostname{"foo.example.com"}, ""},
+		{"section-name-not-allowed", helperHTTPRoute("rt", "default", sectionRef("https"), "foo.example.com"),
+			nil, nil, gatewayapi.RouteReasonNotAllowedByListeners},
+		{"unknown-section-name", helperHTTPRoute("rt", "default", sectionRef("foo"), "foo.example.com"),
+			nil, nil, gatewayapi.RouteReasonNoMatchingParent},
+		{"port", helperHTTPRoute("rt", "default", portRef(80), "foo.example.com"),
+			[]gatewayapi.SectionName{"http"}, []gatewayapi.Hostname{"foo.example.com"}, ""},
+		{"unknown-port", helperHTTPRoute("rt", "default", portRef(8080), "foo.example.com"),
+			nil, nil, gatewayapi.RouteReasonNoMatchingParent},
+		{"kind-not-allowed", helperHTTPRoute("rt", "allowed", sectionRef("tls"), "foo.example.com"),
+			nil, nil, gatewayapi.RouteReasonNotAllowedByListeners},
+		{"tls-route", tlsRoute,
+			[]gatewayapi.SectionName{"tls"}, []gatewayapi.Hostname{"sni.example.net"}, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			att := a.attachRoute(tc.rt, &routeCommonSpec(tc.rt).ParentRefs[0])
+			if !reflect.DeepEqual(att.Listeners, tc.expectedListeners) {
+				t.Errorf("listeners got %v, expected %v", att.Listeners, tc.expectedListeners)
+			}
+			if !reflect.DeepEqual(att.Hostnames, tc.expectedHostnames) {
+				t.Errorf("hostnames got %v, expected %v", att.Hostnames, tc.expectedHostnames)
+			}
+			if att.Reason != tc.expectedReason {
+				t.Errorf("reason got %q, expected %q", att.Reason, tc.expectedReason)
+			}
+		})
+	}
+}
+
+func TestAttachRoutes(t *testing.T) {
+	gw := helperAttachmentGateway()
+	a := helperAttacher(t, gw)
+	gwRef := gatewayapi.ParentReference{Name: "gw"}
+	otherGwRef := gatewayapi.ParentReference{Name: "other-gw"}
+
+	// Attached to both 'http' and 'https' through two parentRefs
+	twoRefs := helperHTTPRoute("two-refs", "default", gwRef, "example.com", "foo.example.com")
+	twoRefs.Spec.ParentRefs = append(twoRefs.Spec.ParentRefs, gatewayapi.ParentReference{
+		Name: "gw", SectionName: PtrTo(gatewayapi.SectionName("http"))})
+	crossNamespace := helperHTTPRoute("cross-namespace", "allowed", gatewayapi.ParentReference{
+		Name: "gw", Namespace: PtrTo(gatewayapi.Namespace("default"))}, "bar.example.com")
+	notAllowed := helperHTTPRoute("not-allowed", "other", gatewayapi.ParentReference{
+		Name: "gw", Namespace: PtrTo(gatewayapi.Namespace("default"))}, "leak.example.com")
+	otherGw := helperHTTPRoute("other-gw", "default", otherGwRef, "other.example.com")
+	tcpRoute := &gatewayv1a2.TCPRoute{ObjectMeta: metav1.ObjectMeta{Name: "tcp", Namespace: "default"}}
+	tcpRoute.Spec.ParentRefs = []gatewayapi.ParentReference{gwRef}
+
+	att := a.attachRoutes([]client.Object{twoRefs, crossNamespace, notAllowed, otherGw, tcpRoute})
+
+	names := []string{}
+	for _, rt := range att.Routes {
+		names = append(names, rt.GetName())
+	}
+	if !reflect.DeepEqual(names, []string{"two-refs", "cross-namespace"}) {
+		t.Errorf("attached routes got %v", names)
+	}
+	expectedAttached := map[gatewayapi.SectionName]int32{"http": 1, "https": 1}
+	if !reflect.DeepEqual(att.AttachedRoutes, expectedAttached) {
+		t.Errorf("attached route count got %v, expected %v", att.AttachedRoutes, expectedAttached)
+	}
+
+	union, isect := combineHostnames(gw, att.Hostnames)
+	sort.Strings(union)
+	sort.Strings(isect)
+	expected := []string{"*.example.com", "bar.example.com", "foo.example.com"}
+	if !reflect.DeepEqual(union, expected) {
+		t.Errorf("combineHostnames() union got %v, expected %v", union, expected)
+	}
+	if !reflect.DeepEqual(isect, expected) {
+		t.Errorf("combineHostnames() intersection got %v, expected %v", isect, expected)
+	}
+}
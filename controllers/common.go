@@ -35,11 +35,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
+	"strings"
 
+	corev1 "k8s.io/api/core/v1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
@@ -97,82 +101,262 @@ func lookupGatewayClassBlueprint(ctx context.Context, r ControllerClient, gwc *g
 	return &gwcb, nil
 }
 
-// Deep map merge, with 'b' overwriting values in 'a'.  On type conflicts precedence is given to 'a' i.e. no overwrite
+// Merge strategies by dotted path of values
+type mergeStrategies map[string]gwcapi.ValuesMergeStrategy
+
+// Merge strategies of a blueprint or policy, with strategies in 'base'
+// applying to paths without a strategy
+func newMergeStrategies(base mergeStrategies, values *gwcapi.TemplateValues) mergeStrategies {
+	strategies := mergeStrategies{}
+	for path, strategy := range base {
+		strategies[path] = strategy
+	}
+	for _, strategy := range values.MergeStrategies {
+		strategies[strategy.Path] = strategy
+	}
+	return strategies
+}
+
+// Deep map merge, with 'b' overwriting values in 'a'. Null values in
+// 'b' delete values in 'a'. On type conflicts precedence is given to
+// 'b', i.e. 'a' is overwritten. Lists are replaced unless a merge
+// strategy is defined for the path of the list.
 // x and y are concrete versions of a and b
-func merge(a, b any) any {
+func merge(a, b any, path []string, strategies mergeStrategies) any {
 	switch x := a.(type) {
 	case map[string]any:
 		y, ok := b.(map[string]any)
 		if !ok {
-			return a
+			return removeNulls(b)
 		}
 		for k, vy := range y { // Copy from 'b' (represented by 'y') into 'a'
-			if va, ok := x[k]; ok {
-				x[k] = merge(va, vy)
+			if vy == nil {
+				delete(x, k)
+			} else if va, ok := x[k]; ok {
+				x[k] = merge(va, vy, append(append([]string{}, path...), k), strategies)
 			} else {
-				x[k] = vy
+				x[k] = removeNulls(vy)
 			}
 		}
+	case []any:
+		y, ok := b.([]any)
+		if !ok {
+			return removeNulls(b)
+		}
+		strategy := strategies[strings.Join(path, ".")]
+		switch strategy.Strategy {
+		case gwcapi.MergeStrategyAppend:
+			return append(x, removeNulls(y).([]any)...)
+		case gwcapi.MergeStrategyMergeByKey:
+			return mergeByKey(x, y, strategy.Key)
+		}
+		return removeNulls(b)
 	default:
-		return b
+		return removeNulls(b)
+	}
+	return a
+}
+
+// Merge list items with the same value of 'key', e.g. the same
+// name. Items of 'b' without a matching item in 'a' are appended
+func mergeByKey(a, b []any, key string) []any {
+	for _, vb := range b {
+		mb, ok := vb.(map[string]any)
+		if !ok || mb[key] == nil {
+			a = append(a, removeNulls(vb))
+			continue
+		}
+		merged := false
+		for idx, va := range a {
+			if ma, ok := va.(map[string]any); ok && reflect.DeepEqual(ma[key], mb[key]) {
+				a[idx] = merge(ma, mb, nil, nil)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			a = append(a, removeNulls(mb))
+		}
 	}
 	return a
 }
 
+// Remove null values from objects, i.e. null values only delete
+// existing values
+func removeNulls(val any) any {
+	switch x := val.(type) {
+	case map[string]any:
+		for k, v := range x {
+			if v == nil {
+				delete(x, k)
+			} else {
+				x[k] = removeNulls(v)
+			}
+		}
+	case []any:
+		for idx, v := range x {
+			x[idx] = removeNulls(v)
+		}
+	}
+	return val
+}
+
 // Lookup values from GatewayClassConfig/GatewayConfig CRDs and combine using precedence rules:
 // - Values from GatewayClassBlueprint
 // - Values from GatewayClassConfig in controller namespace (aka. global policies)
+// - Values from GatewayClassConfig in controller namespace selecting namespace or Gateway by labels
 // - Values from GatewayClassConfig in Gateway/HTTPRoute local namespace targeting namespace
 // - Values from GatewayClassConfig in Gateway/HTTPRoute local namespace targeting GatewayClass
+// - Values from GatewayClassConfig in Gateway/HTTPRoute local namespace selecting Gateway by labels
 // - Values from GatewayConfig in Gateway/HTTPRoute local namespace, targeting namespace
+// - Values from GatewayConfig in Gateway/HTTPRoute local namespace, selecting Gateway by labels
 // - Values from GatewayConfig in Gateway/HTTPRoute local namespace, targeting Gateway/HTTPRoute resource
+// - Values from GatewayConfig in Gateway namespace, targeting a listener the route attaches to (routes only)
+// - Values from GatewayConfig in route namespace, targeting the route (routes only)
 // Note, defaults are processed top-to-bottom (i.e. later defaults overwrites earlier defaults), while overrides are bottom-to-top (see GEP-713)
+// Values from ConfigMaps and Secrets referenced through valuesFrom have the precedence of the blueprint or policy referencing them
 //
 // See also doc/extended-configuration-w-policy-attachments.md
 //
-// FIXME: Fully implement conflict resolution: https://gateway-api.sigs.k8s.io/references/policy-attachment/#conflict-resolution
-//
-//nolint:gocyclo // This function have a repeating character and this not as complex as the number of ifs may indicate
+// Policies with the same target and namespace conflicting with
+// policies of higher precedence are skipped, see resolvePolicyConflicts.
+// Remaining policies with the same target and namespace are merged in
+// order of precedence, see policyPrecedes.
 func lookupValues(ctx context.Context, r ControllerClient, gatewayClassName string, gwcb *gwcapi.GatewayClassBlueprint,
 	gwNamespace string, gwName string) (map[string]any, error) {
+	values, _, err := lookupValuesWithSources(ctx, r, gatewayClassName, gwcb, gwNamespace, gwName, nil)
+	return values, err
+}
+
+// Route and listeners of the parent Gateway the route attaches to,
+// i.e. the scope of route-specific values
+type routeValuesScope struct {
+	route     client.Object
+	listeners []gatewayapi.SectionName
+}
+
+// Source of values, i.e. the blueprint or policy which supplied a
+// value, by path of leaf values joined with valuePathSeparator, see
+// valueSourceKey. Keys of values may hold dots, e.g. 'tls.crt' from
+// Secrets, i.e. paths cannot be joined with dots
+type valueSources map[string]valueSource
+
+// Key of the value at a path in valueSources
+func valueSourceKey(path []string) string {
+	return strings.Join(path, valuePathSeparator)
+}
+
+// Path of a value from its key in valueSources
+func valueSourcePath(key string) []string {
+	return strings.Split(key, valuePathSeparator)
+}
+
+// Key in valueSources as a dotted path, e.g. 'healthCheck.port', for
+// output and for matching paths reported by schema validation
+func dottedValuePath(key string) string {
+	return strings.ReplaceAll(key, valuePathSeparator, ".")
+}
+
+// Lookup values like lookupValues and return the source of each
+// value. Values of GatewayConfigs targeting listeners or routes are
+// included when a route scope is given, i.e. when rendering routes
+//
+//nolint:gocyclo // This function have a repeating character and this not as complex as the number of ifs may indicate
+func lookupValuesWithSources(ctx context.Context, r ControllerClient, gatewayClassName string, gwcb *gwcapi.GatewayClassBlueprint,
+	gwNamespace string, gwName string, scope *routeValuesScope) (map[string]any, valueSources, error) {
 	values := map[string]any{}
+	sources := valueSources{}
 	var err error
 
 	// Helper to parse and merge-overwrite values. IMPORTANT: All
 	// values from GatewayClassConfig and GatewayConfigs are
 	// Unmarshalled and hence we will not be modifying original
 	// K8s resources
-	mergeValues := func(src *apiextensionsv1.JSON, existing map[string]any) (map[string]any, error) {
+	mergeMap := func(newvals, existing map[string]any, srcObj client.Object, override, sensitive bool,
+		strategies mergeStrategies) (map[string]any, error) {
+		existing, ok := merge(existing, newvals, nil, strategies).(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("cannot merge values")
+		}
+		src := valueSourceOf(srcObj, override)
+		src.Sensitive = sensitive
+		walkLeafValues(nil, newvals, func(path []string, val any) {
+			if _, found := valueAtPath(existing, path); found && val != nil {
+				sources[valueSourceKey(path)] = src
+			}
+		})
+		// Values deleted through null values or replaced by objects have no source
+		for key := range sources {
+			merged, found := valueAtPath(existing, valueSourcePath(key))
+			if _, isObject := merged.(map[string]any); !found || isObject {
+				delete(sources, key)
+			}
+		}
+		return existing, nil
+	}
+	mergeValues := func(src *apiextensionsv1.JSON, existing map[string]any, srcObj client.Object, override bool,
+		strategies mergeStrategies) (map[string]any, error) {
 		if src != nil {
 			newvals := map[string]any{}
-			var ok bool
 			if err = json.Unmarshal(src.Raw, &newvals); err != nil {
 				return nil, fmt.Errorf("cannot unmarshal values: %w", err)
 			}
-			existing, ok = merge(existing, newvals).(map[string]any)
-			if !ok {
-				return nil, fmt.Errorf("cannot merge values: %w", err)
+			return mergeMap(newvals, existing, srcObj, override, false, strategies)
+		}
+		return existing, nil
+	}
+
+	// Values from ConfigMaps and Secrets are merged after the
+	// default or override values of the blueprint or policy. The
+	// blueprint is cluster scoped and uses the controller namespace
+	mergeValuesFrom := func(srcObj client.Object, values *gwcapi.TemplateValues, existing map[string]any, override bool,
+		strategies mergeStrategies) (map[string]any, error) {
+		namespace := srcObj.GetNamespace()
+		if namespace == "" {
+			namespace = ControllerNamespace
+		}
+		from, err := lookupValuesFrom(ctx, r.Client(), namespace, values.ValuesFrom, override)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range from {
+			if existing, err = mergeMap(f.values, existing, srcObj, override, f.sensitive, strategies); err != nil {
+				return nil, err
 			}
 		}
 		return existing, nil
 	}
+	// Merge default or override values of a blueprint or policy,
+	// followed by values from ConfigMaps and Secrets
+	mergeAll := func(srcObj client.Object, values *gwcapi.TemplateValues, existing map[string]any, override bool,
+		strategies mergeStrategies) (map[string]any, error) {
+		src := values.Default
+		if override {
+			src = values.Override
+		}
+		existing, err := mergeValues(src, existing, srcObj, override, strategies)
+		if err != nil {
+			return nil, err
+		}
+		return mergeValuesFrom(srcObj, values, existing, override, strategies)
+	}
 
 	var gwccGlobal gwcapi.GatewayClassConfigList
 	err = r.Client().List(ctx, &gwccGlobal, client.InNamespace(ControllerNamespace))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// GatewayClassConfig and GatewayConfig in same namespace as parent resource (e.g. a Gateway resource)
 	var gwccLocal gwcapi.GatewayClassConfigList
 	err = r.Client().List(ctx, &gwccLocal, client.InNamespace(gwNamespace))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	var gwcLocal gwcapi.GatewayConfigList
 	err = r.Client().List(ctx, &gwcLocal, client.InNamespace(gwNamespace))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Select policies that target GatewayClass, parent resource or namespace of parent resource
@@ -180,69 +364,155 @@ func lookupValues(ctx context.Context, r ControllerClient, gatewayClassName stri
 	var gwccFiltered []*gwcapi.GatewayClassConfig
 	var gwcFiltered []*gwcapi.GatewayConfig
 
-	// Global GatewayClassConfig first
-	for idx := range gwccGlobal.Items {
-		gwcc := &gwccGlobal.Items[idx]
-		if gwcc.Spec.TargetRef.Kind == "GatewayClass" &&
-			gwcc.Spec.TargetRef.Group == gatewayapi.GroupName &&
-			string(gwcc.Spec.TargetRef.Name) == gatewayClassName {
-			gwccFiltered = append(gwccFiltered, gwcc) // gwcc targets GatewayClass
+	// Policies with the same target and namespace which conflict
+	// with policies of higher precedence are skipped, see
+	// resolvePolicyConflicts
+	selectGwcc := func(items []gwcapi.GatewayClassConfig, match func(gwcc *gwcapi.GatewayClassConfig) bool) {
+		selected := []*gwcapi.GatewayClassConfig{}
+		for idx := range items {
+			if match(&items[idx]) {
+				selected = append(selected, &items[idx])
+			}
 		}
+		gwccFiltered = append(gwccFiltered, acceptedPolicies(selected)...)
 	}
-	// Namespace GatewayClassConfig targeting namespace second
-	for idx := range gwccLocal.Items {
-		gwcc := &gwccLocal.Items[idx]
-		if gwcc.Spec.TargetRef.Kind == "Namespace" &&
-			gwcc.Spec.TargetRef.Group == "" &&
-			string(gwcc.Spec.TargetRef.Name) == gwNamespace {
-			gwccFiltered = append(gwccFiltered, gwcc) // gwcc targets namespace
+	selectGwc := func(items []gwcapi.GatewayConfig, match func(gwc *gwcapi.GatewayConfig) bool) {
+		selected := []*gwcapi.GatewayConfig{}
+		for idx := range items {
+			if match(&items[idx]) {
+				selected = append(selected, &items[idx])
+			}
 		}
+		gwcFiltered = append(gwcFiltered, acceptedPolicies(selected)...)
 	}
-	// Namespace GatewayClassConfig targeting GatewayClass third
-	for idx := range gwccLocal.Items {
-		gwcc := &gwccLocal.Items[idx]
-		if gwcc.Spec.TargetRef.Kind == "GatewayClass" &&
-			gwcc.Spec.TargetRef.Group == gatewayapi.GroupName &&
-			string(gwcc.Spec.TargetRef.Name) == gatewayClassName {
-			gwccFiltered = append(gwccFiltered, gwcc) // gwcc targets GatewayClass
+
+	// Labels of the Gateway and its namespace, looked up when
+	// policies select targets by labels
+	var gwLabels, nsLabels labels.Set
+	var labelsErr error
+	selects := func(policy valuesPolicy, kind gatewayapi.Kind) bool {
+		if policy.GetTargetSelector() == nil || policy.GetTargetSelector().Kind != kind || labelsErr != nil {
+			return false
 		}
+		if kind == "Namespace" {
+			if nsLabels == nil {
+				var ns corev1.Namespace
+				if labelsErr = r.Client().Get(ctx, types.NamespacedName{Name: gwNamespace}, &ns); labelsErr != nil {
+					return false
+				}
+				nsLabels = labels.Merge(ns.Labels, nil)
+			}
+			return policySelects(policy, kind, gwNamespace, nsLabels)
+		}
+		if gwLabels == nil {
+			var gw gatewayapi.Gateway
+			if labelsErr = r.Client().Get(ctx, types.NamespacedName{Namespace: gwNamespace, Name: gwName}, &gw); labelsErr != nil {
+				return false
+			}
+			gwLabels = labels.Merge(gw.Labels, nil)
+		}
+		return policySelects(policy, kind, gwNamespace, gwLabels)
 	}
+
+	// Global GatewayClassConfig first, selecting by labels after targeting GatewayClass
+	selectGwcc(gwccGlobal.Items, func(gwcc *gwcapi.GatewayClassConfig) bool {
+		return gwcc.GetTargetRef().Kind == "GatewayClass" &&
+			gwcc.GetTargetRef().Group == gatewayapi.GroupName &&
+			string(gwcc.GetTargetRef().Name) == gatewayClassName // gwcc targets GatewayClass
+	})
+	selectGwcc(gwccGlobal.Items, func(gwcc *gwcapi.GatewayClassConfig) bool {
+		return selects(gwcc, "Namespace") // gwcc selects namespace of Gateway
+	})
+	selectGwcc(gwccGlobal.Items, func(gwcc *gwcapi.GatewayClassConfig) bool {
+		return selects(gwcc, "Gateway") // gwcc selects Gateway
+	})
+	// Namespace GatewayClassConfig targeting namespace second
+	selectGwcc(gwccLocal.Items, func(gwcc *gwcapi.GatewayClassConfig) bool {
+		return gwcc.GetTargetRef().Kind == "Namespace" &&
+			gwcc.GetTargetRef().Group == "" &&
+			string(gwcc.GetTargetRef().Name) == gwNamespace // gwcc targets namespace
+	})
+	// Namespace GatewayClassConfig targeting GatewayClass third
+	selectGwcc(gwccLocal.Items, func(gwcc *gwcapi.GatewayClassConfig) bool {
+		return gwcc.GetTargetRef().Kind == "GatewayClass" &&
+			gwcc.GetTargetRef().Group == gatewayapi.GroupName &&
+			string(gwcc.GetTargetRef().Name) == gatewayClassName // gwcc targets GatewayClass
+	})
+	// Namespace GatewayClassConfig selecting Gateway fourth
+	selectGwcc(gwccLocal.Items, func(gwcc *gwcapi.GatewayClassConfig) bool {
+		return gwcc.Namespace != ControllerNamespace && selects(gwcc, "Gateway") // gwcc selects Gateway
+	})
 	// Namespace GatewayConfig first
-	for idx := range gwcLocal.Items {
-		gwc := &gwcLocal.Items[idx]
-		if gwc.Spec.TargetRef.Kind == "Namespace" &&
-			gwc.Spec.TargetRef.Group == "" &&
-			string(gwc.Spec.TargetRef.Name) == gwNamespace {
-			gwcFiltered = append(gwcFiltered, gwc) // gwcc targets namespace of Gateway
-		}
+	selectGwc(gwcLocal.Items, func(gwc *gwcapi.GatewayConfig) bool {
+		return gwc.GetTargetRef().Kind == "Namespace" &&
+			gwc.GetTargetRef().Group == "" &&
+			string(gwc.GetTargetRef().Name) == gwNamespace // gwc targets namespace of Gateway
+	})
+	// Namespace GatewayConfig selecting Gateway second
+	selectGwc(gwcLocal.Items, func(gwc *gwcapi.GatewayConfig) bool {
+		return selects(gwc, "Gateway") // gwc selects Gateway
+	})
+	// Parent resource GatewayConfig third
+	targetsGateway := func(gwc *gwcapi.GatewayConfig) bool {
+		return gwc.GetTargetRef().Kind == "Gateway" &&
+			gwc.GetTargetRef().Group == gatewayapi.GroupName &&
+			(gwc.GetTargetRef().Namespace == nil || string(*gwc.GetTargetRef().Namespace) == gwNamespace) &&
+			string(gwc.GetTargetRef().Name) == gwName // gwc targets Gateway
 	}
-	// Parent resource GatewayConfig second
-	for idx := range gwcLocal.Items {
-		gwc := &gwcLocal.Items[idx]
-		if gwc.Spec.TargetRef.Kind == "Gateway" &&
-			gwc.Spec.TargetRef.Group == gatewayapi.GroupName &&
-			(gwc.Spec.TargetRef.Namespace == nil || string(*gwc.Spec.TargetRef.Namespace) == gwNamespace) &&
-			string(gwc.Spec.TargetRef.Name) == gwName {
-			gwcFiltered = append(gwcFiltered, gwc) // gwcc targets Gateway
+	selectGwc(gwcLocal.Items, func(gwc *gwcapi.GatewayConfig) bool {
+		return targetsGateway(gwc) && policySectionName(gwc) == nil
+	})
+	if scope != nil {
+		// GatewayConfig targeting listeners the route attaches to fourth, in order of listeners
+		for _, listener := range scope.listeners {
+			selectGwc(gwcLocal.Items, func(gwc *gwcapi.GatewayConfig) bool {
+				return targetsGateway(gwc) && derefCmp(policySectionName(gwc), &listener)
+			})
+		}
+
+		// GatewayConfig in route namespace targeting the route last
+		if rtType := routeTypeOf(scope.route); rtType != nil {
+			var gwcRoute gwcapi.GatewayConfigList
+			err = r.Client().List(ctx, &gwcRoute, client.InNamespace(scope.route.GetNamespace()))
+			if err != nil {
+				return nil, nil, err
+			}
+			selectGwc(gwcRoute.Items, func(gwc *gwcapi.GatewayConfig) bool {
+				return gwc.GetTargetRef().Kind == gatewayapi.Kind(rtType.Kind) &&
+					gwc.GetTargetRef().Group == gatewayapi.Group(rtType.GroupVersion.Group) &&
+					(gwc.GetTargetRef().Namespace == nil || string(*gwc.GetTargetRef().Namespace) == scope.route.GetNamespace()) &&
+					string(gwc.GetTargetRef().Name) == scope.route.GetName() // gwc targets route
+			})
 		}
 	}
 
+	if labelsErr != nil {
+		return nil, nil, fmt.Errorf("cannot lookup labels for policies selecting targets by labels: %w", labelsErr)
+	}
+
+	// Merge strategies of the blueprint apply to values from all
+	// policies, while strategies of a policy apply to its own values
+	blueprintStrategies := newMergeStrategies(nil, &gwcb.Spec.Values)
+	policyStrategies := func(pol valuesPolicy) mergeStrategies {
+		return newMergeStrategies(blueprintStrategies, pol.GetTemplateValues())
+	}
+
 	// Process defaults
 
 	// Blueprint default values are first
-	if values, err = mergeValues(gwcb.Spec.Values.Default, values); err != nil {
-		return nil, fmt.Errorf("while processing blueprint default values for gatewayclass %s: %w", gatewayClassName, err)
+	if values, err = mergeAll(gwcb, &gwcb.Spec.Values, values, false, blueprintStrategies); err != nil {
+		return nil, nil, fmt.Errorf("while processing blueprint default values for gatewayclass %s: %w", gatewayClassName, err)
 	}
 	// GatewayClassConfig, ordered, global first
 	for _, pol := range gwccFiltered {
-		if values, err = mergeValues(pol.Spec.Default, values); err != nil {
-			return nil, fmt.Errorf("while processing %s: %w", pol.Name, err)
+		if values, err = mergeAll(pol, &pol.Spec.TemplateValues, values, false, policyStrategies(pol)); err != nil {
+			return nil, nil, fmt.Errorf("while processing %s: %w", pol.Name, err)
 		}
 	}
 	// GatewayConfig, ordered, namespace-targeted first
 	for _, pol := range gwcFiltered {
-		if values, err = mergeValues(pol.Spec.Default, values); err != nil {
-			return nil, fmt.Errorf("while processing %s: %w", pol.Name, err)
+		if values, err = mergeAll(pol, &pol.Spec.TemplateValues, values, false, policyStrategies(pol)); err != nil {
+			return nil, nil, fmt.Errorf("while processing %s: %w", pol.Name, err)
 		}
 	}
 
@@ -250,24 +520,24 @@ func lookupValues(ctx context.Context, r ControllerClient, gatewayClassName stri
 
 	// GatewayConfig, ordered, namespace-targeted is first i.e. reverse loop
 	for idx := len(gwcFiltered) - 1; idx >= 0; idx-- {
-		if values, err = mergeValues(gwcFiltered[idx].Spec.Override, values); err != nil {
-			return nil, fmt.Errorf("while processing %s: %w", gwcFiltered[idx].Name, err)
+		if values, err = mergeAll(gwcFiltered[idx], &gwcFiltered[idx].Spec.TemplateValues, values, true, policyStrategies(gwcFiltered[idx])); err != nil {
+			return nil, nil, fmt.Errorf("while processing %s: %w", gwcFiltered[idx].Name, err)
 		}
 	}
 
 	// GatewayClassConfig, ordered, global is first i.e. reverse loop
 	for idx := len(gwccFiltered) - 1; idx >= 0; idx-- {
-		if values, err = mergeValues(gwccFiltered[idx].Spec.Override, values); err != nil {
-			return nil, fmt.Errorf("while processing %s: %w", gwccFiltered[idx].Name, err)
+		if values, err = mergeAll(gwccFiltered[idx], &gwccFiltered[idx].Spec.TemplateValues, values, true, policyStrategies(gwccFiltered[idx])); err != nil {
+			return nil, nil, fmt.Errorf("while processing %s: %w", gwccFiltered[idx].Name, err)
 		}
 	}
 
 	// Blueprint override values are last since they have highest precedence
-	if values, err = mergeValues(gwcb.Spec.Values.Override, values); err != nil {
-		return nil, fmt.Errorf("while processing blueprint override values for gatewayclass %s: %w", gatewayClassName, err)
+	if values, err = mergeAll(gwcb, &gwcb.Spec.Values, values, true, blueprintStrategies); err != nil {
+		return nil, nil, fmt.Errorf("while processing blueprint override values for gatewayclass %s: %w", gatewayClassName, err)
 	}
 
-	return values, nil
+	return values, sources, nil
 }
 
 func lookupGateway(ctx context.Context, r ControllerClient, name gatewayapi.ObjectName, namespace string) (*gatewayapi.Gateway, error) {
@@ -307,37 +577,57 @@ func unstructuredToGVR(r ControllerClient, u *unstructured.Unstructured) (*schem
 	}, isNamespaced, nil
 }
 
-// Apply an unstructured object using server-side apply
+// Apply an unstructured object using server-side apply. With dryRun,
+// the object is not persisted by the API server. Returns the
+// resulting object
 func patchUnstructured(ctx context.Context, r ControllerDynClient, us *unstructured.Unstructured,
-	gvr *schema.GroupVersionResource, namespace *string) error {
+	gvr *schema.GroupVersionResource, namespace *string, dryRun bool) (*unstructured.Unstructured, error) {
 	jsonData, err := json.Marshal(us.Object)
 	if err != nil {
-		return fmt.Errorf("unable to marshal unstructured to json %w", err)
+		return nil, fmt.Errorf("unable to marshal unstructured to json %w", err)
 	}
 
 	force := true
 
-	metricPatchApply.Inc()
+	var dynamicClient dynamic.ResourceInterface
 	if namespace != nil {
-		dynamicClient := r.DynamicClient().Resource(*gvr).Namespace(*namespace)
-		_, err = dynamicClient.Patch(ctx, us.GetName(), types.ApplyPatchType, jsonData, metav1.PatchOptions{
-			Force:        &force,
-			FieldManager: string(selfapi.SelfControllerName),
-		})
+		dynamicClient = r.DynamicClient().Resource(*gvr).Namespace(*namespace)
 	} else {
-		dynamicClient := r.DynamicClient().Resource(*gvr)
-		_, err = dynamicClient.Patch(ctx, us.GetName(), types.ApplyPatchType, jsonData, metav1.PatchOptions{
-			Force:        &force,
-			FieldManager: string(selfapi.SelfControllerName),
-		})
+		dynamicClient = r.DynamicClient().Resource(*gvr)
 	}
 
+	opts := metav1.PatchOptions{
+		Force:        &force,
+		FieldManager: string(selfapi.SelfControllerName),
+	}
+	if dryRun {
+		opts.DryRun = []string{metav1.DryRunAll}
+	}
+
+	metricPatchApply.Inc()
+	applied, err := dynamicClient.Patch(ctx, us.GetName(), types.ApplyPatchType, jsonData, opts)
 	if err != nil {
 		metricPatchApplyErrs.Inc()
+		return nil, err
 	}
-	return err
+	return applied, nil
 }
 
 func PtrTo[T any](val T) *T {
 	return &val
 }
+
+// Return the value at a path of keys in nested values
+func valueAtPath(values map[string]any, path []string) (any, bool) {
+	var val any = values
+	for _, key := range path {
+		m, ok := val.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if val, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return val, true
+}
@@ -4,6 +4,9 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -257,3 +260,59 @@ var _ = Describe("Attached policies and value precedence", func() {
 		})
 	})
 })
+
+func TestMerge(t *testing.T) {
+	strategies := mergeStrategies{
+		"tags":      {Path: "tags", Strategy: gwcapi.MergeStrategyAppend},
+		"listeners": {Path: "listeners", Strategy: gwcapi.MergeStrategyMergeByKey, Key: "name"},
+	}
+	testCases := []struct {
+		name     string
+		a, b     string
+		expected string
+	}{
+		{"overwrite", `{"a": 1, "b": {"c": 1, "d": 1}}`, `{"b": {"c": 2}}`, `{"a": 1, "b": {"c": 2, "d": 1}}`},
+		{"type-conflict-object", `{"a": {"b": 1}}`, `{"a": 1}`, `{"a": 1}`},
+		{"type-conflict-value", `{"a": 1}`, `{"a": {"b": 1}}`, `{"a": {"b": 1}}`},
+		{"delete-null", `{"a": 1, "b": {"c": 1, "d": 1}}`, `{"a": null, "b": {"c": null}, "e": null}`, `{"b": {"d": 1}}`},
+		{"new-value-nested-null", `{}`, `{"a": {"b": null, "c": 1}}`, `{"a": {"c": 1}}`},
+		{"list-replace", `{"list": [1, 2]}`, `{"list": [3]}`, `{"list": [3]}`},
+		{"list-append", `{"tags": ["a"]}`, `{"tags": ["b", "c"]}`, `{"tags": ["a", "b", "c"]}`},
+		{"list-merge-by-key", `{"listeners": [{"name": "http", "port": 80, "tls": false}, {"name": "https", "port": 443}]}`,
+			`{"listeners": [{"name": "http", "port": 8080, "tls": null}, {"name": "grpc", "port": 9000}, {"port": 1}]}`,
+			`{"listeners": [{"name": "http", "port": 8080}, {"name": "https", "port": 443}, {"name": "grpc", "port": 9000}, {"port": 1}]}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var a, b, expected map[string]any
+			for _, v := range []struct {
+				src string
+				dst *map[string]any
+			}{{tc.a, &a}, {tc.b, &b}, {tc.expected, &expected}} {
+				if err := json.Unmarshal([]byte(v.src), v.dst); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := merge(a, b, nil, strategies); !reflect.DeepEqual(got, expected) {
+				t.Errorf("merge() got %v, expected %v", got, expected)
+			}
+		})
+	}
+}
+
+func TestNewMergeStrategies(t *testing.T) {
+	base := newMergeStrategies(nil, &gwcapi.TemplateValues{MergeStrategies: []gwcapi.ValuesMergeStrategy{
+		{Path: "tags", Strategy: gwcapi.MergeStrategyAppend},
+		{Path: "subnets", Strategy: gwcapi.MergeStrategyAppend},
+	}})
+	strategies := newMergeStrategies(base, &gwcapi.TemplateValues{MergeStrategies: []gwcapi.ValuesMergeStrategy{
+		{Path: "tags", Strategy: gwcapi.MergeStrategyReplace},
+	}})
+	if strategies["tags"].Strategy != gwcapi.MergeStrategyReplace || strategies["subnets"].Strategy != gwcapi.MergeStrategyAppend {
+		t.Errorf("unexpected strategies %v", strategies)
+	}
+	if base["tags"].Strategy != gwcapi.MergeStrategyAppend {
+		t.Errorf("base strategies modified %v", base)
+	}
+}
@@ -0,0 +1,268 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+)
+
+// Returned (wrapped) by sortTemplates when dependencies between templates are cyclic
+var errDependencyCycle = errors.New("dependency cycle between templates")
+
+// Call fn for all nodes of the parse trees of a template, including
+// templates defined using 'define'
+func walkTemplate(tmpl *template.Template, fn func(node parse.Node)) {
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		fn(node)
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, c := range n.Nodes {
+				walk(c)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.TemplateNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, c := range n.Cmds {
+				walk(c)
+			}
+		case *parse.CommandNode:
+			for _, c := range n.Args {
+				walk(c)
+			}
+		case *parse.ChainNode:
+			walk(n.Node)
+		}
+	}
+
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			walk(t.Tree.Root)
+		}
+	}
+}
+
+// Field names of a reference to a top-level template value,
+// e.g. '.Values.a.b' or '$.Values.a.b' gives 'Values', 'a' and 'b'
+func rootFieldIdent(node parse.Node) ([]string, bool) {
+	switch n := node.(type) {
+	case *parse.FieldNode:
+		return n.Ident, true
+	case *parse.VariableNode:
+		if len(n.Ident) == 0 || n.Ident[0] != "$" {
+			return nil, false
+		}
+		return n.Ident[1:], true
+	}
+	return nil, false
+}
+
+// Find names of sibling templates referenced from a template through
+// '.Resources.<name>', '$.Resources.<name>' or 'index .Resources "<name>"'
+func templateReferences(tmpl *template.Template) []string {
+	refs := map[string]bool{}
+
+	// Match '.Resources' or '$.Resources' with optional trailing field names
+	resourceIdent := func(node parse.Node) ([]string, bool) {
+		ident, ok := rootFieldIdent(node)
+		if !ok || len(ident) == 0 || ident[0] != "Resources" {
+			return nil, false
+		}
+		return ident[1:], true
+	}
+
+	walkTemplate(tmpl, func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.CommandNode:
+			if len(n.Args) >= 3 {
+				if fn, ok := n.Args[0].(*parse.IdentifierNode); ok && fn.Ident == "index" {
+					if fields, ok := resourceIdent(n.Args[1]); ok && len(fields) == 0 {
+						if name, ok := n.Args[2].(*parse.StringNode); ok {
+							refs[name.Text] = true
+						}
+					}
+				}
+			}
+		case *parse.FieldNode, *parse.VariableNode:
+			if fields, ok := resourceIdent(n); ok && len(fields) > 0 {
+				refs[fields[0]] = true
+			}
+		}
+	})
+
+	names := make([]string, 0, len(refs))
+	for name := range refs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Find dependencies between templates and sort templates in apply
+// wave and topological order, i.e. such that templates are sorted
+// after the templates they depend on. Templates without mutual
+// dependencies are sorted by name. Dependencies are found from
+// references to '.Resources' in templates and from the 'dependsOn'
+// template option. Returns an error if dependencies are cyclic.
+func sortTemplates(templates []*ResourceTemplateState, options map[string]gwcapi.ResourceTemplateOptions) ([]*ResourceTemplateState, error) {
+	byName := map[string]*ResourceTemplateState{}
+	for _, tmpl := range templates {
+		byName[tmpl.TemplateName] = tmpl
+	}
+
+	for _, tmpl := range templates {
+		deps := map[string]bool{}
+		for _, ref := range templateReferences(tmpl.Template) {
+			// References to unknown templates will fail when
+			// rendering. A template referencing its own
+			// resources is not considered a dependency
+			if _, found := byName[ref]; found && ref != tmpl.TemplateName {
+				deps[ref] = true
+			}
+		}
+		for _, dep := range options[tmpl.TemplateName].DependsOn {
+			if _, found := byName[dep]; !found {
+				return nil, fmt.Errorf("template %q depends on unknown template %q", tmpl.TemplateName, dep)
+			}
+			deps[dep] = true
+		}
+		tmpl.Dependencies = make([]string, 0, len(deps))
+		for dep := range deps {
+			tmpl.Dependencies = append(tmpl.Dependencies, dep)
+		}
+		sort.Strings(tmpl.Dependencies)
+	}
+
+	remaining := make([]*ResourceTemplateState, len(templates))
+	copy(remaining, templates)
+	sort.SliceStable(remaining, func(i, j int) bool { return remaining[i].TemplateName < remaining[j].TemplateName })
+
+	sorted := make([]*ResourceTemplateState, 0, len(templates))
+	done := map[string]bool{}
+	for len(remaining) > 0 {
+		next := remaining[:0:0]
+		progress := false
+		for _, tmpl := range remaining {
+			ready := true
+			for _, dep := range tmpl.Dependencies {
+				if !done[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				sorted = append(sorted, tmpl)
+				done[tmpl.TemplateName] = true
+				progress = true
+			} else {
+				next = append(next, tmpl)
+			}
+		}
+		if !progress {
+			return nil, fmt.Errorf("%w: %s", errDependencyCycle, strings.Join(findCycle(next, byName), " -> "))
+		}
+		remaining = next
+	}
+
+	// Templates are never applied before the templates they
+	// depend on, i.e. the wave of a template is at least the wave
+	// of its dependencies. A stable sort by wave retains the
+	// topological order.
+	for _, tmpl := range sorted {
+		tmpl.Wave = options[tmpl.TemplateName].Wave
+		for _, dep := range tmpl.Dependencies {
+			if byName[dep].Wave > tmpl.Wave {
+				tmpl.Wave = byName[dep].Wave
+			}
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Wave < sorted[j].Wave })
+
+	return sorted, nil
+}
+
+// Find a dependency cycle among templates which could not be sorted
+// topologically. Returns the template names of the cycle with the
+// first name repeated at the end.
+func findCycle(unsorted []*ResourceTemplateState, byName map[string]*ResourceTemplateState) []string {
+	isUnsorted := map[string]bool{}
+	for _, tmpl := range unsorted {
+		isUnsorted[tmpl.TemplateName] = true
+	}
+
+	// Every unsorted template has at least one unsorted
+	// dependency, i.e. following dependencies will eventually
+	// revisit a template
+	path := []string{}
+	visited := map[string]int{}
+	name := unsorted[0].TemplateName
+	for {
+		if idx, found := visited[name]; found {
+			return append(path[idx:], name)
+		}
+		visited[name] = len(path)
+		path = append(path, name)
+		for _, dep := range byName[name].Dependencies {
+			if isUnsorted[dep] {
+				name = dep
+				break
+			}
+		}
+	}
+}
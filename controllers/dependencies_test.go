@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+)
+
+func TestTemplateReferences(t *testing.T) {
+	cases := []struct {
+		tmpl string
+		refs []string
+	}{
+		{`name: {{ .Gateway.metadata.name }}`, []string{}},
+		{`arn: {{ (index .Resources.lb 0).status.arn }}`, []string{"lb"}},
+		{`{{ range .Values.x }}{{ $.Resources.cert }}{{ end }}`, []string{"cert"}},
+		{`{{ if .Resources.a }}{{ index .Resources "b" }}{{ else }}{{ toYaml .Resources.c | nindent 2 }}{{ end }}`, []string{"a", "b", "c"}},
+		{`{{ define "sub" }}{{ .Resources.d }}{{ end }}{{ template "sub" . }}`, []string{"d"}},
+		{`{{ with .Resources.e }}{{ .f }}{{ end }}`, []string{"e"}},
+	}
+	for idx, tcase := range cases {
+		tmpl, err := parseSingleTemplate("test", tcase.tmpl)
+		if err != nil {
+			t.Fatalf("Case %v, cannot parse template: %v", idx, err)
+		}
+		refs := templateReferences(tmpl)
+		if !reflect.DeepEqual(refs, tcase.refs) {
+			t.Fatalf("Case %v, got %v, expected %v", idx, refs, tcase.refs)
+		}
+	}
+}
+
+func helperSortedTemplateNames(t *testing.T, resourceTemplates map[string]string, options map[string]gwcapi.ResourceTemplateOptions) ([]string, error) {
+	templates, err := parseTemplates(resourceTemplates)
+	if err != nil {
+		t.Fatalf("Cannot parse templates: %v", err)
+	}
+	sorted, err := sortTemplates(templates, options)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, tmpl := range sorted {
+		names = append(names, tmpl.TemplateName)
+	}
+	return names, nil
+}
+
+func TestSortTemplates(t *testing.T) {
+	resourceTemplates := map[string]string{
+		"a":        `{{ .Resources.listener }}`,
+		"listener": `{{ .Resources.lb }}{{ .Resources.cert }}`,
+		"lb":       `{{ .Resources.sg }}`,
+		"sg":       `{{ .Resources.sg }}`,
+		"cert":     ``,
+		"z":        ``,
+	}
+	names, err := helperSortedTemplateNames(t, resourceTemplates, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{"cert", "sg", "z", "lb", "listener", "a"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Sort order, got %v, expected %v", names, expected)
+	}
+
+	// Explicit dependency
+	names, err = helperSortedTemplateNames(t, resourceTemplates, map[string]gwcapi.ResourceTemplateOptions{
+		"cert": {DependsOn: []string{"z"}},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected = []string{"sg", "z", "cert", "lb", "listener", "a"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Sort order, got %v, expected %v", names, expected)
+	}
+
+	// Unknown explicit dependency
+	_, err = helperSortedTemplateNames(t, resourceTemplates, map[string]gwcapi.ResourceTemplateOptions{
+		"cert": {DependsOn: []string{"unknown"}},
+	})
+	if err == nil || errors.Is(err, errDependencyCycle) {
+		t.Fatalf("Expected unknown dependency error, got %v", err)
+	}
+
+	// Cycle
+	_, err = helperSortedTemplateNames(t, resourceTemplates, map[string]gwcapi.ResourceTemplateOptions{
+		"sg": {DependsOn: []string{"a"}},
+	})
+	if !errors.Is(err, errDependencyCycle) {
+		t.Fatalf("Expected cycle error, got %v", err)
+	}
+	if err.Error() != "dependency cycle between templates: a -> listener -> lb -> sg -> a" {
+		t.Fatalf("Unexpected cycle error: %v", err)
+	}
+}
+
+func TestSortTemplatesWaves(t *testing.T) {
+	resourceTemplates := map[string]string{
+		"cert":     ``,
+		"lb":       ``,
+		"listener": `{{ .Resources.lb }}{{ .Resources.cert }}`,
+		"dns":      `{{ .Resources.lb }}`,
+	}
+	templates, err := parseTemplates(resourceTemplates)
+	if err != nil {
+		t.Fatalf("Cannot parse templates: %v", err)
+	}
+	sorted, err := sortTemplates(templates, map[string]gwcapi.ResourceTemplateOptions{
+		"cert": {Wave: 1},
+		"dns":  {Wave: 2},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	names := []string{}
+	waves := []int32{}
+	for _, tmpl := range sorted {
+		names = append(names, tmpl.TemplateName)
+		waves = append(waves, tmpl.Wave)
+	}
+	// Listener inherits wave from cert
+	if !reflect.DeepEqual(names, []string{"lb", "cert", "listener", "dns"}) || !reflect.DeepEqual(waves, []int32{0, 1, 1, 2}) {
+		t.Fatalf("Sort order, got %v with waves %v", names, waves)
+	}
+}
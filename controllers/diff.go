@@ -0,0 +1,372 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+)
+
+// Kind of change to a child resource
+type ChildChangeAction string
+
+const (
+	// Resource does not exist and would be created
+	ChildChangeCreate ChildChangeAction = "create"
+
+	// Resource exists and would be changed
+	ChildChangeUpdate ChildChangeAction = "update"
+
+	// Resource is no longer rendered and would be deleted
+	ChildChangePrune ChildChangeAction = "prune"
+
+	// Resource is no longer rendered but would be kept due to prune
+	// policy 'Keep' or 'Orphan'
+	ChildChangeKeep ChildChangeAction = "keep"
+)
+
+// A change to a child resource from changing a GatewayClassBlueprint
+type ChildChange struct {
+	Action ChildChangeAction
+
+	// Parent of the resource as 'Kind namespace/name'
+	Parent string
+
+	// Template rendering the resource. Empty for resources no longer rendered
+	Template string
+
+	// The resource as 'Kind namespace/name', or 'Kind name' for
+	// cluster scoped resources
+	Resource string
+
+	// Unified diff of the resource in the cluster and the resource
+	// as applied, without status and metadata set by the API
+	// server. Empty for resources no longer rendered
+	Diff string
+}
+
+// BlueprintDiffer finds the changes to child resources in a cluster
+// from replacing a GatewayClassBlueprint with a modified
+// version. Templates of the modified blueprint are rendered for all
+// Gateways and routes using the blueprint, with values and current
+// child resources read from the cluster, and rendered resources are
+// applied using server-side dry-run with the field manager of the
+// controller. Nothing is changed in the cluster.
+type BlueprintDiffer struct {
+	client    client.Client
+	dynClient dynamic.Interface
+	gwcb      *gwcapi.GatewayClassBlueprint
+
+	// Resources in the cluster and as applied, by resource name
+	applied map[string]appliedChild
+}
+
+type appliedChild struct {
+	live, applied *unstructured.Unstructured
+}
+
+func (d *BlueprintDiffer) Client() client.Client {
+	return d.client
+}
+
+func (d *BlueprintDiffer) Scheme() *runtime.Scheme {
+	return d.client.Scheme()
+}
+
+func (d *BlueprintDiffer) DynamicClient() dynamic.Interface {
+	return d.dynClient
+}
+
+// Client reading a replacement GatewayClassBlueprint instead of the
+// blueprint of the same name from the API server
+type blueprintOverrideClient struct {
+	client.Client
+	gwcb *gwcapi.GatewayClassBlueprint
+}
+
+func (c *blueprintOverrideClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	if gwcb, ok := obj.(*gwcapi.GatewayClassBlueprint); ok && key.Name == c.gwcb.Name {
+		c.gwcb.DeepCopyInto(gwcb)
+		return nil
+	}
+	return c.Client.Get(ctx, key, obj, opts...)
+}
+
+// Create a differ for a modified GatewayClassBlueprint. The client
+// scheme must include the kinds read by the controller, see
+// NewRendererScheme
+func NewBlueprintDiffer(c client.Client, dynClient dynamic.Interface, gwcb *gwcapi.GatewayClassBlueprint) *BlueprintDiffer {
+	return &BlueprintDiffer{
+		client:    &blueprintOverrideClient{Client: c, gwcb: gwcb},
+		dynClient: dynClient,
+		gwcb:      gwcb,
+	}
+}
+
+// Find the changes to child resources of all Gateways and routes using
+// the blueprint. Changes are returned also when some templates cannot
+// be rendered or applied, with the errors returned joined. Resources
+// are only reported as pruned when all templates of a parent could be
+// rendered and applied, like the controller does.
+func (d *BlueprintDiffer) Diff(ctx context.Context) ([]ChildChange, error) {
+	if _, err := validateBlueprint(d.gwcb, parseBlueprint(d.gwcb)); err != nil {
+		return nil, fmt.Errorf("invalid GatewayClassBlueprint %q: %w", d.gwcb.Name, err)
+	}
+	d.applied = map[string]appliedChild{}
+	renderer := &OfflineRenderer{client: d.client, scheme: d.Scheme(), apply: d.dryRunApply}
+
+	var gwcList gatewayapi.GatewayClassList
+	if err := d.client.List(ctx, &gwcList); err != nil {
+		return nil, fmt.Errorf("cannot list GatewayClasses: %w", err)
+	}
+	classes := map[string]bool{}
+	for idx := range gwcList.Items {
+		gwc := &gwcList.Items[idx]
+		ref := gwc.Spec.ParametersRef
+		if isOurGatewayClass(gwc) && ref != nil && string(ref.Group) == gwcapi.GroupVersion.Group &&
+			ref.Kind == "GatewayClassBlueprint" && ref.Name == d.gwcb.Name {
+			classes[gwc.Name] = true
+		}
+	}
+
+	var gwList gatewayapi.GatewayList
+	if err := d.client.List(ctx, &gwList); err != nil {
+		return nil, fmt.Errorf("cannot list Gateways: %w", err)
+	}
+	changes := []ChildChange{}
+	var errs []error
+	gateways := map[client.ObjectKey]bool{}
+	for idx := range gwList.Items {
+		gw := &gwList.Items[idx]
+		if !classes[string(gw.Spec.GatewayClassName)] {
+			continue
+		}
+		gateways[client.ObjectKeyFromObject(gw)] = true
+		parent := fmt.Sprintf("Gateway %s/%s", gw.Namespace, gw.Name)
+		result, err := renderer.RenderGateway(ctx, gw.Namespace, gw.Name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", parent, err))
+			continue
+		}
+		if result.TemplateErrors != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", parent, result.TemplateErrors))
+		}
+		pruned, err := d.prunedChanges(parent, gw, result.Inventory, result.PrunePolicy, result.Complete)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", parent, err))
+		}
+		changes = append(changes, d.renderedChanges(parent, result)...)
+		changes = append(changes, pruned...)
+	}
+
+	for _, rtType := range availableRouteTypes(d.client.RESTMapper()) {
+		rtList := rtType.NewRouteList()
+		if err := d.client.List(ctx, rtList); err != nil {
+			return nil, fmt.Errorf("cannot list %s: %w", rtType.Kind, err)
+		}
+		routes, err := routeListItems(rtList)
+		if err != nil {
+			return nil, err
+		}
+		for _, rt := range routes {
+			if !hasParentGateway(rt, gateways) {
+				continue
+			}
+			parent := fmt.Sprintf("%s %s/%s", rtType.Kind, rt.GetNamespace(), rt.GetName())
+			results, err := renderer.RenderRoute(ctx, rtType.Kind, rt.GetNamespace(), rt.GetName())
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", parent, err))
+			}
+
+			// The inventory of a route holds the resources rendered for all parents
+			rendered := []InventoryEntry{}
+			var prunePolicy gwcapi.PrunePolicy
+			complete := err == nil
+			for _, result := range results {
+				if result.TemplateErrors != nil {
+					errs = append(errs, fmt.Errorf("%s: gateway %s: %w", parent, result.Gateway, result.TemplateErrors))
+				}
+				changes = append(changes, d.renderedChanges(parent, result)...)
+				rendered = append(rendered, result.Inventory...)
+				prunePolicy = conservativePrunePolicy(prunePolicy, result.PrunePolicy)
+				complete = complete && result.Complete
+			}
+			pruned, err := d.prunedChanges(parent, rt, rendered, prunePolicy, complete)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", parent, err))
+			}
+			changes = append(changes, pruned...)
+		}
+	}
+	return changes, errors.Join(errs...)
+}
+
+// Whether a route references one of the given Gateways as parent
+func hasParentGateway(rt client.Object, gateways map[client.ObjectKey]bool) bool {
+	for _, ref := range routeCommonSpec(rt).ParentRefs {
+		if ref.Kind != nil && *ref.Kind != "Gateway" {
+			continue
+		}
+		key := client.ObjectKey{Namespace: rt.GetNamespace(), Name: string(ref.Name)}
+		if ref.Namespace != nil {
+			key.Namespace = string(*ref.Namespace)
+		}
+		if gateways[key] {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply a rendered resource using server-side dry-run and record the
+// resource in the cluster, if any, and the resource as applied
+func (d *BlueprintDiffer) dryRunApply(ctx context.Context, res *ResourceComposite) (*unstructured.Unstructured, error) {
+	var ns *string
+	if res.IsNamespaced {
+		ns = PtrTo(res.Rendered.GetNamespace())
+	}
+	live, err := lookupChild(ctx, d, res.GVR, ns, res.Rendered.GetName())
+	if err != nil {
+		return nil, err
+	}
+	applied, err := patchUnstructured(ctx, d, res.Rendered, res.GVR, ns, true)
+	if err != nil {
+		return nil, err
+	}
+	d.applied[resourceName(applied.GetKind(), applied.GetNamespace(), applied.GetName())] = appliedChild{live: live, applied: applied}
+	return applied, nil
+}
+
+// Changes to the resources rendered for a parent
+func (d *BlueprintDiffer) renderedChanges(parent string, result *OfflineRenderResult) []ChildChange {
+	changes := []ChildChange{}
+	for _, tmpl := range result.Templates {
+		for _, res := range tmpl.Resources {
+			name := resourceName(res.GetKind(), res.GetNamespace(), res.GetName())
+			child, found := d.applied[name]
+			if !found {
+				continue // Could not be applied
+			}
+			action, diff, changed := childDiff(child.live, child.applied)
+			if !changed {
+				continue
+			}
+			change := ChildChange{Action: action, Parent: parent, Template: tmpl.TemplateName, Resource: name, Diff: diff}
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// Changes to resources in the inventory of a parent which are no longer rendered
+func (d *BlueprintDiffer) prunedChanges(parent string, obj client.Object, rendered []InventoryEntry,
+	policy gwcapi.PrunePolicy, complete bool) ([]ChildChange, error) {
+	existing, err := readInventory(obj)
+	if err != nil {
+		return nil, err
+	}
+	_, stale := updateInventory(existing, rendered)
+	if !complete {
+		return []ChildChange{}, nil
+	}
+	changes := []ChildChange{}
+	for _, e := range stale {
+		if e.Pruned {
+			continue // Already kept due to prune policy
+		}
+		action := ChildChangePrune
+		if policy == gwcapi.PrunePolicyKeep || policy == gwcapi.PrunePolicyOrphan {
+			action = ChildChangeKeep
+		}
+		changes = append(changes, ChildChange{Action: action, Parent: parent, Resource: resourceName(e.Kind, e.Namespace, e.Name)})
+	}
+	return changes, nil
+}
+
+// Difference between a child resource in the cluster, or nil if it
+// does not exist, and as applied. Returns whether the resource would
+// be created or updated and a unified diff of the resources without
+// status and metadata set by the API server
+func childDiff(live, applied *unstructured.Unstructured) (ChildChangeAction, string, bool) {
+	action := ChildChangeUpdate
+	var liveYaml string
+	if live == nil {
+		action = ChildChangeCreate
+	} else {
+		liveYaml = diffableYaml(live)
+	}
+	appliedYaml := diffableYaml(applied)
+	if liveYaml == appliedYaml {
+		return action, "", false
+	}
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(liveYaml),
+		B:        difflib.SplitLines(appliedYaml),
+		FromFile: "live",
+		ToFile:   "applied",
+		Context:  3,
+	})
+	return action, diff, true
+}
+
+// Name of a resource as 'Kind namespace/name' or 'Kind name'
+func resourceName(kind, namespace, name string) string {
+	if namespace == "" {
+		return fmt.Sprintf("%s %s", kind, name)
+	}
+	return fmt.Sprintf("%s %s/%s", kind, namespace, name)
+}
+
+// Resource as YAML without status and metadata set by the API server
+func diffableYaml(u *unstructured.Unstructured) string {
+	u = u.DeepCopy()
+	delete(u.Object, "status")
+	for _, field := range []string{"managedFields", "resourceVersion", "generation", "creationTimestamp", "uid", "selfLink"} {
+		unstructured.RemoveNestedField(u.Object, "metadata", field)
+	}
+	data, err := sigsyaml.Marshal(u.Object)
+	if err != nil {
+		return fmt.Sprintf("%+v\n", u.Object)
+	}
+	return string(data)
+}
@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+)
+
+const diffTestManifests = `
+apiVersion: gateway.networking.k8s.io/v1
+kind: GatewayClass
+metadata:
+  name: gwc
+spec:
+  controllerName: "github.com/tv2-oss/bifrost-gateway-controller"
+  parametersRef:
+    group: gateway.tv2.dk
+    kind: GatewayClassBlueprint
+    name: blueprint
+---
+apiVersion: gateway.tv2.dk/v1alpha1
+kind: GatewayClassBlueprint
+metadata:
+  name: blueprint
+spec:
+  values:
+    default:
+      region: eu-north-1
+  gatewayTemplate:
+    resourceTemplates:
+      config: |
+        apiVersion: v1
+        kind: ConfigMap
+        metadata:
+          name: {{ .Gateway.metadata.name }}-config
+          namespace: {{ .Gateway.metadata.namespace }}
+        data:
+          region: {{ .Values.region }}
+---
+apiVersion: gateway.networking.k8s.io/v1
+kind: Gateway
+metadata:
+  name: gw
+  namespace: default
+  uid: gw-uid
+  annotations:
+    gateway.tv2.dk/inventory: >-
+      [{"version":"v1","resource":"configmaps","kind":"ConfigMap","namespace":"default","name":"gw-config"},
+      {"version":"v1","resource":"configmaps","kind":"ConfigMap","namespace":"default","name":"gw-old"}]
+spec:
+  gatewayClassName: gwc
+  listeners:
+  - name: http
+    port: 80
+    protocol: HTTP
+---
+apiVersion: gateway.networking.k8s.io/v1
+kind: Gateway
+metadata:
+  name: other
+  namespace: default
+  uid: other-uid
+spec:
+  gatewayClassName: other-gwc
+  listeners:
+  - name: http
+    port: 80
+    protocol: HTTP
+`
+
+const diffTestLive = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: gw-config
+  namespace: default
+  resourceVersion: "42"
+data:
+  region: eu-north-1
+`
+
+const diffTestBlueprint = `
+apiVersion: gateway.tv2.dk/v1alpha1
+kind: GatewayClassBlueprint
+metadata:
+  name: blueprint
+spec:
+  values:
+    default:
+      region: eu-west-1
+  gatewayTemplate:
+    resourceTemplates:
+      config: |
+        apiVersion: v1
+        kind: ConfigMap
+        metadata:
+          name: {{ .Gateway.metadata.name }}-config
+          namespace: {{ .Gateway.metadata.namespace }}
+        data:
+          region: {{ .Values.region }}
+      extra: |
+        apiVersion: v1
+        kind: ConfigMap
+        metadata:
+          name: {{ .Gateway.metadata.name }}-extra
+          namespace: {{ .Gateway.metadata.namespace }}
+`
+
+func TestBlueprintDiff(t *testing.T) {
+	defer func(w io.Writer) { RenderDebugOutput = w }(RenderDebugOutput)
+	RenderDebugOutput = io.Discard
+
+	scheme := NewRendererScheme()
+	objs := []client.Object{}
+	for _, u := range helperDecodeManifests(t, diffTestManifests) {
+		obj, err := scheme.New(u.GroupVersionKind())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, obj); err != nil {
+			t.Fatal(err)
+		}
+		objs = append(objs, obj.(client.Object))
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithRESTMapper(newStaticRESTMapper(scheme, nil)).WithObjects(objs...).Build()
+
+	// Server-side apply is not supported by the fake dynamic client,
+	// i.e. the applied resource is the rendered resource
+	dynClient := dynfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{{Version: "v1", Resource: "configmaps"}: "ConfigMapList"},
+		helperDecodeManifests(t, diffTestLive)[0])
+	dynClient.PrependReactor("patch", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		applied := &unstructured.Unstructured{}
+		err := json.Unmarshal(action.(k8stesting.PatchAction).GetPatch(), &applied.Object)
+		return true, applied, err
+	})
+
+	gwcb := &gwcapi.GatewayClassBlueprint{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(helperDecodeManifests(t, diffTestBlueprint)[0].Object, gwcb); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := NewBlueprintDiffer(c, dynClient, gwcb).Diff(context.Background())
+	if err != nil {
+		t.Fatalf("Diff() failed: %v", err)
+	}
+
+	expected := []ChildChange{
+		{Action: ChildChangeUpdate, Parent: "Gateway default/gw", Template: "config", Resource: "ConfigMap default/gw-config"},
+		{Action: ChildChangeCreate, Parent: "Gateway default/gw", Template: "extra", Resource: "ConfigMap default/gw-extra"},
+		{Action: ChildChangePrune, Parent: "Gateway default/gw", Resource: "ConfigMap default/gw-old"},
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("got changes %+v, expected %+v", changes, expected)
+	}
+	for idx, change := range changes {
+		diff := change.Diff
+		change.Diff = ""
+		if change != expected[idx] {
+			t.Errorf("change %d, got %+v, expected %+v", idx, change, expected[idx])
+		}
+		if change.Action == ChildChangeUpdate && !strings.Contains(diff, "-  region: eu-north-1\n+  region: eu-west-1\n") {
+			t.Errorf("unexpected diff:\n%s", diff)
+		}
+	}
+
+	// The blueprint in the cluster is unchanged
+	var live gwcapi.GatewayClassBlueprint
+	if err := c.Get(context.Background(), client.ObjectKey{Name: "blueprint"}, &live); err != nil || live.Spec.Values.Default == nil ||
+		!strings.Contains(string(live.Spec.Values.Default.Raw), "eu-north-1") {
+		t.Errorf("unexpected blueprint %+v, error %v", live.Spec.Values, err)
+	}
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+)
+
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+var (
+	// Whether to run without changing resources, see EnableDryRun
+	DryRun bool
+
+	// Records Events for changes not done in dry-run mode. Nil if Events are not recorded
+	dryRunRecorder record.EventRecorder
+)
+
+// A change not done in dry-run mode. Also used as Event reason with a 'DryRun' prefix
+type dryRunAction string
+
+const (
+	dryRunCreate         dryRunAction = "Create"
+	dryRunUpdate         dryRunAction = "Update"
+	dryRunDelete         dryRunAction = "Delete"
+	dryRunOrphan         dryRunAction = "Orphan"
+	dryRunUpdateStatus   dryRunAction = "UpdateStatus"
+	dryRunUpdateMetadata dryRunAction = "UpdateMetadata"
+)
+
+// Run controllers without changing resources. Child resources are
+// applied using server-side dry-run, and status updates, updates of
+// metadata like inventory and finalizers, pruning and deletion of
+// child resources are skipped. Changes not done are logged, counted
+// in the 'bifrost_dryrun_changes_total' metric and recorded as Events
+// using recorder, if not nil.
+func EnableDryRun(recorder record.EventRecorder) {
+	DryRun = true
+	dryRunRecorder = recorder
+}
+
+// Record a change not done in dry-run mode. Events are recorded on obj,
+// which is the parent for child resources applied, pruned or deleted
+func recordDryRun(ctx context.Context, obj runtime.Object, action dryRunAction, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	log.FromContext(ctx).Info("dry-run, change not done", "action", action, "change", msg)
+	metricDryRunChanges.WithLabelValues(string(action)).Inc()
+	if dryRunRecorder != nil {
+		dryRunRecorder.Event(obj, corev1.EventTypeNormal, "DryRun"+string(action), msg)
+	}
+}
+
+// Record the change from applying a child resource using server-side
+// dry-run, if any. The difference between the resource in the API
+// server and as applied is logged
+func recordDryRunApply(ctx context.Context, parent client.Object, tmplName string, live, applied *unstructured.Unstructured) {
+	action, diff, changed := childDiff(live, applied)
+	if !changed {
+		return
+	}
+	name := resourceName(applied.GetKind(), applied.GetNamespace(), applied.GetName())
+	log.FromContext(ctx).V(1).Info("dry-run, child resource difference", "resource", name, "diff", diff)
+	if action == ChildChangeCreate {
+		recordDryRun(ctx, parent, dryRunCreate, "create %s from template %q", name, tmplName)
+	} else {
+		recordDryRun(ctx, parent, dryRunUpdate, "update %s from template %q", name, tmplName)
+	}
+}
+
+// Record pruning of a child resource no longer rendered
+func recordDryRunPrune(ctx context.Context, parent client.Object, e *InventoryEntry, policy gwcapi.PrunePolicy) {
+	name := resourceName(e.Kind, e.Namespace, e.Name)
+	switch policy {
+	case gwcapi.PrunePolicyKeep:
+		recordDryRun(ctx, parent, dryRunUpdate, "annotate child resource %s as pruned", name)
+	case gwcapi.PrunePolicyOrphan:
+		recordDryRun(ctx, parent, dryRunOrphan, "orphan child resource %s", name)
+	default:
+		recordDryRun(ctx, parent, dryRunDelete, "delete child resource %s", name)
+	}
+}
+
+// Names of the metadata changed between two versions of an object,
+// e.g. 'annotations and finalizers'
+func changedMetadata(obj, updated client.Object) string {
+	changed := []string{}
+	if !equality.Semantic.DeepEqual(obj.GetAnnotations(), updated.GetAnnotations()) {
+		changed = append(changed, "annotations")
+	}
+	if !equality.Semantic.DeepEqual(obj.GetFinalizers(), updated.GetFinalizers()) {
+		changed = append(changed, "finalizers")
+	}
+	if len(changed) == 0 {
+		return "metadata"
+	}
+	return strings.Join(changed, " and ")
+}
+
+// Update the status of an object, unless in dry-run mode
+func updateStatus(ctx context.Context, r ControllerClient, obj client.Object) error {
+	if DryRun {
+		recordDryRun(ctx, obj, dryRunUpdateStatus, "update status")
+		return nil
+	}
+	return r.Client().Status().Update(ctx, obj)
+}
+
+// Lookup a child resource. Returns nil if the resource does not exist
+func lookupChild(ctx context.Context, r ControllerDynClient, gvr *schema.GroupVersionResource, namespace *string,
+	name string) (*unstructured.Unstructured, error) {
+	var resClient dynamic.ResourceInterface = r.DynamicClient().Resource(*gvr)
+	if namespace != nil {
+		resClient = r.DynamicClient().Resource(*gvr).Namespace(*namespace)
+	}
+	current, err := resClient.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	return current, err
+}
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	selfapi "github.com/tv2-oss/bifrost-gateway-controller/pkg/api"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynfake "k8s.io/client-go/dynamic/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+)
+
+func TestDryRun(t *testing.T) {
+	defer func() { DryRun, dryRunRecorder = false, nil }()
+	recorder := record.NewFakeRecorder(10)
+	EnableDryRun(recorder)
+
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = gatewayapi.Install(scheme)
+
+	inventory := `[{"version":"v1","resource":"namespaces","kind":"Namespace","name":"first"}]`
+	gw := &gatewayapi.Gateway{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "gw",
+			Namespace:   "default",
+			UID:         "gw-uid",
+			Finalizers:  []string{selfapi.ChildResourcesFinalizer},
+			Annotations: map[string]string{selfapi.InventoryAnnotation: inventory},
+		},
+	}
+	r := &fakeReconciler{
+		client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(gw).Build(),
+		scheme: scheme,
+		dynClient: dynfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+			map[schema.GroupVersionResource]string{helperNamespaceGVR: "NamespaceList"},
+			helperNamespace("first", "gw-uid")),
+	}
+	exists := func(name string) bool {
+		_, err := r.dynClient.Resource(helperNamespaceGVR).Get(ctx, name, metav1.GetOptions{})
+		return err == nil
+	}
+	events := func() []string {
+		var recorded []string
+		for len(recorder.Events) > 0 {
+			recorded = append(recorded, <-recorder.Events)
+		}
+		return recorded
+	}
+	deletes := testutil.ToFloat64(metricDryRunChanges.WithLabelValues(string(dryRunDelete)))
+
+	// Resources no longer rendered are not pruned and the inventory is not updated
+	if err := reconcileInventory(ctx, r, gw, []InventoryEntry{}, gwcapi.PrunePolicyDelete, true); err != nil {
+		t.Fatalf("reconcileInventory() failed: %v", err)
+	}
+	if !exists("first") {
+		t.Errorf("resource deleted in dry-run mode")
+	}
+	if gw.Annotations[selfapi.InventoryAnnotation] != inventory {
+		t.Errorf("inventory updated in dry-run mode: %q", gw.Annotations[selfapi.InventoryAnnotation])
+	}
+	recorded := events()
+	if len(recorded) != 2 || recorded[0] != "Normal DryRunDelete delete child resource Namespace first" ||
+		recorded[1] != "Normal DryRunUpdateMetadata update annotations" {
+		t.Errorf("unexpected events %q", recorded)
+	}
+	if count := testutil.ToFloat64(metricDryRunChanges.WithLabelValues(string(dryRunDelete))); count != deletes+1 {
+		t.Errorf("unexpected dry-run delete count %v", count-deletes)
+	}
+
+	// Finalizing does not wait for child resources which are not deleted
+	res, err := finalizeParent(ctx, r, gw)
+	if err != nil || res.RequeueAfter != 0 {
+		t.Fatalf("finalizeParent() returned %+v, %v", res, err)
+	}
+	if !exists("first") {
+		t.Errorf("resource deleted in dry-run mode")
+	}
+	var current gatewayapi.Gateway
+	if err := r.client.Get(ctx, client.ObjectKeyFromObject(gw), &current); err != nil || len(current.Finalizers) != 1 {
+		t.Errorf("finalizer removed in dry-run mode: %+v, %v", current.Finalizers, err)
+	}
+	recorded = events()
+	if len(recorded) != 2 || !strings.HasPrefix(recorded[0], "Normal DryRunDelete") ||
+		recorded[1] != "Normal DryRunUpdateMetadata update finalizers" {
+		t.Errorf("unexpected events %q", recorded)
+	}
+
+	// Status is not updated
+	gw.Status.Conditions = []metav1.Condition{{Type: "Accepted", Status: metav1.ConditionTrue, Reason: "Accepted"}}
+	if err := updateStatus(ctx, r, gw); err != nil {
+		t.Fatalf("updateStatus() failed: %v", err)
+	}
+	if err := r.client.Get(ctx, client.ObjectKeyFromObject(gw), &current); err != nil || len(current.Status.Conditions) != 0 {
+		t.Errorf("status updated in dry-run mode: %+v, %v", current.Status, err)
+	}
+	if recorded = events(); len(recorded) != 1 || recorded[0] != "Normal DryRunUpdateStatus update status" {
+		t.Errorf("unexpected events %q", recorded)
+	}
+}
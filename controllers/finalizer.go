@@ -0,0 +1,175 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	selfapi "github.com/tv2-oss/bifrost-gateway-controller/pkg/api"
+)
+
+// Used to requeue while waiting for cluster-scoped child resources to be deleted
+var childDeletionRequeuePeriod = 5 * time.Second
+
+// Add our finalizer to a parent resource such that cluster-scoped
+// child resources, which cannot be garbage collected through owner
+// references, can be deleted before the parent
+func ensureFinalizer(ctx context.Context, r ControllerClient, parent client.Object) error {
+	if controllerutil.ContainsFinalizer(parent, selfapi.ChildResourcesFinalizer) {
+		return nil
+	}
+	return patchParentMetadata(ctx, r, parent, func(obj client.Object) {
+		controllerutil.AddFinalizer(obj, selfapi.ChildResourcesFinalizer)
+	})
+}
+
+// Handle deletion of a parent resource. Cluster-scoped child
+// resources are deleted one at a time in reverse order of creation
+// and the finalizer is released when all are gone.
+func finalizeParent(ctx context.Context, r ControllerDynClient, parent client.Object) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	if !controllerutil.ContainsFinalizer(parent, selfapi.ChildResourcesFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	children, err := clusterScopedChildren(ctx, r, parent)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	for idx := range children {
+		e := &children[idx]
+		current, err := inventoryResourceClient(r, e).Get(ctx, e.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return ctrl.Result{}, fmt.Errorf("cannot lookup child resource %s: %w", e.String(), err)
+		}
+		if current.GetLabels()[selfapi.ParentUIDLabel] != string(parent.GetUID()) {
+			continue // Orphaned or taken over by another parent
+		}
+		if DryRun {
+			// Nothing is deleted, i.e. there is nothing to wait for
+			if current.GetDeletionTimestamp() == nil {
+				recordDryRun(ctx, parent, dryRunDelete, "delete child resource %s", resourceName(e.Kind, e.Namespace, e.Name))
+			}
+			continue
+		}
+		if current.GetDeletionTimestamp() == nil {
+			propagation := metav1.DeletePropagationBackground
+			err = inventoryResourceClient(r, e).Delete(ctx, e.Name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+			if err != nil && !apierrors.IsNotFound(err) {
+				metricPruneErrs.Inc()
+				return ctrl.Result{}, fmt.Errorf("cannot delete child resource %s: %w", e.String(), err)
+			}
+			logger.Info("deleted child resource", "resource", e.String())
+			metricPrune.Inc()
+		}
+		// Wait for the resource to be gone before deleting resources it may depend on
+		logger.Info("waiting for child resource deletion", "resource", e.String())
+		return ctrl.Result{RequeueAfter: childDeletionRequeuePeriod}, nil
+	}
+
+	if err := patchParentMetadata(ctx, r, parent, func(obj client.Object) {
+		controllerutil.RemoveFinalizer(obj, selfapi.ChildResourcesFinalizer)
+	}); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	return ctrl.Result{}, nil
+}
+
+// Return cluster-scoped child resources of a parent in the order
+// they should be deleted. Resources from the inventory are returned
+// in reverse order of creation. Resources labelled with the parent UID
+// but missing from the inventory, e.g. because the inventory could not
+// be updated, are returned first.
+func clusterScopedChildren(ctx context.Context, r ControllerDynClient, parent client.Object) ([]InventoryEntry, error) {
+	inventory, err := readInventory(parent)
+	if err != nil {
+		return nil, err
+	}
+
+	ordered := []InventoryEntry{}
+	resources := []InventoryEntry{}
+	seen := map[schema.GroupResource]bool{}
+	for idx := len(inventory) - 1; idx >= 0; idx-- {
+		e := inventory[idx]
+		if e.Namespace != "" {
+			continue
+		}
+		ordered = append(ordered, e)
+		gr := schema.GroupResource{Group: e.Group, Resource: e.Resource}
+		if !seen[gr] {
+			seen[gr] = true
+			resources = append(resources, e)
+		}
+	}
+
+	untracked := []InventoryEntry{}
+	selector := fmt.Sprintf("%s=%s", selfapi.ParentUIDLabel, parent.GetUID())
+	for idx := range resources {
+		res := &resources[idx]
+		list, err := inventoryResourceClient(r, res).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return nil, fmt.Errorf("cannot list child resources of type %s: %w", res.GVR().GroupResource(), err)
+		}
+		for _, item := range list.Items {
+			e := InventoryEntry{Group: res.Group, Version: res.Version, Resource: res.Resource, Kind: item.GetKind(), Name: item.GetName()}
+			if !containsInventoryEntry(ordered, &e) {
+				untracked = append(untracked, e)
+			}
+		}
+	}
+
+	return append(untracked, ordered...), nil
+}
+
+func containsInventoryEntry(entries []InventoryEntry, e *InventoryEntry) bool {
+	for idx := range entries {
+		if entries[idx].sameResource(e) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	selfapi "github.com/tv2-oss/bifrost-gateway-controller/pkg/api"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	dynfake "k8s.io/client-go/dynamic/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+type fakeReconciler struct {
+	client    client.Client
+	scheme    *runtime.Scheme
+	dynClient dynamic.Interface
+}
+
+func (r *fakeReconciler) Client() client.Client            { return r.client }
+func (r *fakeReconciler) Scheme() *runtime.Scheme          { return r.scheme }
+func (r *fakeReconciler) DynamicClient() dynamic.Interface { return r.dynClient }
+
+var helperNamespaceGVR = schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+
+func helperNamespace(name, parentUID string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("v1")
+	u.SetKind("Namespace")
+	u.SetName(name)
+	if parentUID != "" {
+		u.SetLabels(map[string]string{selfapi.ParentUIDLabel: parentUID})
+	}
+	return u
+}
+
+func TestFinalizeParent(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = gatewayapi.Install(scheme)
+
+	gw := &gatewayapi.Gateway{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "gw",
+			Namespace:  "default",
+			UID:        "gw-uid",
+			Finalizers: []string{selfapi.ChildResourcesFinalizer},
+			Annotations: map[string]string{
+				selfapi.InventoryAnnotation: `[{"version":"v1","resource":"namespaces","kind":"Namespace","name":"first"},` +
+					`{"version":"v1","resource":"configmaps","kind":"ConfigMap","namespace":"default","name":"cm"},` +
+					`{"version":"v1","resource":"namespaces","kind":"Namespace","name":"second"}]`,
+			},
+		},
+	}
+	r := &fakeReconciler{
+		client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(gw).Build(),
+		scheme: scheme,
+		dynClient: dynfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+			map[schema.GroupVersionResource]string{helperNamespaceGVR: "NamespaceList"},
+			helperNamespace("first", "gw-uid"), helperNamespace("second", "gw-uid"),
+			helperNamespace("untracked", "gw-uid"), helperNamespace("unrelated", "other-uid")),
+	}
+
+	exists := func(name string) bool {
+		_, err := r.dynClient.Resource(helperNamespaceGVR).Get(ctx, name, metav1.GetOptions{})
+		return err == nil
+	}
+
+	// Untracked resources first, then reverse order of creation, one per reconcile
+	order := []string{"untracked", "second", "first"}
+	for idx, name := range order {
+		res, err := finalizeParent(ctx, r, gw)
+		if err != nil {
+			t.Fatalf("Finalize error: %v", err)
+		}
+		if res.RequeueAfter == 0 {
+			t.Fatalf("Expected requeue while deleting %v", name)
+		}
+		if exists(name) {
+			t.Fatalf("Expected %v to be deleted", name)
+		}
+		for _, remaining := range order[idx+1:] {
+			if !exists(remaining) {
+				t.Fatalf("Expected %v to be deleted after %v", remaining, name)
+			}
+		}
+	}
+	if !exists("unrelated") {
+		t.Fatalf("Unrelated resource deleted")
+	}
+
+	res, err := finalizeParent(ctx, r, gw)
+	if err != nil || res.RequeueAfter != 0 {
+		t.Fatalf("Finalize, got %+v, %v", res, err)
+	}
+	if len(gw.GetFinalizers()) != 0 {
+		t.Fatalf("Finalizer not removed, got %v", gw.GetFinalizers())
+	}
+}
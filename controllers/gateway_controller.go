@@ -33,23 +33,31 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"sort"
 	"strings"
 	"time"
 
-	"github.com/mitchellh/mapstructure"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1b1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+	selfapi "github.com/tv2-oss/bifrost-gateway-controller/pkg/api"
 )
 
 // Used to requeue when a resource is missing a dependency
@@ -60,11 +68,18 @@ type GatewayReconciler struct {
 	client    client.Client
 	scheme    *runtime.Scheme
 	dynClient dynamic.Interface
+	watches   *childWatches
+
+	// Route kinds served by the API server
+	routeTypes []*routeType
 }
 
 //+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=gateways,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=gateways/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=gateways/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=configmaps;secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=referencegrants,verbs=get;list;watch
 
 func (r *GatewayReconciler) Client() client.Client {
 	return r.client
@@ -80,17 +95,58 @@ func (r *GatewayReconciler) DynamicClient() dynamic.Interface {
 
 func NewGatewayController(mgr ctrl.Manager, config *rest.Config) *GatewayReconciler {
 	r := &GatewayReconciler{
-		client:    mgr.GetClient(),
-		scheme:    mgr.GetScheme(),
-		dynClient: dynamic.NewForConfigOrDie(config),
+		client:     mgr.GetClient(),
+		scheme:     mgr.GetScheme(),
+		dynClient:  dynamic.NewForConfigOrDie(config),
+		routeTypes: availableRouteTypes(mgr.GetRESTMapper()),
 	}
 	return r
 }
 
+func (r *GatewayReconciler) watchChildKind(gvk schema.GroupVersionKind) error {
+	return r.watches.watchChildKind(gvk)
+}
+
 func (r *GatewayReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&gatewayapi.Gateway{}).
-		Complete(r)
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&gatewayapi.Gateway{})
+	for _, rtType := range r.routeTypes {
+		// Attached routes affect e.g. hostnames, route status does not affect Gateways
+		b = b.Watches(rtType.NewRoute(), handler.EnqueueRequestsFromMapFunc(mapRouteToGateways),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}))
+	}
+	c, err := b.
+		Watches(&gatewayapi.GatewayClass{}, handler.EnqueueRequestsFromMapFunc(mapGatewayClassToGateways(mgr.GetClient()))).
+		Watches(&gwcapi.GatewayClassBlueprint{}, handler.EnqueueRequestsFromMapFunc(mapBlueprintToGateways(mgr.GetClient()))).
+		Watches(&gwcapi.GatewayClassConfig{}, handler.EnqueueRequestsFromMapFunc(mapPolicyToGateways(mgr.GetClient())),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&gwcapi.GatewayConfig{}, handler.EnqueueRequestsFromMapFunc(mapPolicyToGateways(mgr.GetClient())),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		// Values may be sourced from ConfigMaps and Secrets
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(
+			mapValuesFromReferrers(mgr.GetClient(), gwcapi.ValuesFromConfigMap, r.mapValuesReferrer))).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(
+			mapValuesFromReferrers(mgr.GetClient(), gwcapi.ValuesFromSecret, r.mapValuesReferrer))).
+		// Namespace labels affect which routes attach to listeners
+		Watches(&corev1.Namespace{}, handler.EnqueueRequestsFromMapFunc(mapNamespaceToGateways(mgr.GetClient())),
+			builder.WithPredicates(predicate.LabelChangedPredicate{})).
+		Watches(&gatewayv1b1.ReferenceGrant{}, handler.EnqueueRequestsFromMapFunc(
+			mapReferenceGrantToReferrers(mgr.GetClient(), gatewayapi.GroupName, "Gateway",
+				func() client.ObjectList { return &gatewayapi.GatewayList{} }))).
+		Build(r)
+	if err != nil {
+		return err
+	}
+	r.watches = newChildWatches(c, mgr.GetCache(), "Gateway")
+	return nil
+}
+
+// Map a blueprint or policy using a ConfigMap or Secret for values to Gateways
+func (r *GatewayReconciler) mapValuesReferrer(referrer client.Object) handler.MapFunc {
+	if _, ok := referrer.(*gwcapi.GatewayClassBlueprint); ok {
+		return mapBlueprintToGateways(r.Client())
+	}
+	return mapPolicyToGateways(r.Client())
 }
 
 func (r *GatewayReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -107,6 +163,10 @@ func (r *GatewayReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 
 	logger.Info("Gateway")
 
+	if !gw.DeletionTimestamp.IsZero() {
+		return finalizeParent(ctx, r, &gw)
+	}
+
 	gwc, err := lookupGatewayClass(ctx, r, gw.Spec.GatewayClassName)
 	if err != nil {
 		return ctrl.Result{RequeueAfter: dependencyMissingRequeuePeriod}, client.IgnoreNotFound(err)
@@ -121,12 +181,16 @@ func (r *GatewayReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		return ctrl.Result{RequeueAfter: dependencyMissingRequeuePeriod}, fmt.Errorf("parameters for GatewayClass %q not found: %w", gwc.ObjectMeta.Name, err)
 	}
 
-	routes, err := lookupHTTPRoutes(ctx, r)
+	routes, err := lookupRoutes(ctx, r, r.routeTypes)
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("cannot look up routes: %w", err)
 	}
-	gwRoutes := filterHTTPRoutesForGateway(&gw, routes)
-	union, isect := combineHostnames(&gw, gwRoutes)
+	attacher, err := newGatewayAttacher(ctx, r, &gw, gwcb, r.routeTypes)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	attachment := attacher.attachRoutes(routes)
+	union, isect := combineHostnames(&gw, attachment.Hostnames)
 
 	// Prepare Gateway resource for use in templates by converting to map[string]any
 	gatewayMap, err := objectToMap(&gw)
@@ -134,15 +198,58 @@ func (r *GatewayReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		return ctrl.Result{}, fmt.Errorf("cannot convert gateway to map: %w", err)
 	}
 
-	values, err := lookupValues(ctx, r, gwc.Name, gwcb, gw.ObjectMeta.Namespace, gw.ObjectMeta.Name)
+	values, sources, err := lookupValuesWithSources(ctx, r, gwc.Name, gwcb, gw.ObjectMeta.Namespace, gw.ObjectMeta.Name, nil)
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("cannot lookup values: %w", err)
 	}
 
+	// Record the blueprint and policies which supplied values, also when values are invalid
+	if err := writeValuesSources(ctx, r, &gw, sources); err != nil {
+		return ctrl.Result{}, fmt.Errorf("cannot update values sources: %w", err)
+	}
+
+	// Values must be valid according to the values schema of the
+	// blueprint. Invalid values require a change of policies or
+	// the blueprint, i.e. no requeue
+	violations, err := validateValuesSchema(gwcb.Spec.ValuesSchema, values, sources)
+	if err == nil {
+		err = violationsError(violations)
+	}
+	if err != nil {
+		logger.Info("invalid values", "error", err)
+		beforeStatusUpdate := gw.DeepCopy()
+		meta.SetStatusCondition(&gw.Status.Conditions, metav1.Condition{
+			Type:               string(gatewayapi.GatewayConditionAccepted),
+			Status:             metav1.ConditionFalse,
+			Reason:             string(gatewayapi.GatewayReasonInvalidParameters),
+			Message:            err.Error(),
+			ObservedGeneration: gw.ObjectMeta.Generation})
+		if !equality.Semantic.DeepEqual(beforeStatusUpdate.Status, gw.Status) {
+			if err := updateStatus(ctx, r, &gw); err != nil {
+				logger.Error(err, "unable to update Gateway status")
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// Only certificates permitted by ReferenceGrants are available to templates
+	grants, err := lookupReferenceGrants(ctx, r)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	certRefs := grants.resolveCertificateRefs(&gw)
+	resolvedCertRefs, err := certRefs.templateValue()
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
 	// Setup template variables context
 	templateValues := TemplateValues{
-		Gateway: &gatewayMap,
-		Values:  values,
+		Gateway:                 &gatewayMap,
+		Values:                  values,
+		sensitiveValues:         sensitiveValues(values, sources),
+		ResolvedCertificateRefs: resolvedCertRefs,
 		Hostnames: TemplateHostnameValues{
 			Union:        union,
 			Intersection: isect,
@@ -153,88 +260,84 @@ func (r *GatewayReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("cannot parse templates: %w", err)
 	}
+	templates, err = sortTemplates(templates, gwcb.Spec.GatewayTemplate.TemplateOptions)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("cannot resolve template dependencies: %w", err)
+	}
+
+	// Cluster-scoped child resources are deleted through our finalizer
+	if err := ensureFinalizer(ctx, r, &gw); err != nil {
+		return ctrl.Result{}, fmt.Errorf("cannot add finalizer: %w", err)
+	}
 
 	// At this point we are ready to accept the Gateway resource. If we encounter errors we track then in this variable
 	var errStatus error
 
-	// Resource templates may reference each other, with the
-	// worst-case being a strictly linear DAG. This means that we
-	// may have to loop N times, with N being the number of
-	// resources.
-	var renderedNum, existsNum int
-	for attempt := 0; attempt < len(templates); attempt++ {
-		logger.Info("reconcile loop", "attempt", attempt)
-		isFinalAttempt := attempt == len(templates)-1
-
-		templateValues.Resources = buildResourceValues(templates)
+	// Render and apply templates in dependency order
+	renderResult, err := renderAndApplyTemplates(ctx, r, &gw, templates, &templateValues)
+	if err != nil {
+		errStatus = fmt.Errorf("unable to apply templates: %w", err)
+	}
 
-		renderedNum, existsNum = renderTemplates(ctx, r, &gw, templates, &templateValues, isFinalAttempt)
-		logger.Info("Rendered", "rendered", renderedNum, "exists", existsNum)
+	requeue = (renderResult.Rendered != len(templates))
+	logger.Info("rendering done", "renderedNum", renderResult.Rendered, "existsNum", renderResult.Exists, "totalNum", len(templates), "requeue", requeue)
 
-		if err = applyTemplates(ctx, r, &gw, templates); err != nil {
-			errStatus = fmt.Errorf("unable to apply templates: %w", err)
-		}
+	// Track child resources and prune resources no longer rendered
+	complete := !requeue && errStatus == nil
+	if err = reconcileInventory(ctx, r, &gw, templatesToInventory(templates, gw.Namespace), gwcb.Spec.PrunePolicy, complete); err != nil {
+		errStatus = errors.Join(errStatus, fmt.Errorf("unable to reconcile inventory: %w", err))
 	}
 
-	requeue = (renderedNum != len(templates))
-	logger.Info("ending reconcile loop", "renderedNum", renderedNum, "totalNum", len(templates), "requeue", requeue)
-
 	beforeStatusUpdate := gw.DeepCopy()
 
-	// Update status.addresses field
-	tmplStr, found := gwcb.Spec.GatewayTemplate.Status["template"]
-	statusUpdateOK := true
-	if found {
-		statusUpdateOK = false
-		templateValues.Resources = buildResourceValues(templates) // Needed in case of a single-pass render loop above
-		if tmpl, errs := parseSingleTemplate("status", tmplStr); errs != nil {
-			logger.Info("unable to parse status template", "temporary error", errs)
-		} else {
-			if statusMap, errs := template2maps(tmpl, &templateValues); errs != nil {
-				logger.Info("unable to render status template", "temporary error", errs, "template", tmplStr, "values", templateValues)
-			} else {
-				gw.Status.Addresses = []gatewayapi.GatewayStatusAddress{}
-				_, found := statusMap[0]["addresses"] // FIXME, more addresses?
-				if found {
-					addresses := statusMap[0]["addresses"]
-					if errs := mapstructure.Decode(addresses, &gw.Status.Addresses); errs != nil {
-						// This is probably not a temporary error
-						logger.Error(errs, "unable to decode status data")
-					} else {
-						statusUpdateOK = true
-					}
-				}
-			}
-		}
+	statusErr, err := updateGatewayStatus(&gw, gwcb, templates, renderResult, attacher.kinds, attachment, certRefs, &templateValues)
+	if err != nil {
+		logger.Error(err, "unable to update status condition due to sub-resource status error")
+		return ctrl.Result{}, err
 	}
-	if !statusUpdateOK {
-		requeue = true
+	if statusErr != nil {
+		logger.Info("unable to render status templates", "error", statusErr)
+		// Invalid status requires a change of the GatewayClassBlueprint, other errors are typically temporary
+		if !errors.Is(statusErr, errInvalidStatus) {
+			requeue = true
+		}
 	}
 
-	// TODO: Consider if we can set listener status conditions calculated from child resources
-	for _, listener := range gw.Spec.Listeners {
-		var status *gatewayapi.ListenerStatus
-		for idx := range gw.Status.Listeners { // Locate existing status
-			if gw.Status.Listeners[idx].Name == listener.Name {
-				break
-			}
+	if !equality.Semantic.DeepEqual(beforeStatusUpdate.Status, gw.Status) {
+		if err := updateStatus(ctx, r, &gw); err != nil {
+			logger.Error(err, "unable to update Gateway status")
+			return ctrl.Result{}, err
 		}
-		if status == nil {
-			status = &gatewayapi.ListenerStatus{ // Existing not found, create new
-				Name: listener.Name,
-				SupportedKinds: []gatewayapi.RouteGroupKind{{
-					Group: (*gatewayapi.Group)(&gatewayapi.GroupVersion.Group),
-					Kind:  gatewayapi.Kind("HTTPRoute"),
-				}},
-				AttachedRoutes: int32(len(gwRoutes)), // FIXME, not necessarily correct
-			}
+	}
+
+	if requeue {
+		logger.Info("requeue - not all resources updated")
+		return ctrl.Result{RequeueAfter: dependencyMissingRequeuePeriod}, nil
+	}
+	return ctrl.Result{}, errStatus
+}
+
+// Update Gateway status from the outcome of rendering templates, from
+// status templates and from routes attached to listeners. Returns the
+// error from rendering status templates, if any, and an error if the
+// status of child resources cannot be computed.
+func updateGatewayStatus(gw *gatewayapi.Gateway, gwcb *gwcapi.GatewayClassBlueprint, templates []*ResourceTemplateState,
+	renderResult *RenderResult, kinds map[gatewayapi.SectionName][]gatewayapi.RouteGroupKind, attachment *gatewayAttachment,
+	certRefs *resolvedCertificateRefs, templateValues *TemplateValues) (statusErr, err error) {
+	// Update status from status templates, e.g. addresses and listener conditions from the status of child resources
+	statusErr = updateGatewayStatusFromTemplates(gw, &gwcb.Spec.GatewayTemplate.Status, templateValues)
+	listenerConditions := map[gatewayapi.SectionName][]metav1.Condition{}
+	if tmplStr := gwcb.Spec.GatewayTemplate.ListenerStatus; tmplStr != "" {
+		if listenerConditions, err = renderListenerConditions(tmplStr, gw, templateValues); err != nil {
+			statusErr = errors.Join(statusErr, err)
 		}
-		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
-			Type:               string(gatewayapi.ListenerConditionAccepted),
-			Status:             metav1.ConditionTrue,
-			Reason:             string(gatewayapi.ListenerReasonAccepted),
-			ObservedGeneration: gw.ObjectMeta.Generation})
 	}
+	if hasStatusTemplates(&gwcb.Spec.GatewayTemplate) {
+		meta.SetStatusCondition(&gw.Status.Conditions, statusRenderedCondition(statusErr, gw.ObjectMeta.Generation))
+	} else {
+		meta.RemoveStatusCondition(&gw.Status.Conditions, selfapi.ConditionStatusRendered)
+	}
+	statusUpdateOK := statusErr == nil
 
 	// Gateway was accepted as 'ours'
 	meta.SetStatusCondition(&gw.Status.Conditions, metav1.Condition{
@@ -245,30 +348,17 @@ func (r *GatewayReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 
 	// Consider Gateway as 'programmed' when all resources have
 	// been templated and applied
-	progStatus := metav1.ConditionFalse
-	progReason := "Pending"
-	progMsg := ""
-	if existsNum == len(templates) { // 'Programmed' relates to templates alone
-		progStatus = metav1.ConditionTrue
-		progReason = string(gatewayapi.GatewayReasonProgrammed)
-	} else {
-		missing := statusExistingTemplates(templates)
-		sort.Strings(missing)
-		progMsg = fmt.Sprintf("missing %v resources: %s", len(templates)-existsNum, strings.Join(missing, ","))
-	}
-	meta.SetStatusCondition(&gw.Status.Conditions, metav1.Condition{
-		Type:               string(gatewayapi.GatewayConditionProgrammed),
-		Status:             progStatus,
-		Reason:             progReason,
-		Message:            progMsg,
-		ObservedGeneration: gw.ObjectMeta.Generation})
+	progCond := programmedCondition(templates, renderResult, gw.ObjectMeta.Generation)
+	progStatus := progCond.Status
+	meta.SetStatusCondition(&gw.Status.Conditions, progCond)
+
+	updateListenerStatus(gw, kinds, attachment, certRefs, progStatus == metav1.ConditionTrue, listenerConditions)
 
 	// Set `Ready` condition based on child resource statuses, status update and programmed status
 	status := metav1.ConditionFalse
 	isReady, err := statusIsReady(templates)
 	if err != nil {
-		logger.Error(err, "unable to update status condition due to sub-resource status error")
-		return ctrl.Result{}, err
+		return statusErr, err
 	}
 	if isReady && statusUpdateOK && progStatus == metav1.ConditionTrue {
 		status = metav1.ConditionTrue
@@ -280,18 +370,7 @@ func (r *GatewayReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		Reason:             string(gatewayapi.GatewayReasonReady),
 		ObservedGeneration: gw.ObjectMeta.Generation})
 
-	if !equality.Semantic.DeepEqual(beforeStatusUpdate.Status, gw.Status) {
-		if err := r.Client().Status().Update(ctx, &gw); err != nil {
-			logger.Error(err, "unable to update Gateway status")
-			return ctrl.Result{}, err
-		}
-	}
-
-	if requeue {
-		logger.Info("requeue - not all resources updated")
-		return ctrl.Result{RequeueAfter: dependencyMissingRequeuePeriod}, nil
-	}
-	return ctrl.Result{}, errStatus
+	return statusErr, nil
 }
 
 // Calculate union and intersection of Hostnames for use in templates.
@@ -300,8 +379,9 @@ func (r *GatewayReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 // HTTPRoute with 'foo.example.com'. We may want to create a TLS
 // certificate using '*.example.com' (intersection) and not
 // 'foo.example.com' (union). Calculating both allows template authors
-// to choose which to use.
-func combineHostnames(gw *gatewayapi.Gateway, rtList []*gatewayapi.HTTPRoute) (union, isect []string) {
+// to choose which to use. Route hostnames are those of attached routes
+// restricted to the hostnames of the listeners, see gatewayAttachment.
+func combineHostnames(gw *gatewayapi.Gateway, rtHostnames []gatewayapi.Hostname) (union, isect []string) {
 	wildcards := sets.New[string]() // Wildcard hostnames without '*.' prefix
 	hostnames := sets.New[string]() // Non-wildcard hostnames
 
@@ -322,10 +402,8 @@ func combineHostnames(gw *gatewayapi.Gateway, rtList []*gatewayapi.HTTPRoute) (u
 			addHostname(string(*l.Hostname))
 		}
 	}
-	for _, rt := range rtList {
-		for _, rtHostname := range rt.Spec.Hostnames {
-			addHostname(string(rtHostname))
-		}
+	for _, rtHostname := range rtHostnames {
+		addHostname(string(rtHostname))
 	}
 
 	for hostname := range wildcards { // Unique wildcards goes in both union and intersection
@@ -342,42 +420,55 @@ func combineHostnames(gw *gatewayapi.Gateway, rtList []*gatewayapi.HTTPRoute) (u
 	return union, isect
 }
 
-// Match HTTPRoutes against Gateway listeners, return valid HTTPRoute matches
-func filterHTTPRoutesForGateway(gw *gatewayapi.Gateway, rtList []*gatewayapi.HTTPRoute) []*gatewayapi.HTTPRoute {
-	rtOut := make([]*gatewayapi.HTTPRoute, 0, len(rtList))
-	for _, rt := range rtList {
-		for _, pRef := range rt.Spec.ParentRefs {
-			if (pRef.Group != nil && *pRef.Group != gatewayapi.Group(gatewayapi.GroupName)) ||
-				(pRef.Kind != nil && *pRef.Kind != gatewayapi.Kind("Gateway")) ||
-				(pRef.Namespace != nil && *pRef.Namespace != gatewayapi.Namespace(gw.ObjectMeta.Namespace)) ||
-				// Unspecified namespace means use HTTPRoute namespace
-				(pRef.Namespace == nil && rt.ObjectMeta.Namespace != gw.ObjectMeta.Namespace) ||
-				(pRef.Name != gatewayapi.ObjectName(gw.ObjectMeta.Name)) {
-				// Skip as ParentRef does not refer to Gateway
-				continue
-			}
-			// FIXME: Also include SectionName and Port in match
-			// for _, l := range gw.Spec.Listeners {
-			// 	if xxx
-			// }
-			rtOut = append(rtOut, rt)
+// Lookup all routes of the given kinds
+func lookupRoutes(ctx context.Context, r ControllerClient, rtTypes []*routeType) ([]client.Object, error) {
+	rtOut := []client.Object{}
+	for _, rtType := range rtTypes {
+		rtList := rtType.NewRouteList()
+		if err := r.Client().List(ctx, rtList); err != nil {
+			return nil, err
+		}
+		routes, err := routeListItems(rtList)
+		if err != nil {
+			return nil, err
 		}
+		rtOut = append(rtOut, routes...)
 	}
-	return rtOut
+	return rtOut, nil
 }
 
-// Lookup all HTTPRoutes
-func lookupHTTPRoutes(ctx context.Context, r ControllerClient) ([]*gatewayapi.HTTPRoute, error) {
-	var rtList gatewayapi.HTTPRouteList
-
-	if err := r.Client().List(ctx, &rtList); err != nil {
-		return nil, err
-	}
-
-	// Return pointer slice
-	rtOut := make([]*gatewayapi.HTTPRoute, 0, len(rtList.Items))
-	for idx := range rtList.Items {
-		rtOut = append(rtOut, &rtList.Items[idx])
+// Route kinds supported by a listener, i.e. route kinds which are
+// compatible with the listener protocol, for which the
+// GatewayClassBlueprint has templates and which are served by the API
+// server. If the listener restricts route kinds, only kinds allowed by
+// the listener are included.
+func supportedKinds(listener *gatewayapi.Listener, gwcb *gwcapi.GatewayClassBlueprint, rtTypes []*routeType) []gatewayapi.RouteGroupKind {
+	kinds := []gatewayapi.RouteGroupKind{}
+	for _, rtType := range rtTypes {
+		compatible := false
+		for _, kind := range protocolRouteKinds[listener.Protocol] {
+			compatible = compatible || kind == rtType.Kind
+		}
+		if !compatible || len(rtType.Template(&gwcb.Spec).ResourceTemplates) == 0 {
+			continue
+		}
+		if listener.AllowedRoutes != nil && len(listener.AllowedRoutes.Kinds) > 0 {
+			allowed := false
+			for _, rgk := range listener.AllowedRoutes.Kinds {
+				group := gatewayapi.GroupName
+				if rgk.Group != nil {
+					group = string(*rgk.Group)
+				}
+				allowed = allowed || (group == rtType.GroupVersion.Group && string(rgk.Kind) == rtType.Kind)
+			}
+			if !allowed {
+				continue
+			}
+		}
+		kinds = append(kinds, gatewayapi.RouteGroupKind{
+			Group: PtrTo(gatewayapi.Group(rtType.GroupVersion.Group)),
+			Kind:  gatewayapi.Kind(rtType.Kind),
+		})
 	}
-	return rtOut, nil
+	return kinds
 }
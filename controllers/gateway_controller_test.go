@@ -39,7 +39,11 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+	selfapi "github.com/tv2-oss/bifrost-gateway-controller/pkg/api"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -436,3 +440,339 @@ func setGatewayStatus(nn types.NamespacedName, newCondition *metav1.Condition, a
 	}
 	return nil
 }
+
+const pruneTestGatewayClassManifest string = `
+apiVersion: gateway.networking.k8s.io/v1beta1
+kind: GatewayClass
+metadata:
+  name: prune-test
+spec:
+  controllerName: "github.com/tv2-oss/bifrost-gateway-controller"
+  parametersRef:
+    group: gateway.tv2.dk
+    kind: GatewayClassBlueprint
+    name: prune-test`
+
+const pruneTestGatewayManifest string = `
+apiVersion: gateway.networking.k8s.io/v1beta1
+kind: Gateway
+metadata:
+  name: prune-test
+  namespace: default
+spec:
+  gatewayClassName: prune-test
+  listeners:
+  - name: prod-web
+    port: 80
+    protocol: HTTP
+`
+
+const pruneTestGatewayClassBlueprintManifest string = `
+apiVersion: gateway.tv2.dk/v1alpha1
+kind: GatewayClassBlueprint
+metadata:
+  name: prune-test
+spec:
+  values:
+    default:
+      optionalEnabled: true
+  gatewayTemplate:
+    resourceTemplates:
+      configMapPermanent: |
+        apiVersion: v1
+        kind: ConfigMap
+        metadata:
+          name: {{ .Gateway.metadata.name }}-permanent
+          namespace: {{ .Gateway.metadata.namespace }}
+      configMapOptional: |
+        {{ if .Values.optionalEnabled }}
+        apiVersion: v1
+        kind: ConfigMap
+        metadata:
+          name: {{ .Gateway.metadata.name }}-optional
+          namespace: {{ .Gateway.metadata.namespace }}
+        {{ end }}
+`
+
+var _ = Describe("Gateway controller pruning", func() {
+
+	const (
+		timeout  = time.Second * 15
+		interval = time.Millisecond * 250
+	)
+
+	var (
+		gwc  *gatewayapi.GatewayClass
+		gwcb *gwcapi.GatewayClassBlueprint
+		gw   *gatewayapi.Gateway
+		ctx  context.Context
+	)
+
+	BeforeEach(func() {
+		gwc = &gatewayapi.GatewayClass{}
+		gwcb = &gwcapi.GatewayClassBlueprint{}
+		gw = &gatewayapi.Gateway{}
+		ctx = context.Background()
+		Expect(yaml.Unmarshal([]byte(pruneTestGatewayClassManifest), gwc)).To(Succeed())
+		Expect(k8sClient.Create(ctx, gwc)).Should(Succeed())
+		Expect(yaml.Unmarshal([]byte(pruneTestGatewayClassBlueprintManifest), gwcb)).To(Succeed())
+		Expect(k8sClient.Create(ctx, gwcb)).Should(Succeed())
+		Expect(yaml.Unmarshal([]byte(pruneTestGatewayManifest), gw)).To(Succeed())
+		Expect(k8sClient.Create(ctx, gw)).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(k8sClient.Delete(ctx, gw)).Should(Succeed())
+		Expect(k8sClient.Delete(ctx, gwc)).Should(Succeed())
+		Expect(k8sClient.Delete(ctx, gwcb)).Should(Succeed())
+	})
+
+	It("Should prune resources no longer rendered", func() {
+		permanentNN := types.NamespacedName{Name: "prune-test-permanent", Namespace: "default"}
+		optionalNN := types.NamespacedName{Name: "prune-test-optional", Namespace: "default"}
+
+		By("Creating child resources labelled with the parent")
+		cm := &corev1.ConfigMap{}
+		Eventually(func() bool {
+			return k8sClient.Get(ctx, optionalNN, cm) == nil
+		}, timeout, interval).Should(BeTrue())
+		Expect(cm.ObjectMeta.Labels).To(HaveKeyWithValue(selfapi.ParentUIDLabel, string(gw.ObjectMeta.UID)))
+		Expect(cm.ObjectMeta.Annotations).To(HaveKeyWithValue(selfapi.ParentAnnotation, "Gateway/default/prune-test"))
+
+		By("Recording child resources in the parent inventory")
+		gwRead := &gatewayapi.Gateway{}
+		Eventually(func() bool {
+			if err := k8sClient.Get(ctx, types.NamespacedName{Name: gw.Name, Namespace: gw.Namespace}, gwRead); err != nil {
+				return false
+			}
+			entries, err := readInventory(gwRead)
+			return err == nil && len(entries) == 2
+		}, timeout, interval).Should(BeTrue())
+
+		By("Disabling the optional template")
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: gwcb.Name}, gwcb)).To(Succeed())
+		gwcb.Spec.Values.Default.Raw = []byte(`{"optionalEnabled": false}`)
+		Expect(k8sClient.Update(ctx, gwcb)).To(Succeed())
+
+		By("Deleting the resource no longer rendered")
+		Eventually(func() bool {
+			err := k8sClient.Get(ctx, optionalNN, cm)
+			return apierrors.IsNotFound(err)
+		}, timeout, interval).Should(BeTrue())
+		Expect(k8sClient.Get(ctx, permanentNN, cm)).To(Succeed())
+	})
+})
+
+const finalizerTestGatewayClassManifest string = `
+apiVersion: gateway.networking.k8s.io/v1beta1
+kind: GatewayClass
+metadata:
+  name: finalizer-test
+spec:
+  controllerName: "github.com/tv2-oss/bifrost-gateway-controller"
+  parametersRef:
+    group: gateway.tv2.dk
+    kind: GatewayClassBlueprint
+    name: finalizer-test`
+
+const finalizerTestGatewayManifest string = `
+apiVersion: gateway.networking.k8s.io/v1beta1
+kind: Gateway
+metadata:
+  name: finalizer-test
+  namespace: default
+spec:
+  gatewayClassName: finalizer-test
+  listeners:
+  - name: prod-web
+    port: 80
+    protocol: HTTP
+`
+
+const finalizerTestGatewayClassBlueprintManifest string = `
+apiVersion: gateway.tv2.dk/v1alpha1
+kind: GatewayClassBlueprint
+metadata:
+  name: finalizer-test
+spec:
+  gatewayTemplate:
+    resourceTemplates:
+      clusterRole: |
+        apiVersion: rbac.authorization.k8s.io/v1
+        kind: ClusterRole
+        metadata:
+          name: {{ .Gateway.metadata.namespace }}-{{ .Gateway.metadata.name }}
+        rules: []
+`
+
+var _ = Describe("Gateway controller finalizer", func() {
+
+	const (
+		timeout  = time.Second * 15
+		interval = time.Millisecond * 250
+	)
+
+	var (
+		gwc  *gatewayapi.GatewayClass
+		gwcb *gwcapi.GatewayClassBlueprint
+		gw   *gatewayapi.Gateway
+		ctx  context.Context
+	)
+
+	BeforeEach(func() {
+		gwc = &gatewayapi.GatewayClass{}
+		gwcb = &gwcapi.GatewayClassBlueprint{}
+		gw = &gatewayapi.Gateway{}
+		ctx = context.Background()
+		Expect(yaml.Unmarshal([]byte(finalizerTestGatewayClassManifest), gwc)).To(Succeed())
+		Expect(k8sClient.Create(ctx, gwc)).Should(Succeed())
+		Expect(yaml.Unmarshal([]byte(finalizerTestGatewayClassBlueprintManifest), gwcb)).To(Succeed())
+		Expect(k8sClient.Create(ctx, gwcb)).Should(Succeed())
+		Expect(yaml.Unmarshal([]byte(finalizerTestGatewayManifest), gw)).To(Succeed())
+		Expect(k8sClient.Create(ctx, gw)).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(k8sClient.Delete(ctx, gwc)).Should(Succeed())
+		Expect(k8sClient.Delete(ctx, gwcb)).Should(Succeed())
+	})
+
+	It("Should delete cluster-scoped resources before the Gateway", func() {
+		gwNN := types.NamespacedName{Name: gw.Name, Namespace: gw.Namespace}
+		crNN := types.NamespacedName{Name: "default-finalizer-test"}
+
+		By("Adding a finalizer and creating the cluster-scoped resource")
+		cr := &rbacv1.ClusterRole{}
+		Eventually(func() bool {
+			return k8sClient.Get(ctx, crNN, cr) == nil
+		}, timeout, interval).Should(BeTrue())
+		Expect(cr.ObjectMeta.Labels).To(HaveKeyWithValue(selfapi.ParentUIDLabel, string(gw.ObjectMeta.UID)))
+		Expect(k8sClient.Get(ctx, gwNN, gw)).To(Succeed())
+		Expect(gw.ObjectMeta.Finalizers).To(ContainElement(selfapi.ChildResourcesFinalizer))
+
+		By("Deleting the Gateway")
+		Expect(k8sClient.Delete(ctx, gw)).Should(Succeed())
+		Eventually(func() bool {
+			return apierrors.IsNotFound(k8sClient.Get(ctx, crNN, cr))
+		}, timeout, interval).Should(BeTrue())
+		Eventually(func() bool {
+			return apierrors.IsNotFound(k8sClient.Get(ctx, gwNN, gw))
+		}, timeout, interval).Should(BeTrue())
+	})
+})
+
+const wavesTestGatewayClassManifest string = `
+apiVersion: gateway.networking.k8s.io/v1beta1
+kind: GatewayClass
+metadata:
+  name: waves-test
+spec:
+  controllerName: "github.com/tv2-oss/bifrost-gateway-controller"
+  parametersRef:
+    group: gateway.tv2.dk
+    kind: GatewayClassBlueprint
+    name: waves-test`
+
+const wavesTestGatewayManifest string = `
+apiVersion: gateway.networking.k8s.io/v1beta1
+kind: Gateway
+metadata:
+  name: waves-test
+  namespace: default
+spec:
+  gatewayClassName: waves-test
+  listeners:
+  - name: prod-web
+    port: 80
+    protocol: HTTP
+`
+
+// Deployments never become ready in the test environment since there is no deployment controller
+const wavesTestGatewayClassBlueprintManifest string = `
+apiVersion: gateway.tv2.dk/v1alpha1
+kind: GatewayClassBlueprint
+metadata:
+  name: waves-test
+spec:
+  gatewayTemplate:
+    resourceTemplates:
+      deployment: |
+        apiVersion: apps/v1
+        kind: Deployment
+        metadata:
+          name: {{ .Gateway.metadata.name }}
+          namespace: {{ .Gateway.metadata.namespace }}
+        spec:
+          selector:
+            matchLabels:
+              app: waves-test
+          template:
+            metadata:
+              labels:
+                app: waves-test
+            spec:
+              containers:
+              - name: test
+                image: test
+      configMap: |
+        apiVersion: v1
+        kind: ConfigMap
+        metadata:
+          name: {{ .Gateway.metadata.name }}
+          namespace: {{ .Gateway.metadata.namespace }}
+    templateOptions:
+      configMap:
+        wave: 1
+`
+
+var _ = Describe("Gateway controller apply waves", func() {
+
+	const (
+		timeout  = time.Second * 15
+		interval = time.Millisecond * 250
+	)
+
+	var (
+		gwc  *gatewayapi.GatewayClass
+		gwcb *gwcapi.GatewayClassBlueprint
+		gw   *gatewayapi.Gateway
+		ctx  context.Context
+	)
+
+	BeforeEach(func() {
+		gwc = &gatewayapi.GatewayClass{}
+		gwcb = &gwcapi.GatewayClassBlueprint{}
+		gw = &gatewayapi.Gateway{}
+		ctx = context.Background()
+		Expect(yaml.Unmarshal([]byte(wavesTestGatewayClassManifest), gwc)).To(Succeed())
+		Expect(k8sClient.Create(ctx, gwc)).Should(Succeed())
+		Expect(yaml.Unmarshal([]byte(wavesTestGatewayClassBlueprintManifest), gwcb)).To(Succeed())
+		Expect(k8sClient.Create(ctx, gwcb)).Should(Succeed())
+		Expect(yaml.Unmarshal([]byte(wavesTestGatewayManifest), gw)).To(Succeed())
+		Expect(k8sClient.Create(ctx, gw)).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(k8sClient.Delete(ctx, gw)).Should(Succeed())
+		Expect(k8sClient.Delete(ctx, gwc)).Should(Succeed())
+		Expect(k8sClient.Delete(ctx, gwcb)).Should(Succeed())
+	})
+
+	It("Should hold back later waves until earlier waves are ready", func() {
+		nn := types.NamespacedName{Name: gw.Name, Namespace: gw.Namespace}
+
+		By("Reporting the blocking wave")
+		Eventually(func() bool {
+			if err := k8sClient.Get(ctx, nn, gw); err != nil {
+				return false
+			}
+			cond := meta.FindStatusCondition(gw.Status.Conditions, string(gatewayapi.GatewayConditionProgrammed))
+			return cond != nil && cond.Status == metav1.ConditionFalse &&
+				regexp.MustCompile(`^waiting for wave 0 to become ready \(deployment\)`).MatchString(cond.Message)
+		}, timeout, interval).Should(BeTrue())
+
+		By("Not creating resources from later waves")
+		Expect(k8sClient.Get(ctx, nn, &appsv1.Deployment{})).To(Succeed())
+		Expect(apierrors.IsNotFound(k8sClient.Get(ctx, nn, &corev1.ConfigMap{}))).To(BeTrue())
+	})
+})
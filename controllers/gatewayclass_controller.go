@@ -125,7 +125,7 @@ func (r *GatewayClassReconciler) Reconcile(ctx context.Context, req ctrl.Request
 			ObservedGeneration: gwc.ObjectMeta.Generation})
 	}
 
-	err = r.Client().Status().Update(ctx, gwc)
+	err = updateStatus(ctx, r, gwc)
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("failed to update GatewayClass status condition: %w", err)
 	}
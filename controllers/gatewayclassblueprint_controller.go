@@ -0,0 +1,210 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+)
+
+// GatewayClassBlueprintReconciler reconciles a GatewayClassBlueprint object
+type GatewayClassBlueprintReconciler struct {
+	client client.Client
+	scheme *runtime.Scheme
+}
+
+func (r *GatewayClassBlueprintReconciler) Client() client.Client {
+	return r.client
+}
+
+func (r *GatewayClassBlueprintReconciler) Scheme() *runtime.Scheme {
+	return r.scheme
+}
+
+func NewGatewayClassBlueprintController(mgr ctrl.Manager) *GatewayClassBlueprintReconciler {
+	r := &GatewayClassBlueprintReconciler{
+		client: mgr.GetClient(),
+		scheme: mgr.GetScheme(),
+	}
+	return r
+}
+
+func (r *GatewayClassBlueprintReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&gwcapi.GatewayClassBlueprint{}).
+		Complete(r)
+}
+
+func (r *GatewayClassBlueprintReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	var gwcb gwcapi.GatewayClassBlueprint
+	if err := r.Client().Get(ctx, req.NamespacedName, &gwcb); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	beforeStatusUpdate := gwcb.DeepCopy()
+
+	acceptedStatus := metav1.ConditionTrue
+	acceptedReason := gwcapi.GatewayClassBlueprintReasonAccepted
+	acceptedMsg := ""
+	sections := parseBlueprint(&gwcb)
+	if invalidReason, err := validateBlueprint(&gwcb, sections); err != nil {
+		logger.Info("invalid blueprint", "reason", invalidReason, "error", err)
+		acceptedStatus = metav1.ConditionFalse
+		acceptedReason = invalidReason
+		acceptedMsg = err.Error()
+	}
+	meta.SetStatusCondition(&gwcb.Status.Conditions, metav1.Condition{
+		Type:               gwcapi.GatewayClassBlueprintConditionAccepted,
+		Status:             acceptedStatus,
+		Reason:             acceptedReason,
+		Message:            acceptedMsg,
+		ObservedGeneration: gwcb.ObjectMeta.Generation})
+
+	status := metav1.ConditionTrue
+	reason := gwcapi.GatewayClassBlueprintReasonResolvedDependencies
+	msg := ""
+	if invalidReason, err := validateTemplateDependencies(sections); err != nil {
+		logger.Info("invalid template dependencies", "reason", invalidReason, "error", err)
+		status = metav1.ConditionFalse
+		reason = invalidReason
+		msg = err.Error()
+	}
+	meta.SetStatusCondition(&gwcb.Status.Conditions, metav1.Condition{
+		Type:               gwcapi.GatewayClassBlueprintConditionResolvedDependencies,
+		Status:             status,
+		Reason:             reason,
+		Message:            msg,
+		ObservedGeneration: gwcb.ObjectMeta.Generation})
+
+	if !equality.Semantic.DeepEqual(beforeStatusUpdate.Status, gwcb.Status) {
+		if err := updateStatus(ctx, r, &gwcb); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to update GatewayClassBlueprint status condition: %w", err)
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// Resource templates of a section of a GatewayClassBlueprint, e.g.
+// 'gatewayTemplate', parsed for validation
+type blueprintSection struct {
+	name      string
+	spec      *gwcapi.ResourceSpec
+	templates []*ResourceTemplateState
+	parseErr  error
+}
+
+// Parse the resource templates of all sections of a
+// GatewayClassBlueprint. Parse errors are not counted in metrics since
+// templates are parsed again when rendering
+func parseBlueprint(gwcb *gwcapi.GatewayClassBlueprint) []blueprintSection {
+	sections := []blueprintSection{
+		{name: "gatewayTemplate", spec: &gwcb.Spec.GatewayTemplate},
+		{name: "httpRouteTemplate", spec: &gwcb.Spec.HTTPRouteTemplate},
+		{name: "grpcRouteTemplate", spec: &gwcb.Spec.GRPCRouteTemplate},
+		{name: "tlsRouteTemplate", spec: &gwcb.Spec.TLSRouteTemplate},
+		{name: "tcpRouteTemplate", spec: &gwcb.Spec.TCPRouteTemplate},
+		{name: "udpRouteTemplate", spec: &gwcb.Spec.UDPRouteTemplate},
+	}
+	for idx := range sections {
+		sections[idx].templates, sections[idx].parseErr = parseResourceTemplates(sections[idx].spec.ResourceTemplates)
+	}
+	return sections
+}
+
+// Resolve dependencies between the parsed templates of a
+// GatewayClassBlueprint. Returns the condition reason and an error
+// describing the problem if dependencies cannot be resolved.
+func validateTemplateDependencies(sections []blueprintSection) (string, error) {
+	for _, section := range sections {
+		if section.parseErr != nil {
+			return gwcapi.GatewayClassBlueprintReasonInvalidTemplates, fmt.Errorf("%s: %w", section.name, section.parseErr)
+		}
+		if _, err := sortTemplates(section.templates, section.spec.TemplateOptions); err != nil {
+			if errors.Is(err, errDependencyCycle) {
+				return gwcapi.GatewayClassBlueprintReasonDependencyCycle, fmt.Errorf("%s: %w", section.name, err)
+			}
+			return gwcapi.GatewayClassBlueprintReasonInvalidDependencies, fmt.Errorf("%s: %w", section.name, err)
+		}
+	}
+	return "", nil
+}
+
+// Validate a GatewayClassBlueprint, i.e. check that all templates
+// parse, see parseBlueprint, and that values are JSON objects. Returns
+// the condition reason and an error describing the problem if the
+// blueprint is invalid.
+func validateBlueprint(gwcb *gwcapi.GatewayClassBlueprint, sections []blueprintSection) (string, error) {
+	if err := validateValues(&gwcb.Spec.Values); err != nil {
+		return gwcapi.GatewayClassBlueprintReasonInvalidValues, fmt.Errorf("values: %w", err)
+	}
+	if _, err := parseValuesSchema(gwcb.Spec.ValuesSchema); err != nil {
+		return gwcapi.GatewayClassBlueprintReasonInvalidValues, fmt.Errorf("valuesSchema: %w", err)
+	}
+
+	for _, section := range sections {
+		if section.parseErr != nil {
+			return gwcapi.GatewayClassBlueprintReasonInvalidTemplates, fmt.Errorf("%s: %w", section.name, section.parseErr)
+		}
+		statusTemplates := []struct {
+			name    string
+			tmplStr string
+		}{
+			{"status.template", section.spec.Status.Template},
+			{"status.addresses", section.spec.Status.Addresses},
+			{"status.conditions", section.spec.Status.Conditions},
+			{"status.parentConditions", section.spec.Status.ParentConditions},
+			{"listenerStatus", section.spec.ListenerStatus},
+		}
+		for _, tmpl := range statusTemplates {
+			if tmpl.tmplStr == "" {
+				continue
+			}
+			if _, err := parseSingleTemplate(tmpl.name, tmpl.tmplStr); err != nil {
+				return gwcapi.GatewayClassBlueprintReasonInvalidTemplates, fmt.Errorf("%s: %w", section.name, err)
+			}
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+const gwClassBlueprintCycleManifest string = `
+apiVersion: gateway.tv2.dk/v1alpha1
+kind: GatewayClassBlueprint
+metadata:
+  name: dependency-cycle
+spec:
+  gatewayTemplate:
+    resourceTemplates:
+      first: |
+        apiVersion: v1
+        kind: ConfigMap
+        metadata:
+          name: {{ .Gateway.metadata.name }}-first
+          namespace: {{ .Gateway.metadata.namespace }}
+        data:
+          second: {{ (index .Resources.second 0).metadata.name }}
+      second: |
+        apiVersion: v1
+        kind: ConfigMap
+        metadata:
+          name: {{ .Gateway.metadata.name }}-second
+          namespace: {{ .Gateway.metadata.namespace }}
+    templateOptions:
+      second:
+        dependsOn:
+        - first
+`
+
+const gwClassBlueprintInvalidStatusManifest string = `
+apiVersion: gateway.tv2.dk/v1alpha1
+kind: GatewayClassBlueprint
+metadata:
+  name: invalid-status-template
+spec:
+  gatewayTemplate:
+    status:
+      addresses: |
+        {{ range .Resources.foo }}
+`
+
+var _ = Describe("GatewayClassBlueprint controller", func() {
+
+	const (
+		timeout  = time.Second * 10
+		interval = time.Millisecond * 250
+	)
+
+	var (
+		gwcb *gwcapi.GatewayClassBlueprint
+		ctx  context.Context
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		gwcb = &gwcapi.GatewayClassBlueprint{}
+	})
+
+	When("A blueprint with cyclic template dependencies is created", func() {
+		It("Should report the dependency cycle", func() {
+			Expect(yaml.Unmarshal([]byte(gwClassBlueprintCycleManifest), gwcb)).To(Succeed())
+			Expect(k8sClient.Create(ctx, gwcb)).Should(Succeed())
+
+			lookupKey := types.NamespacedName{Name: gwcb.ObjectMeta.Name}
+			Eventually(func() bool {
+				if err := k8sClient.Get(ctx, lookupKey, gwcb); err != nil {
+					return false
+				}
+				cond := meta.FindStatusCondition(gwcb.Status.Conditions, gwcapi.GatewayClassBlueprintConditionResolvedDependencies)
+				return cond != nil && cond.Status == "False" && cond.Reason == gwcapi.GatewayClassBlueprintReasonDependencyCycle
+			}, timeout, interval).Should(BeTrue())
+			Expect(meta.IsStatusConditionTrue(gwcb.Status.Conditions, gwcapi.GatewayClassBlueprintConditionAccepted)).To(BeTrue())
+
+			Expect(k8sClient.Delete(ctx, gwcb)).Should(Succeed())
+		})
+	})
+
+	When("A blueprint with an invalid status template is created", func() {
+		It("Should not accept the blueprint", func() {
+			Expect(yaml.Unmarshal([]byte(gwClassBlueprintInvalidStatusManifest), gwcb)).To(Succeed())
+			Expect(k8sClient.Create(ctx, gwcb)).Should(Succeed())
+
+			lookupKey := types.NamespacedName{Name: gwcb.ObjectMeta.Name}
+			Eventually(func() bool {
+				if err := k8sClient.Get(ctx, lookupKey, gwcb); err != nil {
+					return false
+				}
+				cond := meta.FindStatusCondition(gwcb.Status.Conditions, gwcapi.GatewayClassBlueprintConditionAccepted)
+				return cond != nil && cond.Status == "False" && cond.Reason == gwcapi.GatewayClassBlueprintReasonInvalidTemplates
+			}, timeout, interval).Should(BeTrue())
+
+			Expect(k8sClient.Delete(ctx, gwcb)).Should(Succeed())
+		})
+	})
+})
@@ -0,0 +1,413 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+	selfapi "github.com/tv2-oss/bifrost-gateway-controller/pkg/api"
+)
+
+// A child resource as recorded in the inventory of a parent resource
+type InventoryEntry struct {
+	Group     string `json:"group,omitempty"`
+	Version   string `json:"version"`
+	Resource  string `json:"resource"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+
+	// Resource is no longer rendered but kept due to prune policy 'Keep'
+	Pruned bool `json:"pruned,omitempty"`
+}
+
+func (e *InventoryEntry) GVR() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: e.Group, Version: e.Version, Resource: e.Resource}
+}
+
+// Compare entries, ignoring API version since the same resource may be rendered using different versions
+func (e *InventoryEntry) sameResource(other *InventoryEntry) bool {
+	return e.Group == other.Group && e.Resource == other.Resource && e.Namespace == other.Namespace && e.Name == other.Name
+}
+
+func (e *InventoryEntry) String() string {
+	if e.Namespace == "" {
+		return fmt.Sprintf("%s.%s/%s", e.Resource, e.Group, e.Name)
+	}
+	return fmt.Sprintf("%s.%s/%s/%s", e.Resource, e.Group, e.Namespace, e.Name)
+}
+
+// Return dynamic client interface for an inventory entry
+func inventoryResourceClient(r ControllerDynClient, e *InventoryEntry) dynamic.ResourceInterface {
+	if e.Namespace == "" {
+		return r.DynamicClient().Resource(e.GVR())
+	}
+	return r.DynamicClient().Resource(e.GVR()).Namespace(e.Namespace)
+}
+
+// Label and annotate a child resource such that the parent resource can be identified
+func setParentMetadata(child *unstructured.Unstructured, parent client.Object, parentKind string) {
+	labels := child.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[selfapi.ParentUIDLabel] = string(parent.GetUID())
+	child.SetLabels(labels)
+
+	annotations := child.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[selfapi.ParentAnnotation] = fmt.Sprintf("%s/%s/%s", parentKind, parent.GetNamespace(), parent.GetName())
+	child.SetAnnotations(annotations)
+}
+
+// Build inventory entries from rendered resources. Namespaced
+// resources are always created in the namespace of the parent
+func templatesToInventory(templates []*ResourceTemplateState, namespace string) []InventoryEntry {
+	entries := make([]InventoryEntry, 0, len(templates))
+	for _, tmpl := range templates {
+		for _, res := range tmpl.Resources {
+			if res.Rendered == nil || res.GVR == nil {
+				continue
+			}
+			e := InventoryEntry{
+				Group:    res.GVR.Group,
+				Version:  res.GVR.Version,
+				Resource: res.GVR.Resource,
+				Kind:     res.Rendered.GetKind(),
+				Name:     res.Rendered.GetName(),
+			}
+			if res.IsNamespaced {
+				e.Namespace = namespace
+			}
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// Read inventory from annotation of parent resource
+func readInventory(parent metav1.Object) ([]InventoryEntry, error) {
+	entries := []InventoryEntry{}
+	data, found := parent.GetAnnotations()[selfapi.InventoryAnnotation]
+	if !found || data == "" {
+		return entries, nil
+	}
+	if err := json.Unmarshal([]byte(data), &entries); err != nil {
+		return nil, fmt.Errorf("cannot decode inventory: %w", err)
+	}
+	return entries, nil
+}
+
+// Write inventory to annotation of parent resource. The parent is only updated if the inventory changed
+func writeInventory(ctx context.Context, r ControllerClient, parent client.Object, entries []InventoryEntry) error {
+	data := ""
+	if len(entries) > 0 {
+		raw, err := json.Marshal(entries)
+		if err != nil {
+			return fmt.Errorf("cannot encode inventory: %w", err)
+		}
+		data = string(raw)
+	}
+
+	annotations := parent.GetAnnotations()
+	if annotations[selfapi.InventoryAnnotation] == data {
+		return nil
+	}
+
+	return patchParentMetadata(ctx, r, parent, func(obj client.Object) {
+		annotations := obj.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		if data == "" {
+			delete(annotations, selfapi.InventoryAnnotation)
+		} else {
+			annotations[selfapi.InventoryAnnotation] = data
+		}
+		obj.SetAnnotations(annotations)
+	})
+}
+
+// Patch metadata of a parent resource. The patch is done on a copy
+// such that other changes to parent, e.g. status, are retained while
+// the resulting annotations, finalizers and resource version are
+// copied back to parent
+func patchParentMetadata(ctx context.Context, r ControllerClient, parent client.Object, mutate func(client.Object)) error {
+	updated := parent.DeepCopyObject().(client.Object)
+	patch := client.MergeFromWithOptions(parent.DeepCopyObject().(client.Object), client.MergeFromWithOptimisticLock{})
+	mutate(updated)
+	if DryRun {
+		recordDryRun(ctx, parent, dryRunUpdateMetadata, "update %s", changedMetadata(parent, updated))
+		return nil
+	}
+	if err := r.Client().Patch(ctx, updated, patch); err != nil {
+		return err
+	}
+	parent.SetAnnotations(updated.GetAnnotations())
+	parent.SetFinalizers(updated.GetFinalizers())
+	parent.SetResourceVersion(updated.GetResourceVersion())
+	return nil
+}
+
+// Combine existing inventory with currently rendered resources. The
+// order of existing entries is kept and new entries are appended,
+// i.e. the inventory lists resources in the order they were
+// created. Entries no longer rendered are returned separately.
+func updateInventory(existing, rendered []InventoryEntry) (updated, stale []InventoryEntry) {
+	isRendered := func(e *InventoryEntry) *InventoryEntry {
+		for idx := range rendered {
+			if rendered[idx].sameResource(e) {
+				return &rendered[idx]
+			}
+		}
+		return nil
+	}
+
+	updated = make([]InventoryEntry, 0, len(rendered))
+	for idx := range existing {
+		if r := isRendered(&existing[idx]); r != nil {
+			updated = append(updated, *r)
+		} else {
+			stale = append(stale, existing[idx])
+		}
+	}
+	for idx := range rendered {
+		found := false
+		for uIdx := range updated {
+			if updated[uIdx].sameResource(&rendered[idx]) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			updated = append(updated, rendered[idx])
+		}
+	}
+	return updated, stale
+}
+
+// Handle resources which are no longer rendered according to prune
+// policy. Returns entries which should be retained in the inventory,
+// i.e. resources kept due to prune policy and resources which could
+// not be pruned and should be retried.
+func pruneResources(ctx context.Context, r ControllerDynClient, parent client.Object,
+	stale []InventoryEntry, policy gwcapi.PrunePolicy) ([]InventoryEntry, error) {
+	var errorCnt = 0
+	retained := make([]InventoryEntry, 0)
+
+	logger := log.FromContext(ctx)
+
+	for idx := range stale {
+		e := stale[idx]
+		resClient := inventoryResourceClient(r, &e)
+		var err error
+
+		if DryRun {
+			if !e.Pruned {
+				recordDryRunPrune(ctx, parent, &e, policy)
+			}
+			if policy == gwcapi.PrunePolicyKeep {
+				e.Pruned = true
+				retained = append(retained, e)
+			}
+			continue
+		}
+
+		switch policy {
+		case gwcapi.PrunePolicyKeep:
+			if e.Pruned {
+				retained = append(retained, e) // Already pruned
+				continue
+			}
+			patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:"true"}}}`, selfapi.PrunedAnnotation)
+			_, err = resClient.Patch(ctx, e.Name, types.MergePatchType, []byte(patch), metav1.PatchOptions{
+				FieldManager: string(selfapi.SelfControllerName),
+			})
+			if err == nil {
+				e.Pruned = true
+				retained = append(retained, e)
+			}
+		case gwcapi.PrunePolicyOrphan:
+			err = orphanResource(ctx, resClient, parent, e.Name)
+		default:
+			propagation := metav1.DeletePropagationBackground
+			err = resClient.Delete(ctx, e.Name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+		}
+
+		if err != nil && !apierrors.IsNotFound(err) {
+			logger.Error(err, "cannot prune resource", "resource", e.String(), "policy", policy)
+			metricPruneErrs.Inc()
+			errorCnt++
+			retained = append(retained, e) // Retry on next reconcile
+			continue
+		}
+		logger.Info("pruned resource", "resource", e.String(), "policy", policy)
+		metricPrune.Inc()
+	}
+
+	if errorCnt > 0 {
+		return retained, fmt.Errorf("found %v problems while pruning %v resources", errorCnt, len(stale))
+	}
+	return retained, nil
+}
+
+// Release a resource from its parent by removing owner reference,
+// labels and annotations identifying the parent
+func orphanResource(ctx context.Context, resClient dynamic.ResourceInterface, parent client.Object, name string) error {
+	current, err := resClient.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	owners := []metav1.OwnerReference{}
+	for _, owner := range current.GetOwnerReferences() {
+		if owner.UID != parent.GetUID() {
+			owners = append(owners, owner)
+		}
+	}
+	current.SetOwnerReferences(owners)
+
+	labels := current.GetLabels()
+	delete(labels, selfapi.ParentUIDLabel)
+	current.SetLabels(labels)
+
+	annotations := current.GetAnnotations()
+	delete(annotations, selfapi.ParentAnnotation)
+	current.SetAnnotations(annotations)
+
+	_, err = resClient.Update(ctx, current, metav1.UpdateOptions{FieldManager: string(selfapi.SelfControllerName)})
+	return err
+}
+
+// Remove the 'pruned' annotation from a resource which is rendered again after having been pruned with policy 'Keep'
+func unpruneResource(ctx context.Context, r ControllerDynClient, res *ResourceComposite, namespace *string) error {
+	if res.Current == nil {
+		return nil
+	}
+	if _, found := res.Current.GetAnnotations()[selfapi.PrunedAnnotation]; !found {
+		return nil
+	}
+	if DryRun {
+		recordDryRun(ctx, res.Current, dryRunUpdate, "remove pruned annotation from %s",
+			resourceName(res.Current.GetKind(), res.Current.GetNamespace(), res.Current.GetName()))
+		return nil
+	}
+	var resClient dynamic.ResourceInterface
+	if namespace != nil {
+		resClient = r.DynamicClient().Resource(*res.GVR).Namespace(*namespace)
+	} else {
+		resClient = r.DynamicClient().Resource(*res.GVR)
+	}
+	patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:null}}}`, selfapi.PrunedAnnotation)
+	_, err := resClient.Patch(ctx, res.Rendered.GetName(), types.MergePatchType, []byte(patch), metav1.PatchOptions{
+		FieldManager: string(selfapi.SelfControllerName),
+	})
+	return err
+}
+
+// Update the inventory of a parent resource with the currently
+// rendered child resources and prune resources no longer
+// rendered. Pruning is only done when the reconciliation is
+// 'complete', i.e. all templates have been rendered and applied,
+// since otherwise we cannot tell whether a resource is no longer
+// rendered or temporarily could not be rendered, e.g. due to a
+// missing dependency.
+func reconcileInventory(ctx context.Context, r ControllerDynClient, parent client.Object,
+	rendered []InventoryEntry, policy gwcapi.PrunePolicy, complete bool) error {
+	existing, err := readInventory(parent)
+	if err != nil {
+		return err
+	}
+
+	updated, stale := updateInventory(existing, rendered)
+
+	var errPrune error
+	if len(stale) > 0 {
+		if complete {
+			var retained []InventoryEntry
+			retained, errPrune = pruneResources(ctx, r, parent, stale, policy)
+			updated = append(updated, retained...)
+		} else {
+			updated = append(updated, stale...)
+		}
+	}
+
+	if err := writeInventory(ctx, r, parent, updated); err != nil {
+		return fmt.Errorf("cannot update inventory: %w", err)
+	}
+	return errPrune
+}
+
+// Return the kind of a parent resource, e.g. 'Gateway'
+func parentKind(r ControllerClient, parent client.Object) (string, error) {
+	gvk, err := apiutil.GVKForObject(parent, r.Scheme())
+	if err != nil {
+		return "", err
+	}
+	return gvk.Kind, nil
+}
+
+// Select the most conservative of two prune policies, i.e. the one
+// that retains most. Used when a parent resource is rendered using
+// multiple GatewayClassBlueprints, e.g. an HTTPRoute attached to
+// multiple Gateways
+func conservativePrunePolicy(a, b gwcapi.PrunePolicy) gwcapi.PrunePolicy {
+	rank := func(p gwcapi.PrunePolicy) int {
+		switch p {
+		case gwcapi.PrunePolicyOrphan:
+			return 2
+		case gwcapi.PrunePolicyKeep:
+			return 1
+		default:
+			return 0
+		}
+	}
+	if rank(b) > rank(a) {
+		return b
+	}
+	return a
+}
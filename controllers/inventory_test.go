@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"testing"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+	selfapi "github.com/tv2-oss/bifrost-gateway-controller/pkg/api"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func helperInventoryEntry(resource, name string) InventoryEntry {
+	return InventoryEntry{Version: "v1", Resource: resource, Kind: "Kind", Namespace: "default", Name: name}
+}
+
+func TestUpdateInventory(t *testing.T) {
+	existing := []InventoryEntry{
+		helperInventoryEntry("configmaps", "cm1"),
+		helperInventoryEntry("configmaps", "cm2"),
+		helperInventoryEntry("secrets", "s1"),
+	}
+	rendered := []InventoryEntry{
+		helperInventoryEntry("secrets", "s2"),
+		helperInventoryEntry("secrets", "s1"),
+		helperInventoryEntry("configmaps", "cm1"),
+	}
+	updated, stale := updateInventory(existing, rendered)
+	if len(updated) != 3 {
+		t.Fatalf("Updated inventory length, got %v, expected 3", len(updated))
+	}
+	// Existing order retained, new entries appended
+	if updated[0].Name != "cm1" || updated[1].Name != "s1" || updated[2].Name != "s2" {
+		t.Fatalf("Updated inventory order, got %+v", updated)
+	}
+	if len(stale) != 1 || stale[0].Name != "cm2" {
+		t.Fatalf("Stale inventory, got %+v, expected cm2", stale)
+	}
+
+	// Change of API version is not a new resource
+	rendered = []InventoryEntry{helperInventoryEntry("configmaps", "cm1")}
+	rendered[0].Version = "v2"
+	updated, stale = updateInventory(existing[0:1], rendered)
+	if len(updated) != 1 || len(stale) != 0 || updated[0].Version != "v2" {
+		t.Fatalf("Version change, got updated %+v stale %+v", updated, stale)
+	}
+}
+
+func TestReadInventory(t *testing.T) {
+	parent := metav1.ObjectMeta{}
+	entries, err := readInventory(&parent)
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("Empty inventory, got %+v, err %v", entries, err)
+	}
+
+	parent.Annotations = map[string]string{
+		selfapi.InventoryAnnotation: `[{"version":"v1","resource":"configmaps","kind":"ConfigMap","namespace":"default","name":"cm1"},` +
+			`{"group":"example.com","version":"v1","resource":"foos","kind":"Foo","name":"foo1","pruned":true}]`,
+	}
+	entries, err = readInventory(&parent)
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("Inventory, got %+v, err %v", entries, err)
+	}
+	if entries[1].GVR().Group != "example.com" || entries[1].Namespace != "" || !entries[1].Pruned {
+		t.Fatalf("Inventory cluster-scoped entry, got %+v", entries[1])
+	}
+
+	parent.Annotations[selfapi.InventoryAnnotation] = "not-json"
+	if _, err = readInventory(&parent); err == nil {
+		t.Fatalf("Expected error decoding invalid inventory")
+	}
+}
+
+func TestConservativePrunePolicy(t *testing.T) {
+	if p := conservativePrunePolicy(gwcapi.PrunePolicyDelete, ""); p != gwcapi.PrunePolicyDelete {
+		t.Fatalf("Prune policy, got %v, expected Delete", p)
+	}
+	if p := conservativePrunePolicy(gwcapi.PrunePolicyDelete, gwcapi.PrunePolicyKeep); p != gwcapi.PrunePolicyKeep {
+		t.Fatalf("Prune policy, got %v, expected Keep", p)
+	}
+	if p := conservativePrunePolicy(gwcapi.PrunePolicyOrphan, gwcapi.PrunePolicyKeep); p != gwcapi.PrunePolicyOrphan {
+		t.Fatalf("Prune policy, got %v, expected Orphan", p)
+	}
+}
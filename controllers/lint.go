@@ -0,0 +1,530 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+)
+
+// Rules checked by LintBlueprint
+const (
+	LintRuleParse              = "parse"
+	LintRuleValues             = "values"
+	LintRuleUnknownResource    = "unknown-resource"
+	LintRuleUnknownValue       = "unknown-value"
+	LintRuleUnknownDependency  = "unknown-dependency"
+	LintRuleDependencyCycle    = "dependency-cycle"
+	LintRuleDangerousFunction  = "dangerous-function"
+	LintRuleInvalidResource    = "invalid-resource"
+	LintRuleHardcodedNamespace = "hardcoded-namespace"
+)
+
+// A problem found in a GatewayClassBlueprint
+type LintFinding struct {
+	// Template or other part of the blueprint with the problem,
+	// e.g. 'gatewayTemplate.resourceTemplates.LB'
+	Template string
+
+	// Rule identifying the kind of problem, e.g. 'unknown-value'
+	Rule string
+
+	// Description of the problem
+	Message string
+}
+
+func (f LintFinding) String() string {
+	return fmt.Sprintf("%s: %s (%s)", f.Template, f.Message, f.Rule)
+}
+
+// Template functions which should not be used, with the reason.
+// Templates are rendered by the controller on every reconciliation
+var dangerousFunctions = map[string]string{
+	"env":                      "exposes environment variables of the controller",
+	"expandenv":                "exposes environment variables of the controller",
+	"getHostByName":            "performs DNS lookups from the controller",
+	"now":                      "renders differently on every reconciliation",
+	"randAlphaNum":             "renders differently on every reconciliation",
+	"randAlpha":                "renders differently on every reconciliation",
+	"randAscii":                "renders differently on every reconciliation",
+	"randNumeric":              "renders differently on every reconciliation",
+	"randBytes":                "renders differently on every reconciliation",
+	"randInt":                  "renders differently on every reconciliation",
+	"uuidv4":                   "renders differently on every reconciliation",
+	"shuffle":                  "renders differently on every reconciliation",
+	"genPrivateKey":            "renders differently on every reconciliation",
+	"genCA":                    "renders differently on every reconciliation",
+	"genCAWithKey":             "renders differently on every reconciliation",
+	"genSelfSignedCert":        "renders differently on every reconciliation",
+	"genSelfSignedCertWithKey": "renders differently on every reconciliation",
+	"genSignedCert":            "renders differently on every reconciliation",
+	"genSignedCertWithKey":     "renders differently on every reconciliation",
+}
+
+// Namespaces of the example parents used when rendering templates for linting
+const (
+	lintGatewayNamespace = "lint-gateway-namespace"
+	lintRouteNamespace   = "lint-route-namespace"
+)
+
+type blueprintLinter struct {
+	gwcb          *gwcapi.GatewayClassBlueprint
+	clusterScoped map[schema.GroupKind]bool
+
+	// Default and override values of the blueprint
+	values map[string]any
+
+	// Values schema of the blueprint, nil if none
+	schema *spec.Schema
+
+	findings []LintFinding
+}
+
+func (l *blueprintLinter) add(tmplName, rule, format string, args ...any) {
+	l.findings = append(l.findings, LintFinding{Template: tmplName, Rule: rule, Message: fmt.Sprintf(format, args...)})
+}
+
+// Check a GatewayClassBlueprint for problems which are otherwise only
+// found when rendering templates for a Gateway or route, or not at all:
+//
+//   - Templates which cannot be parsed
+//   - References to '.Resources.<name>' where no template has that name
+//   - References to '.Values.<path>' defined by neither the default or
+//     override values of the blueprint, its values schema nor 'valuesFrom'
+//   - Unknown or cyclic template dependencies
+//   - Use of template functions like 'env', which expose controller
+//     internals or render differently on every reconciliation
+//   - Rendered resources without 'apiVersion', 'kind' or 'metadata.name'
+//   - Namespaced resources with a namespace other than the namespace of the parent
+//
+// Resources are checked by rendering templates for example parents
+// using the values of the blueprint, with values not defined by the
+// blueprint generated from its values schema. Templates which cannot
+// be rendered this way, e.g. because they use the status of other
+// resources, are not checked. Kinds of cluster scoped resources may
+// be given in addition to a built-in list of cluster scoped kinds.
+func LintBlueprint(gwcb *gwcapi.GatewayClassBlueprint, clusterScoped []schema.GroupKind) []LintFinding {
+	l := &blueprintLinter{
+		gwcb:          gwcb,
+		clusterScoped: map[schema.GroupKind]bool{},
+		values:        map[string]any{},
+	}
+	for _, gk := range append(clusterScopedKinds, clusterScoped...) {
+		l.clusterScoped[gk] = true
+	}
+
+	if err := validateValues(&gwcb.Spec.Values); err != nil {
+		l.add("values", LintRuleValues, "%v", err)
+	} else {
+		defaults, _ := valuesToMap(gwcb.Spec.Values.Default)
+		overrides, _ := valuesToMap(gwcb.Spec.Values.Override)
+		strategies := newMergeStrategies(nil, &gwcb.Spec.Values)
+		l.values = merge(defaults, overrides, nil, strategies).(map[string]any)
+	}
+	var err error
+	if l.schema, err = parseValuesSchema(gwcb.Spec.ValuesSchema); err != nil {
+		l.add("valuesSchema", LintRuleValues, "%v", err)
+	}
+
+	sections := []struct {
+		name   string
+		spec   *gwcapi.ResourceSpec
+		rtType *routeType
+	}{
+		{"gatewayTemplate", &gwcb.Spec.GatewayTemplate, nil},
+		{"httpRouteTemplate", &gwcb.Spec.HTTPRouteTemplate, httpRouteType},
+		{"grpcRouteTemplate", &gwcb.Spec.GRPCRouteTemplate, grpcRouteType},
+		{"tlsRouteTemplate", &gwcb.Spec.TLSRouteTemplate, tlsRouteType},
+		{"tcpRouteTemplate", &gwcb.Spec.TCPRouteTemplate, tcpRouteType},
+		{"udpRouteTemplate", &gwcb.Spec.UDPRouteTemplate, udpRouteType},
+	}
+	for _, section := range sections {
+		l.lintSection(section.name, section.spec, section.rtType)
+	}
+
+	sort.SliceStable(l.findings, func(i, j int) bool {
+		a, b := l.findings[i], l.findings[j]
+		if a.Template != b.Template {
+			return a.Template < b.Template
+		}
+		if a.Rule != b.Rule {
+			return a.Rule < b.Rule
+		}
+		return a.Message < b.Message
+	})
+	return l.findings
+}
+
+// Lint the resource and status templates of a blueprint section. The
+// route type is nil for the Gateway section
+func (l *blueprintLinter) lintSection(sectionName string, resSpec *gwcapi.ResourceSpec, rtType *routeType) {
+	tmplNames := make([]string, 0, len(resSpec.ResourceTemplates))
+	for name := range resSpec.ResourceTemplates {
+		tmplNames = append(tmplNames, name)
+	}
+	sort.Strings(tmplNames)
+	knownResources := map[string]bool{}
+	for _, name := range tmplNames {
+		knownResources[name] = true
+	}
+
+	parseErrors := false
+	templates := make([]*ResourceTemplateState, 0, len(tmplNames))
+	for _, name := range tmplNames {
+		fullName := sectionName + ".resourceTemplates." + name
+		tmpl := l.lintTemplate(fullName, name, resSpec.ResourceTemplates[name], knownResources)
+		if tmpl == nil {
+			parseErrors = true
+			continue
+		}
+		templates = append(templates, &ResourceTemplateState{TemplateName: name, Template: tmpl})
+	}
+
+	statusTemplates := []struct {
+		name    string
+		tmplStr string
+	}{
+		{"status.template", resSpec.Status.Template},
+		{"status.addresses", resSpec.Status.Addresses},
+		{"status.conditions", resSpec.Status.Conditions},
+		{"status.parentConditions", resSpec.Status.ParentConditions},
+		{"listenerStatus", resSpec.ListenerStatus},
+	}
+	for _, st := range statusTemplates {
+		if st.tmplStr != "" {
+			l.lintTemplate(sectionName+"."+st.name, sectionName+"."+st.name, st.tmplStr, knownResources)
+		}
+	}
+
+	sorted, err := sortTemplates(templates, resSpec.TemplateOptions)
+	if err != nil {
+		// Dependencies on templates which cannot be parsed are not reported
+		if !parseErrors {
+			rule := LintRuleUnknownDependency
+			if errors.Is(err, errDependencyCycle) {
+				rule = LintRuleDependencyCycle
+			}
+			l.add(sectionName, rule, "%v", err)
+		}
+		sorted = templates
+	}
+	l.lintRendered(sectionName, sorted, rtType)
+}
+
+// Parse a template and check references and functions used. Returns
+// nil if the template cannot be parsed
+func (l *blueprintLinter) lintTemplate(fullName, tmplKey, tmplStr string, knownResources map[string]bool) *template.Template {
+	tmpl, err := parseSingleTemplate(tmplKey, tmplStr)
+	if err != nil {
+		l.add(fullName, LintRuleParse, "%v", err)
+		return nil
+	}
+
+	for _, ref := range templateReferences(tmpl) {
+		if !knownResources[ref] {
+			l.add(fullName, LintRuleUnknownResource, "reference to unknown template %q through '.Resources'", ref)
+		}
+	}
+
+	unknownValues := map[string]bool{}
+	functions := map[string]bool{}
+	walkTemplate(tmpl, func(node parse.Node) {
+		if n, ok := node.(*parse.IdentifierNode); ok {
+			if _, found := dangerousFunctions[n.Ident]; found {
+				functions[n.Ident] = true
+			}
+			return
+		}
+		ident, ok := rootFieldIdent(node)
+		if !ok || len(ident) < 2 || ident[0] != "Values" {
+			return
+		}
+		if !l.valueDefined(ident[1:]) {
+			unknownValues[".Values."+strings.Join(ident[1:], ".")] = true
+		}
+	})
+	for _, path := range sortedKeys(unknownValues) {
+		l.add(fullName, LintRuleUnknownValue, "value %q is defined by neither values nor values schema of the blueprint", path)
+	}
+	for _, fn := range sortedKeys(functions) {
+		l.add(fullName, LintRuleDangerousFunction, "function %q %s", fn, dangerousFunctions[fn])
+	}
+	return tmpl
+}
+
+// Whether a path of values is defined by the values of the blueprint,
+// its values schema or a 'valuesFrom' source
+func (l *blueprintLinter) valueDefined(path []string) bool {
+	var val any = l.values
+	for idx, key := range path {
+		m, ok := val.(map[string]any)
+		if !ok {
+			// Fields of non-object values are not our concern
+			return idx > 0
+		}
+		if val, ok = m[key]; !ok {
+			break
+		}
+		if idx == len(path)-1 {
+			return true
+		}
+	}
+
+	if l.schema != nil && schemaDefines(l.schema, path) {
+		return true
+	}
+
+	for _, src := range l.gwcb.Spec.Values.ValuesFrom {
+		// Without a key, all keys of the source are values
+		if src.TargetPath == "" {
+			if src.Key == "" {
+				return true
+			}
+			continue
+		}
+		target := strings.Split(src.TargetPath, ".")
+		if len(path) >= len(target) && strings.Join(path[:len(target)], ".") == src.TargetPath {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether a path of values is defined by a schema. Objects allowing
+// additional properties and schemas without properties define all
+// paths below them
+func schemaDefines(s *spec.Schema, path []string) bool {
+	for idx, key := range path {
+		if prop, found := s.Properties[key]; found {
+			s = &prop
+			continue
+		}
+		for _, sub := range append(append(append([]spec.Schema{}, s.AllOf...), s.AnyOf...), s.OneOf...) {
+			if schemaDefines(&sub, path[idx:]) {
+				return true
+			}
+		}
+		if s.AdditionalProperties != nil {
+			if s.AdditionalProperties.Schema != nil {
+				s = s.AdditionalProperties.Schema
+				continue
+			}
+			return s.AdditionalProperties.Allows
+		}
+		return len(s.Properties) == 0 && len(s.AllOf)+len(s.AnyOf)+len(s.OneOf) == 0
+	}
+	return true
+}
+
+// Render resource templates, sorted in dependency order, for an
+// example parent and check the rendered resources. Rendered
+// resources are available to later templates through '.Resources'
+func (l *blueprintLinter) lintRendered(sectionName string, templates []*ResourceTemplateState, rtType *routeType) {
+	values, parentKind, parentNamespace := l.exampleTemplateValues(rtType)
+	values.Resources = map[string]any{}
+
+	for _, tmpl := range templates {
+		fullName := sectionName + ".resourceTemplates." + tmpl.TemplateName
+		var buffer bytes.Buffer
+		if err := tmpl.Template.Execute(&buffer, values); err != nil {
+			continue // Cannot render with example values
+		}
+		docs, err := splitDocuments(buffer.Bytes())
+		if err != nil {
+			l.add(fullName, LintRuleInvalidResource, "rendered resources are not valid YAML: %v", err)
+			continue
+		}
+		resources := make([]map[string]any, 0, len(docs))
+		for idx, doc := range docs {
+			resources = append(resources, doc)
+			res := &unstructured.Unstructured{Object: doc}
+			missing := []string{}
+			if res.GetAPIVersion() == "" {
+				missing = append(missing, "apiVersion")
+			}
+			if res.GetKind() == "" {
+				missing = append(missing, "kind")
+			}
+			if res.GetName() == "" {
+				missing = append(missing, "metadata.name")
+			}
+			if len(missing) > 0 {
+				l.add(fullName, LintRuleInvalidResource, "rendered resource %d has no %s", idx+1, strings.Join(missing, ", "))
+				continue
+			}
+			gk := res.GroupVersionKind().GroupKind()
+			if ns := res.GetNamespace(); ns != "" && ns != parentNamespace && !l.clusterScoped[gk] {
+				l.add(fullName, LintRuleHardcodedNamespace, "%s %q has namespace %q instead of the namespace of the parent %s",
+					res.GetKind(), res.GetName(), ns, parentKind)
+			}
+		}
+		values.Resources[tmpl.TemplateName] = resources
+	}
+}
+
+// Template values for an example Gateway and, if the route type is
+// non-nil, an example route attached to it. Returns the values and
+// the kind and namespace of the parent of rendered resources
+func (l *blueprintLinter) exampleTemplateValues(rtType *routeType) (*TemplateValues, string, string) {
+	values := exampleSchemaValue(l.schema)
+	exampleValues, ok := values.(map[string]any)
+	if !ok {
+		exampleValues = map[string]any{}
+	}
+	for _, src := range l.gwcb.Spec.Values.ValuesFrom {
+		if src.Key != "" && src.TargetPath != "" {
+			exampleValues = merge(exampleValues, valueWithPath(src.TargetPath, "example"), nil, nil).(map[string]any)
+		}
+	}
+	exampleValues = merge(exampleValues, l.values, nil, nil).(map[string]any)
+
+	gateway := map[string]any{
+		"apiVersion": "gateway.networking.k8s.io/v1",
+		"kind":       "Gateway",
+		"metadata": map[string]any{
+			"name":        "lint-gateway",
+			"namespace":   lintGatewayNamespace,
+			"uid":         "00000000-0000-0000-0000-000000000000",
+			"labels":      map[string]any{"app": "lint"},
+			"annotations": map[string]any{"example.com/lint": "true"},
+		},
+		"spec": map[string]any{
+			"gatewayClassName": "lint-gateway-class",
+			"listeners": []any{
+				map[string]any{"name": "http", "port": int64(80), "protocol": "HTTP", "hostname": "example.com"},
+			},
+		},
+		"status": map[string]any{},
+	}
+	tmplValues := &TemplateValues{
+		Gateway: &gateway,
+		Values:  exampleValues,
+		Hostnames: TemplateHostnameValues{
+			Union:        []string{"example.com"},
+			Intersection: []string{"example.com"},
+		},
+		ResolvedCertificateRefs: map[string][]map[string]any{},
+	}
+	if rtType == nil {
+		return tmplValues, "Gateway", lintGatewayNamespace
+	}
+
+	backendRef := map[string]any{"group": "", "kind": "Service", "name": "lint-backend", "namespace": lintRouteNamespace, "port": int64(80)}
+	routeSpec := map[string]any{
+		"parentRefs": []any{
+			map[string]any{"group": "gateway.networking.k8s.io", "kind": "Gateway", "namespace": lintGatewayNamespace, "name": "lint-gateway"},
+		},
+		"rules": []any{
+			map[string]any{"backendRefs": []any{backendRef}},
+		},
+	}
+	if rtType.HasHostnames {
+		routeSpec["hostnames"] = []any{"example.com"}
+	}
+	route := map[string]any{
+		"apiVersion": rtType.GroupVersion.String(),
+		"kind":       rtType.Kind,
+		"metadata": map[string]any{
+			"name":        "lint-route",
+			"namespace":   lintRouteNamespace,
+			"uid":         "00000000-0000-0000-0000-000000000001",
+			"labels":      map[string]any{"app": "lint"},
+			"annotations": map[string]any{"example.com/lint": "true"},
+		},
+		"spec":   routeSpec,
+		"status": map[string]any{},
+	}
+	rtType.SetTemplateValue(tmplValues, route)
+	tmplValues.ResolvedBackends = [][]map[string]any{{backendRef}}
+	return tmplValues, rtType.Kind, lintRouteNamespace
+}
+
+// Example value valid for a schema, e.g. the default value or an
+// object with example values for all properties. Returns nil for a
+// nil schema
+func exampleSchemaValue(s *spec.Schema) any {
+	if s == nil {
+		return nil
+	}
+	if s.Default != nil {
+		return s.Default
+	}
+	if len(s.Enum) > 0 {
+		return s.Enum[0]
+	}
+	switch {
+	case s.Type.Contains("object") || len(s.Properties) > 0:
+		obj := map[string]any{}
+		for name, prop := range s.Properties {
+			if val := exampleSchemaValue(&prop); val != nil {
+				obj[name] = val
+			}
+		}
+		return obj
+	case s.Type.Contains("array"):
+		if s.Items != nil && s.Items.Schema != nil {
+			if val := exampleSchemaValue(s.Items.Schema); val != nil {
+				return []any{val}
+			}
+		}
+		return []any{}
+	case s.Type.Contains("string"):
+		return "example"
+	case s.Type.Contains("integer"):
+		return int64(1)
+	case s.Type.Contains("number"):
+		return float64(1)
+	case s.Type.Contains("boolean"):
+		return true
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+)
+
+const lintTestBlueprint = `
+apiVersion: gateway.tv2.dk/v1alpha1
+kind: GatewayClassBlueprint
+metadata:
+  name: blueprint
+spec:
+  values:
+    default:
+      region: eu-north-1
+      tags:
+        team: a
+    valuesFrom:
+    - kind: Secret
+      name: credentials
+      key: token
+      targetPath: auth.token
+  valuesSchema:
+    type: object
+    properties:
+      region:
+        type: string
+      subnets:
+        type: array
+        items:
+          type: string
+      labels:
+        type: object
+        additionalProperties:
+          type: string
+  gatewayTemplate:
+    status:
+      template: |
+        {{ (index .Resources.bucket 0).status.arn }} {{ .Resources.unknownStatus }}
+    resourceTemplates:
+      bucket: |
+        apiVersion: s3.example.com/v1
+        kind: Bucket
+        metadata:
+          name: {{ .Gateway.metadata.name }}
+          namespace: {{ .Gateway.metadata.namespace }}
+        spec:
+          region: {{ .Values.region }}
+          subnets: {{ toYaml .Values.subnets | nindent 4 }}
+          team: {{ .Values.tags.team }}
+          token: {{ .Values.auth.token }}
+      config: |
+        apiVersion: v1
+        kind: ConfigMap
+        metadata:
+          name: {{ (index .Resources.bucket 0).metadata.name }}
+          namespace: kube-system
+      missingName: |
+        apiVersion: v1
+        kind: ConfigMap
+        metadata:
+          namespace: {{ .Gateway.metadata.namespace }}
+      clusterScoped: |
+        apiVersion: v1
+        kind: Namespace
+        metadata:
+          name: {{ .Gateway.metadata.name }}
+          namespace: other
+      extra: |
+        zone: {{ .Values.zone }}
+        owner: {{ .Values.labels.owner }}
+        host: {{ env "HOSTNAME" }}
+      unknown: |
+        {{ .Resources.notFound }}
+      broken: |
+        {{ .Values.region
+  httpRouteTemplate:
+    resourceTemplates:
+      a: |
+        {{ .Resources.b }}
+      b: |
+        {{ .Resources.a }}
+      route: |
+        apiVersion: v1
+        kind: ConfigMap
+        metadata:
+          name: {{ .HTTPRoute.metadata.name }}
+          namespace: {{ .Gateway.metadata.namespace }}
+  tcpRouteTemplate:
+    templateOptions:
+      route:
+        dependsOn: [missing]
+    resourceTemplates:
+      route: |
+        apiVersion: v1
+        kind: ConfigMap
+        metadata:
+          name: {{ .TCPRoute.metadata.name }}
+          namespace: {{ .TCPRoute.metadata.namespace }}
+`
+
+func TestLintBlueprint(t *testing.T) {
+	obj := helperDecodeManifests(t, lintTestBlueprint)[0]
+	var gwcb gwcapi.GatewayClassBlueprint
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &gwcb); err != nil {
+		t.Fatal(err)
+	}
+
+	findings := LintBlueprint(&gwcb, nil)
+	found := [][2]string{}
+	for _, f := range findings {
+		found = append(found, [2]string{f.Template, f.Rule})
+	}
+	expected := [][2]string{
+		{"gatewayTemplate.resourceTemplates.extra", LintRuleDangerousFunction},
+		{"gatewayTemplate.resourceTemplates.extra", LintRuleUnknownValue},
+		{"gatewayTemplate.resourceTemplates.config", LintRuleHardcodedNamespace},
+		{"gatewayTemplate.resourceTemplates.missingName", LintRuleInvalidResource},
+		{"gatewayTemplate.resourceTemplates.unknown", LintRuleUnknownResource},
+		{"gatewayTemplate.resourceTemplates.broken", LintRuleParse},
+		{"gatewayTemplate.status.template", LintRuleUnknownResource},
+		{"httpRouteTemplate", LintRuleDependencyCycle},
+		{"httpRouteTemplate.resourceTemplates.route", LintRuleHardcodedNamespace},
+		{"tcpRouteTemplate", LintRuleUnknownDependency},
+	}
+	if len(found) != len(expected) {
+		t.Fatalf("got findings %v, expected %v", findings, expected)
+	}
+	for _, exp := range expected {
+		matched := false
+		for _, f := range found {
+			matched = matched || f == exp
+		}
+		if !matched {
+			t.Errorf("missing finding %v in %v", exp, findings)
+		}
+	}
+	for _, f := range findings {
+		if f.Rule == LintRuleUnknownValue && f.Message != `value ".Values.zone" is defined by neither values nor values schema of the blueprint` {
+			t.Errorf("unexpected message %q", f.Message)
+		}
+	}
+
+	// Namespaced kinds are only known from the API server
+	findings = LintBlueprint(&gwcb, []schema.GroupKind{{Group: "", Kind: "ConfigMap"}})
+	for _, f := range findings {
+		if f.Rule == LintRuleHardcodedNamespace {
+			t.Errorf("unexpected finding %v for cluster scoped kind", f)
+		}
+	}
+}
+
+func TestSchemaDefines(t *testing.T) {
+	s, err := parseValuesSchema(&apiextensionsv1.JSON{Raw: []byte(`{
+	  "type": "object",
+	  "properties": {
+	    "a": {"type": "object", "properties": {"b": {"type": "string"}}},
+	    "open": {"type": "object"},
+	    "tags": {"type": "object", "additionalProperties": {"type": "string"}},
+	    "closed": {"type": "object", "additionalProperties": false},
+	    "oneOf": {"oneOf": [{"properties": {"x": {}}}, {"properties": {"y": {}}}]}
+	  }
+	}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		path    []string
+		defines bool
+	}{
+		{[]string{"a"}, true},
+		{[]string{"a", "b"}, true},
+		{[]string{"a", "c"}, false},
+		{[]string{"open", "c"}, true},
+		{[]string{"tags", "team"}, true},
+		{[]string{"closed", "c"}, false},
+		{[]string{"oneOf", "y"}, true},
+		{[]string{"oneOf", "z"}, false},
+		{[]string{"unknown"}, false},
+	}
+	for idx, tcase := range cases {
+		if defines := schemaDefines(s, tcase.path); defines != tcase.defines {
+			t.Errorf("Case %v, path %v, got %v, expected %v", idx, tcase.path, defines, tcase.defines)
+		}
+	}
+
+	example, ok := exampleSchemaValue(s).(map[string]any)
+	if !ok || !reflect.DeepEqual(example["a"], map[string]any{"b": "example"}) || !reflect.DeepEqual(example["open"], map[string]any{}) {
+		t.Errorf("unexpected example values %v", example)
+	}
+}
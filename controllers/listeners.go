@@ -0,0 +1,251 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+// Compute listener status of a Gateway. Listener status is kept in
+// the order of listeners in the Gateway spec and status of listeners
+// no longer in the spec is removed.
+// Conditions rendered from the listenerStatus template replace the
+// computed conditions of the same type unless the computed condition is
+// not 'True', i.e. templates may only mark listeners as not ready.
+func updateListenerStatus(gw *gatewayapi.Gateway, kinds map[gatewayapi.SectionName][]gatewayapi.RouteGroupKind,
+	attachment *gatewayAttachment, certRefs *resolvedCertificateRefs, gwProgrammed bool,
+	tmplConditions map[gatewayapi.SectionName][]metav1.Condition) {
+	conflicts := listenerConflicts(gw.Spec.Listeners)
+	listeners := make([]gatewayapi.ListenerStatus, 0, len(gw.Spec.Listeners))
+	for idx := range gw.Spec.Listeners {
+		listener := &gw.Spec.Listeners[idx]
+		status := gatewayapi.ListenerStatus{Name: listener.Name}
+		for _, existing := range gw.Status.Listeners { // Keep existing conditions for transition times
+			if existing.Name == listener.Name {
+				status = *existing.DeepCopy()
+				break
+			}
+		}
+		status.SupportedKinds = kinds[listener.Name]
+		status.AttachedRoutes = attachment.AttachedRoutes[listener.Name]
+
+		accepted := metav1.Condition{
+			Type:   string(gatewayapi.ListenerConditionAccepted),
+			Status: metav1.ConditionTrue,
+			Reason: string(gatewayapi.ListenerReasonAccepted),
+		}
+		if _, found := protocolRouteKinds[listener.Protocol]; !found {
+			accepted.Status = metav1.ConditionFalse
+			accepted.Reason = string(gatewayapi.ListenerReasonUnsupportedProtocol)
+			accepted.Message = fmt.Sprintf("protocol %s not supported", listener.Protocol)
+		}
+
+		conflicted := metav1.Condition{
+			Type:   string(gatewayapi.ListenerConditionConflicted),
+			Status: metav1.ConditionFalse,
+			Reason: string(gatewayapi.ListenerReasonNoConflicts),
+		}
+		if reason, found := conflicts[listener.Name]; found {
+			conflicted.Status = metav1.ConditionTrue
+			conflicted.Reason = string(reason)
+		}
+
+		resolvedRefs := listenerResolvedRefsCondition(listener, kinds[listener.Name], certRefs.NotPermitted[listener.Name])
+		resolvedRefs = templateCondition(resolvedRefs, tmplConditions[listener.Name])
+
+		programmed := metav1.Condition{
+			Type:   string(gatewayapi.ListenerConditionProgrammed),
+			Status: metav1.ConditionTrue,
+			Reason: string(gatewayapi.ListenerReasonProgrammed),
+		}
+		switch {
+		case accepted.Status != metav1.ConditionTrue || conflicted.Status != metav1.ConditionFalse ||
+			resolvedRefs.Status != metav1.ConditionTrue:
+			programmed.Status = metav1.ConditionFalse
+			programmed.Reason = string(gatewayapi.ListenerReasonInvalid)
+		case !gwProgrammed:
+			programmed.Status = metav1.ConditionFalse
+			programmed.Reason = string(gatewayapi.ListenerReasonPending)
+		}
+		programmed = templateCondition(programmed, tmplConditions[listener.Name])
+
+		for _, cond := range []metav1.Condition{accepted, conflicted, resolvedRefs, programmed} {
+			cond.ObservedGeneration = gw.ObjectMeta.Generation
+			meta.SetStatusCondition(&status.Conditions, cond)
+		}
+		listeners = append(listeners, status)
+	}
+	gw.Status.Listeners = listeners
+}
+
+// Replace a computed 'True' condition with a condition of the same type rendered from a template
+func templateCondition(computed metav1.Condition, tmplConditions []metav1.Condition) metav1.Condition {
+	if computed.Status != metav1.ConditionTrue {
+		return computed
+	}
+	if cond := meta.FindStatusCondition(tmplConditions, computed.Type); cond != nil {
+		return *cond
+	}
+	return computed
+}
+
+// Result of rendering the listenerStatus template for a listener
+type listenerStatusTemplateResult struct {
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// Render the listenerStatus template once per listener with the
+// listener available as '.Listener'. Returns conditions by listener name
+func renderListenerConditions(tmplStr string, gw *gatewayapi.Gateway, values *TemplateValues) (map[gatewayapi.SectionName][]metav1.Condition, error) {
+	conditions := map[gatewayapi.SectionName][]metav1.Condition{}
+	for idx := range gw.Spec.Listeners {
+		listener := &gw.Spec.Listeners[idx]
+		listenerValues := *values
+		var err error
+		if listenerValues.Listener, err = runtime.DefaultUnstructuredConverter.ToUnstructured(listener); err != nil {
+			return nil, fmt.Errorf("cannot convert listener %s: %w", listener.Name, err)
+		}
+		var result listenerStatusTemplateResult
+		if err := renderStatusTemplate("listenerStatus", tmplStr, &listenerValues, &result); err != nil {
+			return nil, fmt.Errorf("listener %s: %w", listener.Name, err)
+		}
+		for _, cond := range result.Conditions {
+			if cond.Type != string(gatewayapi.ListenerConditionProgrammed) && cond.Type != string(gatewayapi.ListenerConditionResolvedRefs) {
+				return nil, fmt.Errorf("%w: listener %s: condition type %q cannot be set from template", errInvalidStatus, listener.Name, cond.Type)
+			}
+		}
+		if err := validateConditions(result.Conditions, nil); err != nil {
+			return nil, fmt.Errorf("%w: listener %s: %w", errInvalidStatus, listener.Name, err)
+		}
+		if len(result.Conditions) > 0 {
+			conditions[listener.Name] = result.Conditions
+		}
+	}
+	return conditions, nil
+}
+
+// Find conflicting listeners, i.e. listeners using the same port with
+// conflicting protocols or with the same hostname. Returns conflict
+// reason by listener name
+func listenerConflicts(listeners []gatewayapi.Listener) map[gatewayapi.SectionName]gatewayapi.ListenerConditionReason {
+	conflicts := map[gatewayapi.SectionName]gatewayapi.ListenerConditionReason{}
+	for i := range listeners {
+		for j := i + 1; j < len(listeners); j++ {
+			a, b := &listeners[i], &listeners[j]
+			if a.Port != b.Port || isUDP(a.Protocol) != isUDP(b.Protocol) {
+				continue
+			}
+			var reason gatewayapi.ListenerConditionReason
+			switch {
+			case !protocolsCompatible(a.Protocol, b.Protocol):
+				reason = gatewayapi.ListenerReasonProtocolConflict
+			case derefCmp(a.Hostname, b.Hostname):
+				reason = gatewayapi.ListenerReasonHostnameConflict
+			default:
+				continue
+			}
+			for _, name := range []gatewayapi.SectionName{a.Name, b.Name} {
+				// Protocol conflicts take precedence over hostname conflicts
+				if conflicts[name] != gatewayapi.ListenerReasonProtocolConflict {
+					conflicts[name] = reason
+				}
+			}
+		}
+	}
+	return conflicts
+}
+
+func isUDP(protocol gatewayapi.ProtocolType) bool {
+	return protocol == gatewayapi.UDPProtocolType
+}
+
+// Whether listeners with two protocols may share a port. HTTPS and
+// TLS listeners may share a port since both are distinguished by SNI
+func protocolsCompatible(a, b gatewayapi.ProtocolType) bool {
+	tlsBased := func(p gatewayapi.ProtocolType) bool {
+		return p == gatewayapi.HTTPSProtocolType || p == gatewayapi.TLSProtocolType
+	}
+	return a == b || (tlsBased(a) && tlsBased(b))
+}
+
+// Listener ResolvedRefs condition from allowed route kinds and
+// certificate references not permitted by ReferenceGrants
+func listenerResolvedRefsCondition(listener *gatewayapi.Listener, supported []gatewayapi.RouteGroupKind,
+	notPermitted []gatewayapi.SecretObjectReference) metav1.Condition {
+	if listener.AllowedRoutes != nil {
+		invalid := []string{}
+		for _, rgk := range listener.AllowedRoutes.Kinds {
+			group := gatewayapi.Group(gatewayapi.GroupName)
+			if rgk.Group != nil {
+				group = *rgk.Group
+			}
+			found := false
+			for _, s := range supported {
+				found = found || (*s.Group == group && s.Kind == rgk.Kind)
+			}
+			if !found {
+				invalid = append(invalid, string(rgk.Kind))
+			}
+		}
+		if len(invalid) > 0 {
+			return metav1.Condition{
+				Type:    string(gatewayapi.ListenerConditionResolvedRefs),
+				Status:  metav1.ConditionFalse,
+				Reason:  string(gatewayapi.ListenerReasonInvalidRouteKinds),
+				Message: fmt.Sprintf("route kinds not supported: %s", strings.Join(invalid, ",")),
+			}
+		}
+	}
+	if len(notPermitted) > 0 {
+		refs := []string{}
+		for _, ref := range notPermitted {
+			refs = append(refs, fmt.Sprintf("%s/%s/%s", *ref.Kind, *ref.Namespace, ref.Name))
+		}
+		return metav1.Condition{
+			Type:    string(gatewayapi.ListenerConditionResolvedRefs),
+			Status:  metav1.ConditionFalse,
+			Reason:  string(gatewayapi.ListenerReasonRefNotPermitted),
+			Message: fmt.Sprintf("certificate references not permitted by ReferenceGrants: %s", strings.Join(refs, ",")),
+		}
+	}
+	return metav1.Condition{
+		Type:   string(gatewayapi.ListenerConditionResolvedRefs),
+		Status: metav1.ConditionTrue,
+		Reason: string(gatewayapi.ListenerReasonResolvedRefs),
+	}
+}
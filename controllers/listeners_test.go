@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+func TestListenerConflicts(t *testing.T) {
+	listener := func(name string, port gatewayapi.PortNumber, protocol gatewayapi.ProtocolType, hostname string) gatewayapi.Listener {
+		l := gatewayapi.Listener{Name: gatewayapi.SectionName(name), Port: port, Protocol: protocol}
+		if hostname != "" {
+			l.Hostname = PtrTo(gatewayapi.Hostname(hostname))
+		}
+		return l
+	}
+	listeners := []gatewayapi.Listener{
+		listener("http-foo", 80, gatewayapi.HTTPProtocolType, "foo.example.com"),
+		listener("http-bar", 80, gatewayapi.HTTPProtocolType, "bar.example.com"),
+		listener("http-foo-dup", 80, gatewayapi.HTTPProtocolType, "foo.example.com"),
+		listener("https", 443, gatewayapi.HTTPSProtocolType, "foo.example.com"),
+		listener("tls", 443, gatewayapi.TLSProtocolType, "bar.example.com"),
+		listener("tcp", 8080, gatewayapi.TCPProtocolType, ""),
+		listener("http-8080", 8080, gatewayapi.HTTPProtocolType, ""),
+		listener("udp", 8080, gatewayapi.UDPProtocolType, ""),
+		listener("tcp-any", 9000, gatewayapi.TCPProtocolType, ""),
+		listener("tcp-any-dup", 9000, gatewayapi.TCPProtocolType, ""),
+	}
+
+	expected := map[gatewayapi.SectionName]gatewayapi.ListenerConditionReason{
+		"http-foo":     gatewayapi.ListenerReasonHostnameConflict,
+		"http-foo-dup": gatewayapi.ListenerReasonHostnameConflict,
+		"tcp":          gatewayapi.ListenerReasonProtocolConflict,
+		"http-8080":    gatewayapi.ListenerReasonProtocolConflict,
+		"tcp-any":      gatewayapi.ListenerReasonHostnameConflict,
+		"tcp-any-dup":  gatewayapi.ListenerReasonHostnameConflict,
+	}
+	if conflicts := listenerConflicts(listeners); !reflect.DeepEqual(conflicts, expected) {
+		t.Errorf("listenerConflicts() got %v, expected %v", conflicts, expected)
+	}
+}
+
+func TestUpdateListenerStatus(t *testing.T) {
+	httpRouteKind := []gatewayapi.RouteGroupKind{{Group: PtrTo(gatewayapi.Group(gatewayapi.GroupName)), Kind: "HTTPRoute"}}
+	gw := &gatewayapi.Gateway{
+		ObjectMeta: metav1.ObjectMeta{Name: "gw", Namespace: "default", Generation: 2},
+		Spec: gatewayapi.GatewaySpec{
+			Listeners: []gatewayapi.Listener{
+				{Name: "http", Port: 80, Protocol: gatewayapi.HTTPProtocolType},
+				{Name: "https", Port: 443, Protocol: gatewayapi.HTTPSProtocolType,
+					AllowedRoutes: &gatewayapi.AllowedRoutes{Kinds: []gatewayapi.RouteGroupKind{{Kind: "TCPRoute"}}}},
+				{Name: "other", Port: 8080, Protocol: "example.com/other"},
+			},
+		},
+		Status: gatewayapi.GatewayStatus{
+			Listeners: []gatewayapi.ListenerStatus{
+				{Name: "removed"},
+				{Name: "http", Conditions: []metav1.Condition{{
+					Type:               string(gatewayapi.ListenerConditionAccepted),
+					Status:             metav1.ConditionTrue,
+					Reason:             string(gatewayapi.ListenerReasonAccepted),
+					LastTransitionTime: metav1.Unix(1000, 0),
+				}}},
+			},
+		},
+	}
+	kinds := map[gatewayapi.SectionName][]gatewayapi.RouteGroupKind{"http": httpRouteKind, "https": httpRouteKind}
+	attachment := &gatewayAttachment{AttachedRoutes: map[gatewayapi.SectionName]int32{"http": 3}}
+	certRefs := &resolvedCertificateRefs{NotPermitted: map[gatewayapi.SectionName][]gatewayapi.SecretObjectReference{}}
+
+	updateListenerStatus(gw, kinds, attachment, certRefs, true, nil)
+
+	names := []gatewayapi.SectionName{}
+	for _, l := range gw.Status.Listeners {
+		names = append(names, l.Name)
+	}
+	if !reflect.DeepEqual(names, []gatewayapi.SectionName{"http", "https", "other"}) {
+		t.Fatalf("listener status names got %v", names)
+	}
+
+	expectReason := func(idx int, condType gatewayapi.ListenerConditionType, status metav1.ConditionStatus, reason gatewayapi.ListenerConditionReason) {
+		t.Helper()
+		cond := meta.FindStatusCondition(gw.Status.Listeners[idx].Conditions, string(condType))
+		if cond == nil || cond.Status != status || cond.Reason != string(reason) || cond.ObservedGeneration != 2 {
+			t.Errorf("listener %s condition %s got %+v, expected %s/%s", gw.Status.Listeners[idx].Name, condType, cond, status, reason)
+		}
+	}
+
+	http := gw.Status.Listeners[0]
+	if http.AttachedRoutes != 3 || !reflect.DeepEqual(http.SupportedKinds, httpRouteKind) {
+		t.Errorf("unexpected listener status %+v", http)
+	}
+	if cond := meta.FindStatusCondition(http.Conditions, string(gatewayapi.ListenerConditionAccepted)); !cond.LastTransitionTime.Equal(PtrTo(metav1.Unix(1000, 0))) {
+		t.Errorf("transition time not preserved: %v", cond.LastTransitionTime)
+	}
+	expectReason(0, gatewayapi.ListenerConditionAccepted, metav1.ConditionTrue, gatewayapi.ListenerReasonAccepted)
+	expectReason(0, gatewayapi.ListenerConditionConflicted, metav1.ConditionFalse, gatewayapi.ListenerReasonNoConflicts)
+	expectReason(0, gatewayapi.ListenerConditionResolvedRefs, metav1.ConditionTrue, gatewayapi.ListenerReasonResolvedRefs)
+	expectReason(0, gatewayapi.ListenerConditionProgrammed, metav1.ConditionTrue, gatewayapi.ListenerReasonProgrammed)
+
+	expectReason(1, gatewayapi.ListenerConditionResolvedRefs, metav1.ConditionFalse, gatewayapi.ListenerReasonInvalidRouteKinds)
+	expectReason(1, gatewayapi.ListenerConditionProgrammed, metav1.ConditionFalse, gatewayapi.ListenerReasonInvalid)
+
+	expectReason(2, gatewayapi.ListenerConditionAccepted, metav1.ConditionFalse, gatewayapi.ListenerReasonUnsupportedProtocol)
+	expectReason(2, gatewayapi.ListenerConditionProgrammed, metav1.ConditionFalse, gatewayapi.ListenerReasonInvalid)
+
+	// Listeners are pending until the Gateway is programmed
+	updateListenerStatus(gw, kinds, attachment, certRefs, false, nil)
+	expectReason(0, gatewayapi.ListenerConditionProgrammed, metav1.ConditionFalse, gatewayapi.ListenerReasonPending)
+}
+
+func TestRenderListenerConditions(t *testing.T) {
+	gw := &gatewayapi.Gateway{
+		ObjectMeta: metav1.ObjectMeta{Name: "gw", Namespace: "default"},
+		Spec: gatewayapi.GatewaySpec{
+			Listeners: []gatewayapi.Listener{
+				{Name: "http", Port: 80, Protocol: gatewayapi.HTTPProtocolType},
+				{Name: "https", Port: 443, Protocol: gatewayapi.HTTPSProtocolType},
+			},
+		},
+	}
+	values := &TemplateValues{Resources: map[string]any{
+		"Certificate": []map[string]any{{"status": map[string]any{"ready": false}}},
+	}}
+	tmpl := `
+{{- if and (eq .Listener.protocol "HTTPS") (not (index .Resources.Certificate 0).status.ready) }}
+conditions:
+- type: Programmed
+  status: "False"
+  reason: Pending
+  message: certificate not issued
+{{- end }}`
+
+	conditions, err := renderListenerConditions(tmpl, gw, values)
+	if err != nil {
+		t.Fatalf("renderListenerConditions() failed: %v", err)
+	}
+	if _, found := conditions["http"]; found {
+		t.Errorf("unexpected conditions for listener 'http': %v", conditions["http"])
+	}
+	if len(conditions["https"]) != 1 || conditions["https"][0].Reason != "Pending" {
+		t.Errorf("unexpected conditions for listener 'https': %v", conditions["https"])
+	}
+
+	// Listener is not programmed even if Gateway is
+	kinds := map[gatewayapi.SectionName][]gatewayapi.RouteGroupKind{}
+	attachment := &gatewayAttachment{AttachedRoutes: map[gatewayapi.SectionName]int32{}}
+	certRefs := &resolvedCertificateRefs{NotPermitted: map[gatewayapi.SectionName][]gatewayapi.SecretObjectReference{}}
+	updateListenerStatus(gw, kinds, attachment, certRefs, true, conditions)
+	if cond := meta.FindStatusCondition(gw.Status.Listeners[0].Conditions, string(gatewayapi.ListenerConditionProgrammed)); cond.Status != metav1.ConditionTrue {
+		t.Errorf("listener 'http' condition got %+v", cond)
+	}
+	if cond := meta.FindStatusCondition(gw.Status.Listeners[1].Conditions, string(gatewayapi.ListenerConditionProgrammed)); cond.Status != metav1.ConditionFalse ||
+		cond.Message != "certificate not issued" {
+		t.Errorf("listener 'https' condition got %+v", cond)
+	}
+
+	for _, invalid := range []string{
+		"conditions:\n- type: Accepted\n  status: \"False\"\n  reason: Foo",
+		"conditions:\n- type: Programmed\n  status: \"Maybe\"\n  reason: Foo",
+		"conditions:\n- type: Programmed\n  status: \"False\"",
+	} {
+		if _, err := renderListenerConditions(invalid, gw, values); err == nil {
+			t.Errorf("expected error for template %q", invalid)
+		}
+	}
+}
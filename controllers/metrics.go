@@ -67,8 +67,28 @@ var (
 			Help: "Number of resources fetched to use as dependency in templates",
 		},
 	)
+	metricPrune = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "bifrost_prune_total",
+			Help: "Number of resources pruned because they are no longer rendered",
+		},
+	)
+	metricPruneErrs = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "bifrost_prune_errors_total",
+			Help: "Number of errors pruning resources",
+		},
+	)
+	metricDryRunChanges = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "bifrost_dryrun_changes_total",
+			Help: "Number of changes not done in dry-run mode",
+		},
+		[]string{"action"},
+	)
 )
 
 func init() {
-	metrics.Registry.MustRegister(metricPatchApply, metricPatchApplyErrs, metricTemplateErrs, metricResourceGet)
+	metrics.Registry.MustRegister(metricPatchApply, metricPatchApplyErrs, metricTemplateErrs, metricResourceGet,
+		metricPrune, metricPruneErrs, metricDryRunChanges)
 }
@@ -0,0 +1,577 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1a2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	gatewayv1b1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+)
+
+// Kinds which are cluster scoped. Without an API server the offline
+// renderer cannot discover the scope of kinds, and all other kinds are
+// assumed to be namespaced
+var clusterScopedKinds = []schema.GroupKind{
+	{Group: "", Kind: "Namespace"},
+	{Group: "", Kind: "Node"},
+	{Group: "", Kind: "PersistentVolume"},
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"},
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"},
+	{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"},
+	{Group: "storage.k8s.io", Kind: "StorageClass"},
+	{Group: "networking.k8s.io", Kind: "IngressClass"},
+	{Group: "scheduling.k8s.io", Kind: "PriorityClass"},
+	{Group: "admissionregistration.k8s.io", Kind: "MutatingWebhookConfiguration"},
+	{Group: "admissionregistration.k8s.io", Kind: "ValidatingWebhookConfiguration"},
+	{Group: "cert-manager.io", Kind: "ClusterIssuer"},
+	{Group: gatewayapi.GroupName, Kind: "GatewayClass"},
+	{Group: gwcapi.GroupVersion.Group, Kind: "GatewayClassBlueprint"},
+}
+
+// A REST mapper using a static mapping from kind to scope instead of
+// discovery. Resource names are guessed from kinds
+type staticRESTMapper struct {
+	*meta.DefaultRESTMapper
+	clusterScoped map[schema.GroupKind]bool
+}
+
+func newStaticRESTMapper(scheme *runtime.Scheme, clusterScoped []schema.GroupKind) *staticRESTMapper {
+	m := &staticRESTMapper{
+		DefaultRESTMapper: meta.NewDefaultRESTMapper(scheme.PrioritizedVersionsAllGroups()),
+		clusterScoped:     map[schema.GroupKind]bool{},
+	}
+	for _, gk := range append(clusterScopedKinds, clusterScoped...) {
+		m.clusterScoped[gk] = true
+	}
+	for gvk := range scheme.AllKnownTypes() {
+		m.Add(gvk, m.scope(gvk.GroupKind()))
+	}
+	return m
+}
+
+func (m *staticRESTMapper) scope(gk schema.GroupKind) meta.RESTScope {
+	if m.clusterScoped[gk] {
+		return meta.RESTScopeRoot
+	}
+	return meta.RESTScopeNamespace
+}
+
+// Map any kind with a known version, also kinds unknown to the scheme
+func (m *staticRESTMapper) RESTMapping(gk schema.GroupKind, versions ...string) (*meta.RESTMapping, error) {
+	if len(versions) == 0 || versions[0] == "" {
+		return m.DefaultRESTMapper.RESTMapping(gk, versions...)
+	}
+	gvk := gk.WithVersion(versions[0])
+	plural, _ := meta.UnsafeGuessKindToResource(gvk)
+	return &meta.RESTMapping{Resource: plural, GroupVersionKind: gvk, Scope: m.scope(gk)}, nil
+}
+
+func (m *staticRESTMapper) RESTMappings(gk schema.GroupKind, versions ...string) ([]*meta.RESTMapping, error) {
+	mapping, err := m.RESTMapping(gk, versions...)
+	if err != nil {
+		return nil, err
+	}
+	return []*meta.RESTMapping{mapping}, nil
+}
+
+// Resources rendered from a single template
+type OfflineRenderedTemplate struct {
+	// Name of template (from template key in GatewayClassBlueprint)
+	TemplateName string
+
+	// The rendered resources
+	Resources []*unstructured.Unstructured
+}
+
+// Outcome of rendering the templates of a Gateway, or the templates of
+// a route for one of its parent Gateways
+type OfflineRenderResult struct {
+	// Parent Gateway as 'namespace/name'
+	Gateway string
+
+	// Rendered templates in apply wave and dependency order. Templates
+	// which could not be rendered and templates of apply waves held
+	// back are not included
+	Templates []OfflineRenderedTemplate
+
+	// Errors from rendering templates, joined
+	TemplateErrors error
+
+	// Whether all templates were rendered and applied, i.e. whether
+	// the controller would prune child resources no longer rendered
+	Complete bool
+
+	// Rendered child resources as recorded in the inventory of the parent
+	Inventory []InventoryEntry
+
+	// Prune policy of the blueprint
+	PrunePolicy gwcapi.PrunePolicy
+
+	// Gateway status. Only set when rendering Gateway templates
+	GatewayStatus *gatewayapi.GatewayStatus
+
+	// Route status for the parent Gateway. Only set when rendering route templates
+	RouteParentStatus *gatewayapi.RouteParentStatus
+}
+
+// OfflineRenderer renders the templates of GatewayClassBlueprints for
+// Gateways and routes without an API server. Blueprints, Gateways,
+// routes, policies and other objects read by the controller are
+// served from an in-memory client. Optional 'current' resources stand
+// in for child resources in the API server, e.g. for templates using
+// the status of other resources through '.Resources'. BlueprintDiffer
+// uses the renderer with a client for an API server instead.
+type OfflineRenderer struct {
+	client  client.Client
+	scheme  *runtime.Scheme
+	current []*unstructured.Unstructured
+
+	// Apply a rendered resource and return the resulting
+	// resource. Defaults to simulateApply
+	apply func(ctx context.Context, res *ResourceComposite) (*unstructured.Unstructured, error)
+}
+
+func (o *OfflineRenderer) Client() client.Client {
+	return o.client
+}
+
+func (o *OfflineRenderer) Scheme() *runtime.Scheme {
+	return o.scheme
+}
+
+// Create an offline renderer from objects and current child
+// resources. Objects must be of kinds known to the controller,
+// e.g. GatewayClassBlueprints, Gateways, routes, policies,
+// ConfigMaps and Secrets. Kinds which are cluster scoped in addition
+// to the built-in list of cluster scoped kinds may be given through
+// clusterScoped.
+func NewOfflineRenderer(objs, current []*unstructured.Unstructured, clusterScoped []schema.GroupKind) (*OfflineRenderer, error) {
+	scheme := NewRendererScheme()
+	mapper := newStaticRESTMapper(scheme, clusterScoped)
+
+	typed := make([]client.Object, 0, len(objs))
+	for _, u := range objs {
+		u = u.DeepCopy()
+		u.SetGroupVersionKind(storageVersion(scheme, u.GroupVersionKind()))
+		obj, err := scheme.New(u.GroupVersionKind())
+		if err != nil {
+			return nil, fmt.Errorf("unsupported object %s %q: %w", u.GroupVersionKind(), u.GetName(), err)
+		}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, obj); err != nil {
+			return nil, fmt.Errorf("cannot convert %s %q: %w", u.GetKind(), u.GetName(), err)
+		}
+		cObj, ok := obj.(client.Object)
+		if !ok {
+			return nil, fmt.Errorf("unsupported object %s %q", u.GroupVersionKind(), u.GetName())
+		}
+		defaultNamespace(mapper, cObj, u.GroupVersionKind())
+		typed = append(typed, cObj)
+	}
+	for _, u := range current {
+		defaultNamespace(mapper, u, u.GroupVersionKind())
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithRESTMapper(mapper).WithObjects(typed...).Build()
+	return &OfflineRenderer{client: c, scheme: scheme, current: current}, nil
+}
+
+// Scheme with the kinds read by the controller, i.e. built-in kinds,
+// Gateway API kinds and our own kinds
+func NewRendererScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(gatewayapi.Install(scheme))
+	utilruntime.Must(gatewayv1a2.Install(scheme))
+	utilruntime.Must(gatewayv1b1.Install(scheme))
+	utilruntime.Must(gwcapi.AddToScheme(scheme))
+	return scheme
+}
+
+// Versions of Gateway API kinds read by the controller, in order of
+// preference. The API server converts between versions, e.g. v1beta1
+// and v1 Gateways, and the in-memory client does not
+var gatewayAPIVersions = []string{gatewayapi.GroupVersion.Version, gatewayv1b1.GroupVersion.Version, gatewayv1a2.GroupVersion.Version}
+
+// The version an object is stored in by the in-memory client
+func storageVersion(scheme *runtime.Scheme, gvk schema.GroupVersionKind) schema.GroupVersionKind {
+	if gvk.Group != gatewayapi.GroupName {
+		return gvk
+	}
+	for _, version := range gatewayAPIVersions {
+		if candidate := gvk.GroupKind().WithVersion(version); scheme.Recognizes(candidate) {
+			return candidate
+		}
+	}
+	return gvk
+}
+
+// Namespaced objects without namespace use the 'default' namespace, like kubectl
+func defaultNamespace(mapper *staticRESTMapper, obj client.Object, gvk schema.GroupVersionKind) {
+	if obj.GetNamespace() == "" && mapper.scope(gvk.GroupKind()) == meta.RESTScopeNamespace {
+		obj.SetNamespace("default")
+	}
+}
+
+// Lookup the GatewayClass and GatewayClassBlueprint of a Gateway
+func (o *OfflineRenderer) lookupBlueprint(ctx context.Context, gw *gatewayapi.Gateway) (*gatewayapi.GatewayClass, *gwcapi.GatewayClassBlueprint, error) {
+	gwc, err := lookupGatewayClass(ctx, o, gw.Spec.GatewayClassName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot lookup GatewayClass %q: %w", gw.Spec.GatewayClassName, err)
+	}
+	if !isOurGatewayClass(gwc) {
+		return nil, nil, fmt.Errorf("GatewayClass %q is not handled by this controller", gwc.Name)
+	}
+	gwcb, err := lookupGatewayClassBlueprint(ctx, o, gwc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parameters for GatewayClass %q not found: %w", gwc.Name, err)
+	}
+	return gwc, gwcb, nil
+}
+
+// Render the Gateway templates of the blueprint of a Gateway. Values
+// are looked up and validated, and status is computed, like the
+// Gateway controller does
+func (o *OfflineRenderer) RenderGateway(ctx context.Context, namespace, name string) (*OfflineRenderResult, error) {
+	gw, err := lookupGateway(ctx, o, gatewayapi.ObjectName(name), namespace)
+	if err != nil {
+		return nil, fmt.Errorf("cannot lookup Gateway: %w", err)
+	}
+	gwc, gwcb, err := o.lookupBlueprint(ctx, gw)
+	if err != nil {
+		return nil, err
+	}
+
+	rtTypes := availableRouteTypes(o.client.RESTMapper())
+	routes, err := lookupRoutes(ctx, o, rtTypes)
+	if err != nil {
+		return nil, fmt.Errorf("cannot look up routes: %w", err)
+	}
+	attacher, err := newGatewayAttacher(ctx, o, gw, gwcb, rtTypes)
+	if err != nil {
+		return nil, err
+	}
+	attachment := attacher.attachRoutes(routes)
+	union, isect := combineHostnames(gw, attachment.Hostnames)
+
+	gatewayMap, err := objectToMap(gw)
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert gateway to map: %w", err)
+	}
+	values, sources, err := o.lookupValidValues(ctx, gwc, gwcb, gw, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	grants, err := lookupReferenceGrants(ctx, o)
+	if err != nil {
+		return nil, err
+	}
+	certRefs := grants.resolveCertificateRefs(gw)
+	resolvedCertRefs, err := certRefs.templateValue()
+	if err != nil {
+		return nil, err
+	}
+
+	templateValues := TemplateValues{
+		Gateway:                 &gatewayMap,
+		Values:                  values,
+		sensitiveValues:         sensitiveValues(values, sources),
+		ResolvedCertificateRefs: resolvedCertRefs,
+		Hostnames: TemplateHostnameValues{
+			Union:        union,
+			Intersection: isect,
+		},
+	}
+	templates, renderResult, err := o.renderTemplates(ctx, gw, &gwcb.Spec.GatewayTemplate, &templateValues)
+	if err != nil {
+		return nil, err
+	}
+
+	result := o.newRenderResult(gw, gw, gwcb, templates, renderResult)
+	statusErr, err := updateGatewayStatus(gw, gwcb, templates, renderResult, attacher.kinds, attachment, certRefs, &templateValues)
+	if err != nil {
+		return nil, err
+	}
+	result.TemplateErrors = errors.Join(result.TemplateErrors, statusErr)
+	clearTransitionTimes(gw.Status.Conditions)
+	for idx := range gw.Status.Listeners {
+		clearTransitionTimes(gw.Status.Listeners[idx].Conditions)
+	}
+	result.GatewayStatus = &gw.Status
+	return result, nil
+}
+
+// Render the route templates of the blueprints of the parent Gateways
+// of a route. Values are looked up and validated, and parent status is
+// computed, like the route controller does. Parents the route is not
+// attached to are reported as errors
+func (o *OfflineRenderer) RenderRoute(ctx context.Context, kind, namespace, name string) ([]*OfflineRenderResult, error) {
+	var rtType *routeType
+	for _, t := range routeTypes {
+		if t.Kind == kind {
+			rtType = t
+		}
+	}
+	if rtType == nil {
+		return nil, fmt.Errorf("unsupported route kind %q", kind)
+	}
+	rt := rtType.NewRoute()
+	if err := o.client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, rt); err != nil {
+		return nil, fmt.Errorf("cannot lookup %s: %w", kind, err)
+	}
+
+	grants, err := lookupReferenceGrants(ctx, o)
+	if err != nil {
+		return nil, err
+	}
+	backends := grants.resolveBackends(rtType, rt)
+
+	rtMap, err := objectToMap(rt)
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert %s to map: %w", kind, err)
+	}
+	backends.removeNotPermitted(rtMap)
+	templateValues := TemplateValues{}
+	rtType.SetTemplateValue(&templateValues, rtMap)
+	if templateValues.ResolvedBackends, err = backends.templateValue(); err != nil {
+		return nil, err
+	}
+
+	results := []*OfflineRenderResult{}
+	var errs []error
+	for _, parent := range routeCommonSpec(rt).ParentRefs {
+		// Manifests from files are not defaulted by the API server
+		if parent.Kind != nil && *parent.Kind != gatewayapi.Kind("Gateway") {
+			continue
+		}
+		parent.Kind = PtrTo(gatewayapi.Kind("Gateway"))
+
+		gw, err := lookupParent(ctx, o, rt, parent)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("gateway %q for route not found: %w", parent.Name, err))
+			continue
+		}
+		gwc, gwcb, err := o.lookupBlueprint(ctx, gw)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		attacher, err := newGatewayAttacher(ctx, o, gw, gwcb, []*routeType{rtType})
+		if err != nil {
+			return nil, err
+		}
+		attachment := attacher.attachRoute(rt, &parent)
+		if !attachment.Accepted() {
+			errs = append(errs, fmt.Errorf("route not attached to gateway %s/%s: %s", gw.Namespace, gw.Name, attachment.Message))
+			continue
+		}
+
+		values, sources, err := o.lookupValidValues(ctx, gwc, gwcb, gw,
+			&routeValuesScope{route: rt, listeners: attachment.Listeners})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		templateValues.Values = values
+		templateValues.sensitiveValues = sensitiveValues(values, sources)
+		gatewayMap, err := objectToMap(gw)
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert gateway to map: %w", err)
+		}
+		templateValues.Gateway = &gatewayMap
+
+		tmplSpec := rtType.Template(&gwcb.Spec)
+		templates, renderResult, err := o.renderTemplates(ctx, rt, tmplSpec, &templateValues)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		result := o.newRenderResult(gw, rt, gwcb, templates, renderResult)
+
+		status := &gatewayapi.RouteStatus{}
+		setRouteStatusCondition(status, parent, resolvedRefsCondition(backends))
+		statusErr, err := updateRouteParentStatus(status, parent, rt.GetGeneration(), tmplSpec, templates, renderResult, &templateValues)
+		if err != nil {
+			return nil, err
+		}
+		result.TemplateErrors = errors.Join(result.TemplateErrors, statusErr)
+		result.RouteParentStatus = findParentRouteStatus(status, parent)
+		clearTransitionTimes(result.RouteParentStatus.Conditions)
+		results = append(results, result)
+	}
+	return results, errors.Join(errs...)
+}
+
+// Lookup values and validate them against the values schema of the blueprint
+func (o *OfflineRenderer) lookupValidValues(ctx context.Context, gwc *gatewayapi.GatewayClass, gwcb *gwcapi.GatewayClassBlueprint,
+	gw *gatewayapi.Gateway, scope *routeValuesScope) (map[string]any, valueSources, error) {
+	values, sources, err := lookupValuesWithSources(ctx, o, gwc.Name, gwcb, gw.Namespace, gw.Name, scope)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot lookup values: %w", err)
+	}
+	violations, err := validateValuesSchema(gwcb.Spec.ValuesSchema, values, sources)
+	if err == nil {
+		err = violationsError(violations)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid values for gateway %s/%s: %w", gw.Namespace, gw.Name, err)
+	}
+	return values, sources, nil
+}
+
+// Render templates like renderAndApplyTemplates, except that
+// resources are not applied. Applying a resource is simulated using
+// the status of the matching current resource given to the renderer,
+// e.g. for templates using the status of other resources through
+// '.Resources' and for holding back apply waves until resources are
+// ready. Resources which cannot be applied are reported like
+// templates which cannot be rendered.
+func (o *OfflineRenderer) renderTemplates(ctx context.Context, parent client.Object, tmplSpec *gwcapi.ResourceSpec,
+	values *TemplateValues) ([]*ResourceTemplateState, *RenderResult, error) {
+	kind, err := parentKind(o, parent)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot lookup kind of parent: %w", err)
+	}
+	templates, err := parseTemplates(tmplSpec.ResourceTemplates)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot parse templates: %w", err)
+	}
+	templates, err = sortTemplates(templates, tmplSpec.TemplateOptions)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot resolve template dependencies: %w", err)
+	}
+
+	apply := o.apply
+	if apply == nil {
+		apply = func(_ context.Context, res *ResourceComposite) (*unstructured.Unstructured, error) {
+			return o.simulateApply(res.Rendered), nil
+		}
+	}
+	var applyErrs []error
+	renderResult, err := renderTemplates(ctx, o, templates, values, func(tmpl *ResourceTemplateState) int {
+		var errorCnt = 0
+		for resIdx := range tmpl.Resources {
+			res := &tmpl.Resources[resIdx]
+			// Parent metadata and owner references refer to the
+			// UID assigned by the API server, i.e. they are only
+			// set for parents with a UID in their manifest
+			if parent.GetUID() != "" {
+				setParentMetadata(res.Rendered, parent, kind)
+			}
+			if res.IsNamespaced {
+				if parent.GetUID() != "" {
+					if err := ctrl.SetControllerReference(parent, res.Rendered, o.scheme); err != nil {
+						applyErrs = append(applyErrs, fmt.Errorf("cannot set owner of template %q: %w", tmpl.TemplateName, err))
+						errorCnt++
+					}
+				}
+				res.Rendered.SetNamespace(parent.GetNamespace())
+			}
+			current, err := apply(ctx, res)
+			if err != nil {
+				applyErrs = append(applyErrs, fmt.Errorf("cannot apply template %q: %w", tmpl.TemplateName, err))
+				errorCnt++
+				continue
+			}
+			res.Current = current
+		}
+		return errorCnt
+	})
+	if len(applyErrs) > 0 {
+		// The error returned only counts the errors of applying resources
+		renderResult.TemplateErrors = append(renderResult.TemplateErrors, applyErrs...)
+		err = nil
+	}
+	return templates, renderResult, err
+}
+
+func (o *OfflineRenderer) newRenderResult(gw *gatewayapi.Gateway, parent client.Object, gwcb *gwcapi.GatewayClassBlueprint,
+	templates []*ResourceTemplateState, renderResult *RenderResult) *OfflineRenderResult {
+	result := &OfflineRenderResult{
+		Gateway:        gw.Namespace + "/" + gw.Name,
+		Templates:      []OfflineRenderedTemplate{},
+		TemplateErrors: errors.Join(renderResult.TemplateErrors...),
+		Complete:       renderResult.Rendered == len(templates) && len(renderResult.TemplateErrors) == 0,
+		Inventory:      templatesToInventory(templates, parent.GetNamespace()),
+		PrunePolicy:    gwcb.Spec.PrunePolicy,
+	}
+	for _, tmpl := range templates {
+		if len(tmpl.Resources) == 0 {
+			continue
+		}
+		out := OfflineRenderedTemplate{TemplateName: tmpl.TemplateName}
+		for resIdx := range tmpl.Resources {
+			out.Resources = append(out.Resources, tmpl.Resources[resIdx].Rendered)
+		}
+		result.Templates = append(result.Templates, out)
+	}
+	return result
+}
+
+// The resource as returned by the API server when applying a rendered
+// resource, i.e. with the status of the matching current resource
+func (o *OfflineRenderer) simulateApply(rendered *unstructured.Unstructured) *unstructured.Unstructured {
+	applied := rendered.DeepCopy()
+	for _, cur := range o.current {
+		if cur.GetAPIVersion() == rendered.GetAPIVersion() && cur.GetKind() == rendered.GetKind() &&
+			cur.GetNamespace() == rendered.GetNamespace() && cur.GetName() == rendered.GetName() {
+			if status, found := cur.Object["status"]; found {
+				applied.Object["status"] = runtime.DeepCopyJSONValue(status)
+			}
+		}
+	}
+	return applied
+}
+
+// Condition transition times are the time of rendering, which is not
+// meaningful offline
+func clearTransitionTimes(conditions []metav1.Condition) {
+	for idx := range conditions {
+		conditions[idx].LastTransitionTime = metav1.Time{}
+	}
+}
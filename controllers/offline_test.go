@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+const offlineTestManifests = `
+apiVersion: gateway.networking.k8s.io/v1beta1
+kind: GatewayClass
+metadata:
+  name: gwc
+spec:
+  controllerName: "github.com/tv2-oss/bifrost-gateway-controller"
+  parametersRef:
+    group: gateway.tv2.dk
+    kind: GatewayClassBlueprint
+    name: blueprint
+---
+apiVersion: gateway.tv2.dk/v1alpha1
+kind: GatewayClassBlueprint
+metadata:
+  name: blueprint
+spec:
+  values:
+    default:
+      region: eu-north-1
+  gatewayTemplate:
+    resourceTemplates:
+      bucket: |
+        apiVersion: s3.example.com/v1
+        kind: Bucket
+        metadata:
+          name: {{ .Gateway.metadata.name }}
+        spec:
+          region: {{ .Values.region }}
+      config: |
+        apiVersion: v1
+        kind: ConfigMap
+        metadata:
+          name: {{ .Gateway.metadata.name }}
+        data:
+          arn: {{ (index .Resources.bucket 0).status.arn }}
+  httpRouteTemplate:
+    resourceTemplates:
+      shadow: |
+        apiVersion: v1
+        kind: ConfigMap
+        metadata:
+          name: {{ .HTTPRoute.metadata.name }}-{{ .Gateway.metadata.name }}
+        data:
+          region: {{ .Values.region }}
+---
+apiVersion: gateway.networking.k8s.io/v1beta1
+kind: Gateway
+metadata:
+  name: gw
+spec:
+  gatewayClassName: gwc
+  listeners:
+  - name: http
+    port: 80
+    protocol: HTTP
+---
+apiVersion: gateway.tv2.dk/v1alpha1
+kind: GatewayConfig
+metadata:
+  name: gw-config
+spec:
+  targetRef:
+    group: gateway.networking.k8s.io
+    kind: Gateway
+    name: gw
+  override:
+    region: eu-west-1
+---
+apiVersion: gateway.networking.k8s.io/v1
+kind: HTTPRoute
+metadata:
+  name: rt
+spec:
+  parentRefs:
+  - name: gw
+`
+
+const offlineTestCurrent = `
+apiVersion: s3.example.com/v1
+kind: Bucket
+metadata:
+  name: gw
+status:
+  arn: arn:aws:s3:::gw
+`
+
+func helperDecodeManifests(t *testing.T, manifests string) []*unstructured.Unstructured {
+	objs := []*unstructured.Unstructured{}
+	for _, doc := range strings.Split(manifests, "---") {
+		obj := map[string]any{}
+		if err := sigsyaml.Unmarshal([]byte(doc), &obj); err != nil {
+			t.Fatal(err)
+		}
+		objs = append(objs, &unstructured.Unstructured{Object: obj})
+	}
+	return objs
+}
+
+func TestOfflineRenderer(t *testing.T) {
+	defer func(w io.Writer) { RenderDebugOutput = w }(RenderDebugOutput)
+	RenderDebugOutput = io.Discard
+
+	renderer, err := NewOfflineRenderer(helperDecodeManifests(t, offlineTestManifests),
+		helperDecodeManifests(t, offlineTestCurrent), []schema.GroupKind{{Group: "s3.example.com", Kind: "Bucket"}})
+	if err != nil {
+		t.Fatalf("NewOfflineRenderer() failed: %v", err)
+	}
+
+	result, err := renderer.RenderGateway(context.Background(), "default", "gw")
+	if err != nil || result.TemplateErrors != nil {
+		t.Fatalf("RenderGateway() failed: %v, %v", err, result.TemplateErrors)
+	}
+	// Templates are rendered in dependency order
+	rendered := result.Templates
+	if len(rendered) != 2 || rendered[0].TemplateName != "bucket" || rendered[1].TemplateName != "config" {
+		t.Fatalf("unexpected rendered templates %+v", rendered)
+	}
+	bucket := rendered[0].Resources[0]
+	if region, _, _ := unstructured.NestedString(bucket.Object, "spec", "region"); region != "eu-west-1" {
+		t.Errorf("unexpected region %q", region)
+	}
+	if bucket.GetNamespace() != "" {
+		t.Errorf("unexpected namespace %q for cluster scoped resource", bucket.GetNamespace())
+	}
+	config := rendered[1].Resources[0]
+	if arn, _, _ := unstructured.NestedString(config.Object, "data", "arn"); arn != "arn:aws:s3:::gw" {
+		t.Errorf("unexpected arn %q from current resources", arn)
+	}
+	if config.GetNamespace() != "default" {
+		t.Errorf("unexpected namespace %q", config.GetNamespace())
+	}
+	if cond := meta.FindStatusCondition(result.GatewayStatus.Conditions, "Programmed"); cond == nil || cond.Status != metav1.ConditionTrue {
+		t.Errorf("unexpected Programmed condition %+v", cond)
+	}
+
+	results, err := renderer.RenderRoute(context.Background(), "HTTPRoute", "default", "rt")
+	if err != nil {
+		t.Fatalf("RenderRoute() failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Gateway != "default/gw" || results[0].Templates[0].Resources[0].GetName() != "rt-gw" {
+		t.Errorf("unexpected results %+v", results)
+	}
+	if cond := meta.FindStatusCondition(results[0].RouteParentStatus.Conditions, "Accepted"); cond == nil || cond.Status != metav1.ConditionTrue {
+		t.Errorf("unexpected Accepted condition %+v", cond)
+	}
+
+	// Without current resources, templates depending on their status cannot be rendered
+	renderer, err = NewOfflineRenderer(helperDecodeManifests(t, offlineTestManifests), nil, nil)
+	if err != nil {
+		t.Fatalf("NewOfflineRenderer() failed: %v", err)
+	}
+	result, err = renderer.RenderGateway(context.Background(), "default", "gw")
+	if err != nil || result.TemplateErrors == nil || len(result.Templates) != 1 || result.Templates[0].Resources[0].GetNamespace() != "default" {
+		t.Errorf("unexpected result %+v, error %v", result, err)
+	}
+}
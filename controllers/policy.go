@@ -0,0 +1,384 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1a2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+)
+
+// A GatewayClassConfig or GatewayConfig policy
+type valuesPolicy interface {
+	client.Object
+	GetTemplateValues() *gwcapi.TemplateValues
+	GetTargetRef() *gatewayv1a2.NamespacedPolicyTargetReference
+	GetTargetSelector() *gwcapi.PolicyTargetSelector
+	GetPolicyStatus() *gwcapi.PolicyStatus
+}
+
+// Separator of elements of value paths. Keys of values may hold dots
+const valuePathSeparator = "\x1f"
+
+// Unmarshal values to a map. Returns an error if values are not a JSON object
+func valuesToMap(src *apiextensionsv1.JSON) (map[string]any, error) {
+	values := map[string]any{}
+	if src == nil || len(src.Raw) == 0 {
+		return values, nil
+	}
+	if err := json.Unmarshal(src.Raw, &values); err != nil {
+		return nil, fmt.Errorf("values must be a JSON object: %w", err)
+	}
+	return values, nil
+}
+
+// Validate that default and override values are JSON objects and
+// that merge strategies and valuesFrom references are complete
+func validateValues(values *gwcapi.TemplateValues) error {
+	if _, err := valuesToMap(values.Default); err != nil {
+		return fmt.Errorf("default: %w", err)
+	}
+	if _, err := valuesToMap(values.Override); err != nil {
+		return fmt.Errorf("override: %w", err)
+	}
+	if err := validateValuesFrom(values.ValuesFrom); err != nil {
+		return err
+	}
+	for _, strategy := range values.MergeStrategies {
+		if strategy.Strategy == gwcapi.MergeStrategyMergeByKey && strategy.Key == "" {
+			return fmt.Errorf("mergeStrategies: strategy %s for %q requires a key", strategy.Strategy, strategy.Path)
+		}
+	}
+	return nil
+}
+
+// Listener targeted by a GatewayConfig, nil for other policies and targets
+func policySectionName(policy valuesPolicy) *gatewayapi.SectionName {
+	if gwc, ok := policy.(*gwcapi.GatewayConfig); ok && gwc.Spec.TargetRef != nil {
+		return gwc.Spec.TargetRef.SectionName
+	}
+	return nil
+}
+
+// Whether a policy is a GatewayClassConfig in the controller namespace, i.e. applies to all namespaces
+func isGlobalPolicy(policy valuesPolicy) bool {
+	_, ok := policy.(*gwcapi.GatewayClassConfig)
+	return ok && policy.GetNamespace() == ControllerNamespace
+}
+
+// Whether a policy selects an object of the given kind by labels. For
+// Gateways, namespace is the namespace of the Gateway, for Namespaces
+// the name of the namespace. Policies select Gateways in their own
+// namespace only, except GatewayClassConfigs in the controller
+// namespace. The selector must be valid, see validatePolicyTarget
+func policySelects(policy valuesPolicy, kind gatewayapi.Kind, namespace string, lbls labels.Set) bool {
+	targetSelector := policy.GetTargetSelector()
+	if targetSelector == nil || targetSelector.Kind != kind {
+		return false
+	}
+	if kind == "Gateway" && namespace != policy.GetNamespace() && !isGlobalPolicy(policy) {
+		return false
+	}
+	selector, err := metav1.LabelSelectorAsSelector(&targetSelector.Selector)
+	if err != nil {
+		return false
+	}
+	return selector.Matches(lbls)
+}
+
+// Route kind targeted by a policy, nil if the target is not a route
+func policyTargetRouteType(policy valuesPolicy) *routeType {
+	targetRef := policy.GetTargetRef()
+	for _, rtType := range routeTypes {
+		if string(targetRef.Kind) == rtType.Kind && string(targetRef.Group) == rtType.GroupVersion.Group {
+			return rtType
+		}
+	}
+	return nil
+}
+
+// Validate the target of a policy, i.e. that the target is one used
+// by lookupValues. GatewayClassConfigs target GatewayClasses or their
+// own namespace, GatewayConfigs target their own namespace, Gateways
+// or listeners of Gateways in their own namespace, or routes in their
+// own namespace. Alternatively, policies select Gateways by labels and
+// GatewayClassConfigs in the controller namespace select Namespaces by
+// labels.
+func validatePolicyTarget(policy valuesPolicy) error {
+	targetSelector := policy.GetTargetSelector()
+	if targetSelector != nil {
+		if policy.GetTargetRef().Kind != "" {
+			return fmt.Errorf("only one of targetRef and targetSelector may be set")
+		}
+		if _, err := metav1.LabelSelectorAsSelector(&targetSelector.Selector); err != nil {
+			return fmt.Errorf("invalid targetSelector: %w", err)
+		}
+		switch {
+		case targetSelector.Kind == "Gateway" && targetSelector.Group == gatewayapi.GroupName:
+			return nil
+		case targetSelector.Kind == "Namespace" && targetSelector.Group == "":
+			if isGlobalPolicy(policy) {
+				return nil
+			}
+			return fmt.Errorf("only GatewayClassConfigs in namespace %q may select Namespaces", ControllerNamespace)
+		}
+		return fmt.Errorf("unsupported target selector kind %s/%s", targetSelector.Group, targetSelector.Kind)
+	}
+	targetRef := policy.GetTargetRef()
+	if targetRef.Kind == "" {
+		return fmt.Errorf("one of targetRef and targetSelector must be set")
+	}
+	if policySectionName(policy) != nil && targetRef.Kind != "Gateway" {
+		return fmt.Errorf("sectionName is only supported for Gateway targets")
+	}
+	if _, ok := policy.(*gwcapi.GatewayConfig); ok && policyTargetRouteType(policy) != nil {
+		if targetRef.Namespace != nil && string(*targetRef.Namespace) != policy.GetNamespace() {
+			return fmt.Errorf("policy must target routes in its own namespace %q", policy.GetNamespace())
+		}
+		return nil
+	}
+	switch {
+	case targetRef.Kind == "Namespace" && targetRef.Group == "":
+		if string(targetRef.Name) != policy.GetNamespace() {
+			return fmt.Errorf("policy must target its own namespace %q", policy.GetNamespace())
+		}
+		return nil
+	case targetRef.Kind == "GatewayClass" && targetRef.Group == gatewayapi.GroupName:
+		if _, ok := policy.(*gwcapi.GatewayClassConfig); ok {
+			return nil
+		}
+	case targetRef.Kind == "Gateway" && targetRef.Group == gatewayapi.GroupName:
+		if _, ok := policy.(*gwcapi.GatewayConfig); ok {
+			if targetRef.Namespace != nil && string(*targetRef.Namespace) != policy.GetNamespace() {
+				return fmt.Errorf("policy must target Gateways in its own namespace %q", policy.GetNamespace())
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported target kind %s/%s", targetRef.Group, targetRef.Kind)
+}
+
+// Whether two policies of the same kind and namespace have the same target
+func samePolicyTarget(a, b valuesPolicy) bool {
+	aSelector, bSelector := a.GetTargetSelector(), b.GetTargetSelector()
+	if aSelector != nil || bSelector != nil {
+		return aSelector != nil && bSelector != nil && reflect.DeepEqual(aSelector, bSelector)
+	}
+	aRef, bRef := a.GetTargetRef(), b.GetTargetRef()
+	return aRef.Group == bRef.Group && aRef.Kind == bRef.Kind && aRef.Name == bRef.Name &&
+		derefCmp(aRef.Namespace, bRef.Namespace) && derefCmp(policySectionName(a), policySectionName(b))
+}
+
+// Whether policy a takes precedence over policy b in case of
+// conflicts. Following GEP-713, the oldest policy takes precedence
+// and policies of the same age are ordered by name.
+func policyPrecedes(a, b client.Object) bool {
+	aTime, bTime := a.GetCreationTimestamp(), b.GetCreationTimestamp()
+	if !aTime.Equal(&bTime) {
+		return aTime.Before(&bTime)
+	}
+	if a.GetNamespace() != b.GetNamespace() {
+		return a.GetNamespace() < b.GetNamespace()
+	}
+	return a.GetName() < b.GetName()
+}
+
+// Call a function for each leaf value with the path of the value.
+// Lists are leaf values and empty objects do not hold any values.
+func walkLeafValues(path []string, values map[string]any, fn func(path []string, val any)) {
+	for key, val := range values {
+		valPath := append(append([]string{}, path...), key)
+		if m, ok := val.(map[string]any); ok {
+			walkLeafValues(valPath, m, fn)
+			continue
+		}
+		fn(valPath, val)
+	}
+}
+
+// Return leaf values of a policy by path, e.g. 'default.foo.bar'.
+// Lists are leaf values, except lists merged with the merge
+// strategies of the policy. Items of lists merged by key are objects
+// with paths holding the key, e.g. 'default.listeners.[name=http]',
+// and lists which are appended do not hold leaf values since they
+// never conflict.
+func policyLeafValues(values *gwcapi.TemplateValues) (map[string]any, error) {
+	strategies := newMergeStrategies(nil, values)
+	leaves := map[string]any{}
+	for name, src := range map[string]*apiextensionsv1.JSON{"default": values.Default, "override": values.Override} {
+		vals, err := valuesToMap(src)
+		if err != nil {
+			return nil, err
+		}
+		var addLeaf func(path []string, val any)
+		addLeaf = func(path []string, val any) {
+			list, isList := val.([]any)
+			strategy := strategies[strings.Join(path[1:], ".")]
+			switch {
+			case isList && strategy.Strategy == gwcapi.MergeStrategyAppend:
+				return
+			case isList && strategy.Strategy == gwcapi.MergeStrategyMergeByKey:
+				for _, item := range list {
+					if m, ok := item.(map[string]any); ok && m[strategy.Key] != nil {
+						itemPath := append(append([]string{}, path...), fmt.Sprintf("[%s=%v]", strategy.Key, m[strategy.Key]))
+						walkLeafValues(itemPath, m, func(path []string, val any) {
+							leaves[strings.Join(path, valuePathSeparator)] = val
+						})
+					}
+				}
+				return
+			}
+			leaves[strings.Join(path, valuePathSeparator)] = val
+		}
+		walkLeafValues([]string{name}, vals, addLeaf)
+	}
+	return leaves, nil
+}
+
+// Find a value set differently by two policies, i.e. a path with
+// different leaf values or a path which is a leaf value in one
+// policy and an object in the other. Returns the path in dotted
+// notation, or an empty string if the policies can be merged.
+func conflictingValue(a, b map[string]any) string {
+	conflicts := []string{}
+	for path, aVal := range a {
+		if bVal, found := b[path]; found && !reflect.DeepEqual(aVal, bVal) {
+			conflicts = append(conflicts, path)
+		}
+		// Leaf value in one policy and object in the other
+		for idx := strings.LastIndex(path, valuePathSeparator); idx > 0; idx = strings.LastIndex(path[:idx], valuePathSeparator) {
+			if _, found := b[path[:idx]]; found {
+				conflicts = append(conflicts, path[:idx])
+			}
+		}
+	}
+	for path := range b {
+		for idx := strings.LastIndex(path, valuePathSeparator); idx > 0; idx = strings.LastIndex(path[:idx], valuePathSeparator) {
+			if _, found := a[path[:idx]]; found {
+				conflicts = append(conflicts, path[:idx])
+			}
+		}
+	}
+	if len(conflicts) == 0 {
+		return ""
+	}
+	sort.Strings(conflicts)
+	return strings.ReplaceAll(conflicts[0], valuePathSeparator, ".")
+}
+
+// Result of resolving conflicts between policies with the same target
+type policyConflicts struct {
+	// Policies which are valid and do not conflict with policies of higher precedence
+	Accepted []valuesPolicy
+
+	// Reasons policies are not accepted by policy name
+	Invalid    map[string]string
+	Conflicted map[string]string
+}
+
+// Resolve conflicts between policies of the same kind and namespace
+// with the same target. A policy conflicts with another if they set
+// the same value differently, since the result of merging would
+// depend on the order of policies. Policies conflicting with a policy
+// of higher precedence are not accepted. Accepted policies are
+// returned in order of precedence, see policyPrecedes.
+func resolvePolicyConflicts(policies []valuesPolicy) *policyConflicts {
+	result := &policyConflicts{Invalid: map[string]string{}, Conflicted: map[string]string{}}
+
+	ordered := make([]valuesPolicy, len(policies))
+	copy(ordered, policies)
+	sort.SliceStable(ordered, func(i, j int) bool { return policyPrecedes(ordered[i], ordered[j]) })
+
+	type leafValues struct {
+		policy valuesPolicy
+		leaves map[string]any
+	}
+	accepted := []leafValues{}
+	for _, policy := range ordered {
+		err := validateValues(policy.GetTemplateValues())
+		var leaves map[string]any
+		if err == nil {
+			leaves, err = policyLeafValues(policy.GetTemplateValues())
+		}
+		if err != nil {
+			result.Invalid[policy.GetName()] = err.Error()
+			continue
+		}
+		conflicted := false
+		for _, other := range accepted {
+			if path := conflictingValue(other.leaves, leaves); path != "" {
+				result.Conflicted[policy.GetName()] = fmt.Sprintf("value %q conflicts with policy %s", path, other.policy.GetName())
+				conflicted = true
+				break
+			}
+		}
+		if !conflicted {
+			accepted = append(accepted, leafValues{policy, leaves})
+		}
+	}
+
+	for _, policy := range ordered {
+		_, invalid := result.Invalid[policy.GetName()]
+		_, conflicted := result.Conflicted[policy.GetName()]
+		if !invalid && !conflicted {
+			result.Accepted = append(result.Accepted, policy)
+		}
+	}
+	return result
+}
+
+// Return policies which are accepted, i.e. not conflicting with
+// policies of higher precedence, in order of precedence. All policies
+// must have the same target and namespace.
+func acceptedPolicies[P valuesPolicy](policies []P) []P {
+	generic := make([]valuesPolicy, 0, len(policies))
+	for _, policy := range policies {
+		generic = append(generic, policy)
+	}
+	resolved := resolvePolicyConflicts(generic)
+	accepted := make([]P, 0, len(resolved.Accepted))
+	for _, policy := range resolved.Accepted {
+		accepted = append(accepted, policy.(P))
+	}
+	return accepted
+}
@@ -0,0 +1,492 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1a2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+)
+
+// PolicyReconciler reconciles the status of GatewayClassConfig and GatewayConfig policies
+type PolicyReconciler struct {
+	client        client.Client
+	scheme        *runtime.Scheme
+	routeTypes    []*routeType
+	newPolicy     func() valuesPolicy
+	newPolicyList func() client.ObjectList
+}
+
+func (r *PolicyReconciler) Client() client.Client {
+	return r.client
+}
+
+func (r *PolicyReconciler) Scheme() *runtime.Scheme {
+	return r.scheme
+}
+
+func NewGatewayClassConfigController(mgr ctrl.Manager) *PolicyReconciler {
+	return &PolicyReconciler{
+		client:        mgr.GetClient(),
+		scheme:        mgr.GetScheme(),
+		routeTypes:    availableRouteTypes(mgr.GetRESTMapper()),
+		newPolicy:     func() valuesPolicy { return &gwcapi.GatewayClassConfig{} },
+		newPolicyList: func() client.ObjectList { return &gwcapi.GatewayClassConfigList{} },
+	}
+}
+
+func NewGatewayConfigController(mgr ctrl.Manager) *PolicyReconciler {
+	return &PolicyReconciler{
+		client:        mgr.GetClient(),
+		scheme:        mgr.GetScheme(),
+		routeTypes:    availableRouteTypes(mgr.GetRESTMapper()),
+		newPolicy:     func() valuesPolicy { return &gwcapi.GatewayConfig{} },
+		newPolicyList: func() client.ObjectList { return &gwcapi.GatewayConfigList{} },
+	}
+}
+
+func (r *PolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	c := mgr.GetClient()
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(r.newPolicy()).
+		// Policies with the same target may conflict and values
+		// from other policies affect validation of values
+		Watches(&gwcapi.GatewayClassConfig{}, handler.EnqueueRequestsFromMapFunc(
+			mapToPolicies(c, r.newPolicyList, policyNamespaces)),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&gwcapi.GatewayConfig{}, handler.EnqueueRequestsFromMapFunc(
+			mapToPolicies(c, r.newPolicyList, policyNamespaces)),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		// Targets and affected objects
+		Watches(&gwcapi.GatewayClassBlueprint{}, handler.EnqueueRequestsFromMapFunc(
+			mapToPolicies(c, r.newPolicyList, func(obj client.Object) []string { return []string{""} })),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&gatewayapi.GatewayClass{}, handler.EnqueueRequestsFromMapFunc(
+			mapToPolicies(c, r.newPolicyList, func(obj client.Object) []string { return []string{""} }))).
+		// Labels of Namespaces and Gateways affect policies selecting targets by labels
+		Watches(&corev1.Namespace{}, handler.EnqueueRequestsFromMapFunc(
+			mapToPolicies(c, r.newPolicyList, func(obj client.Object) []string {
+				return []string{obj.GetName(), ControllerNamespace}
+			})),
+			builder.WithPredicates(predicate.LabelChangedPredicate{})).
+		Watches(&gatewayapi.Gateway{}, handler.EnqueueRequestsFromMapFunc(
+			mapToPolicies(c, r.newPolicyList, func(obj client.Object) []string {
+				return []string{obj.GetNamespace(), ControllerNamespace}
+			})),
+			builder.WithPredicates(predicate.Or[client.Object](predicate.GenerationChangedPredicate{}, predicate.LabelChangedPredicate{})))
+	for _, rtType := range r.routeTypes {
+		b = b.Watches(rtType.NewRoute(), handler.EnqueueRequestsFromMapFunc(
+			mapToPolicies(c, r.newPolicyList, func(obj client.Object) []string {
+				// Policies may target the route itself
+				namespaces := []string{ControllerNamespace, obj.GetNamespace()}
+				for _, gw := range routeParentGateways(obj) {
+					namespaces = append(namespaces, gw.Namespace)
+				}
+				return namespaces
+			})),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}))
+	}
+	return b.Complete(r)
+}
+
+// Namespaces of policies affected by a policy. Global policies in the
+// controller namespace affect policies in all namespaces
+func policyNamespaces(obj client.Object) []string {
+	if obj.GetNamespace() == ControllerNamespace {
+		return []string{""}
+	}
+	return []string{obj.GetNamespace()}
+}
+
+func (r *PolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	policy := r.newPolicy()
+	if err := r.Client().Get(ctx, req.NamespacedName, policy); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	status := policy.GetPolicyStatus()
+	beforeStatusUpdate := status.DeepCopy()
+
+	cond, err := r.acceptedCondition(ctx, policy)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	meta.SetStatusCondition(&status.Conditions, *cond)
+
+	status.Affected = nil
+	var valuesCond *metav1.Condition
+	if cond.Status == metav1.ConditionTrue {
+		if status.Affected, err = r.lookupAffected(ctx, policy); err != nil {
+			return ctrl.Result{}, err
+		}
+		if valuesCond, err = r.valuesValidCondition(ctx, policy, status.Affected); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	if valuesCond != nil {
+		meta.SetStatusCondition(&status.Conditions, *valuesCond)
+	} else {
+		meta.RemoveStatusCondition(&status.Conditions, gwcapi.PolicyConditionValuesValid)
+	}
+
+	if !equality.Semantic.DeepEqual(beforeStatusUpdate, status) {
+		logger.Info("updating policy status", "accepted", cond.Status, "reason", cond.Reason, "affected", len(status.Affected))
+		if err := updateStatus(ctx, r, policy); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to update policy status: %w", err)
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// Compute the 'Accepted' policy condition, i.e. whether the policy is
+// valid, the target exists and whether the policy conflicts with
+// other policies with the same target
+func (r *PolicyReconciler) acceptedCondition(ctx context.Context, policy valuesPolicy) (*metav1.Condition, error) {
+	cond := &metav1.Condition{
+		Type:               string(gatewayv1a2.PolicyConditionAccepted),
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: policy.GetGeneration(),
+	}
+
+	err := validatePolicyTarget(policy)
+	if err == nil {
+		err = validateValues(policy.GetTemplateValues())
+	}
+	if err != nil {
+		cond.Reason = string(gatewayv1a2.PolicyReasonInvalid)
+		cond.Message = err.Error()
+		return cond, nil
+	}
+
+	found, err := lookupPolicyTarget(ctx, r, policy)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		targetRef := policy.GetTargetRef()
+		cond.Reason = string(gatewayv1a2.PolicyReasonTargetNotFound)
+		cond.Message = fmt.Sprintf("%s %s not found", targetRef.Kind, targetRef.Name)
+		if sectionName := policySectionName(policy); sectionName != nil {
+			cond.Message = fmt.Sprintf("listener %s of %s %s not found", *sectionName, targetRef.Kind, targetRef.Name)
+		}
+		return cond, nil
+	}
+
+	// Policies of the same kind and namespace with the same target may conflict
+	list := r.newPolicyList()
+	if err := r.Client().List(ctx, list, client.InNamespace(policy.GetNamespace())); err != nil {
+		return nil, err
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return nil, err
+	}
+	sameTarget := []valuesPolicy{}
+	for _, item := range items {
+		if other, ok := item.(valuesPolicy); ok && samePolicyTarget(policy, other) {
+			sameTarget = append(sameTarget, other)
+		}
+	}
+	if msg, conflicted := resolvePolicyConflicts(sameTarget).Conflicted[policy.GetName()]; conflicted {
+		cond.Reason = string(gatewayv1a2.PolicyReasonConflicted)
+		cond.Message = msg
+		return cond, nil
+	}
+	if policy.GetTargetSelector() != nil {
+		msg, err := r.selectorConflict(ctx, policy, items)
+		if err != nil {
+			return nil, err
+		}
+		if msg != "" {
+			cond.Reason = string(gatewayv1a2.PolicyReasonConflicted)
+			cond.Message = msg
+			return cond, nil
+		}
+	}
+
+	cond.Status = metav1.ConditionTrue
+	cond.Reason = string(gatewayv1a2.PolicyReasonAccepted)
+	return cond, nil
+}
+
+// Check whether a policy selecting targets by labels conflicts with
+// other policies of the same kind and namespace for any of the objects
+// selected by the policy. Returns the conflict message if so
+func (r *PolicyReconciler) selectorConflict(ctx context.Context, policy valuesPolicy, items []runtime.Object) (string, error) {
+	kind := policy.GetTargetSelector().Kind
+	others := []valuesPolicy{}
+	for _, item := range items {
+		if other, ok := item.(valuesPolicy); ok && other.GetName() != policy.GetName() &&
+			other.GetTargetSelector() != nil && other.GetTargetSelector().Kind == kind {
+			others = append(others, other)
+		}
+	}
+	if len(others) == 0 {
+		return "", nil
+	}
+
+	// Selected objects by namespace and name, see policySelects
+	type selectedObject struct {
+		namespace, name string
+		labels          labels.Set
+	}
+	objects := []selectedObject{}
+	if kind == "Namespace" {
+		var nsList corev1.NamespaceList
+		if err := r.Client().List(ctx, &nsList); err != nil {
+			return "", err
+		}
+		for idx := range nsList.Items {
+			objects = append(objects, selectedObject{nsList.Items[idx].Name, nsList.Items[idx].Name, nsList.Items[idx].Labels})
+		}
+	} else {
+		var opts []client.ListOption
+		if !isGlobalPolicy(policy) {
+			opts = append(opts, client.InNamespace(policy.GetNamespace()))
+		}
+		var gwList gatewayapi.GatewayList
+		if err := r.Client().List(ctx, &gwList, opts...); err != nil {
+			return "", err
+		}
+		for idx := range gwList.Items {
+			gw := &gwList.Items[idx]
+			objects = append(objects, selectedObject{gw.Namespace, gw.Namespace + "/" + gw.Name, gw.Labels})
+		}
+	}
+
+	for _, obj := range objects {
+		if !policySelects(policy, kind, obj.namespace, obj.labels) {
+			continue
+		}
+		selecting := []valuesPolicy{policy}
+		for _, other := range others {
+			if policySelects(other, kind, obj.namespace, obj.labels) {
+				selecting = append(selecting, other)
+			}
+		}
+		if msg, conflicted := resolvePolicyConflicts(selecting).Conflicted[policy.GetName()]; conflicted {
+			return fmt.Sprintf("%s selecting %s %s", msg, kind, obj.name), nil
+		}
+	}
+	return "", nil
+}
+
+// Check whether the target of a policy exists. The target must be
+// valid, see validatePolicyTarget. Policies selecting targets by
+// labels have no single target and are always considered found
+func lookupPolicyTarget(ctx context.Context, r ControllerClient, policy valuesPolicy) (bool, error) {
+	if policy.GetTargetSelector() != nil {
+		return true, nil
+	}
+	targetRef := policy.GetTargetRef()
+	var err error
+	switch targetRef.Kind {
+	case "GatewayClass":
+		err = r.Client().Get(ctx, types.NamespacedName{Name: string(targetRef.Name)}, &gatewayapi.GatewayClass{})
+	case "Namespace":
+		err = r.Client().Get(ctx, types.NamespacedName{Name: string(targetRef.Name)}, &corev1.Namespace{})
+	case "Gateway":
+		gw := &gatewayapi.Gateway{}
+		err = r.Client().Get(ctx, types.NamespacedName{Namespace: policy.GetNamespace(), Name: string(targetRef.Name)}, gw)
+		if sectionName := policySectionName(policy); err == nil && sectionName != nil {
+			for idx := range gw.Spec.Listeners {
+				if gw.Spec.Listeners[idx].Name == *sectionName {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+	default:
+		if rtType := policyTargetRouteType(policy); rtType != nil {
+			err = r.Client().Get(ctx, types.NamespacedName{Namespace: policy.GetNamespace(), Name: string(targetRef.Name)}, rtType.NewRoute())
+		}
+	}
+	if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Lookup Gateways of our GatewayClasses affected by a policy and the
+// routes attached to them. Policies targeting listeners only affect
+// routes attached to the listener and policies targeting routes only
+// affect the route
+func (r *PolicyReconciler) lookupAffected(ctx context.Context, policy valuesPolicy) ([]gwcapi.PolicyAffectedObject, error) {
+	affected := []gwcapi.PolicyAffectedObject{}
+	if rtType := policyTargetRouteType(policy); rtType != nil {
+		affected = append(affected, gwcapi.PolicyAffectedObject{Group: rtType.GroupVersion.Group, Kind: rtType.Kind,
+			Namespace: policy.GetNamespace(), Name: string(policy.GetTargetRef().Name)})
+		return affected, nil
+	}
+	sectionName := policySectionName(policy)
+
+	gateways := map[types.NamespacedName]bool{}
+	for _, nn := range lookupGatewaysForPolicy(ctx, r.Client(), policy) {
+		var gw gatewayapi.Gateway
+		if err := r.Client().Get(ctx, nn, &gw); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		gwc, err := lookupGatewayClass(ctx, r, gw.Spec.GatewayClassName)
+		if err != nil || !isOurGatewayClass(gwc) {
+			continue
+		}
+		gateways[nn] = true
+		if sectionName == nil {
+			affected = append(affected, gwcapi.PolicyAffectedObject{
+				Group: gatewayapi.GroupName, Kind: "Gateway", Namespace: nn.Namespace, Name: nn.Name})
+		}
+	}
+
+	if len(gateways) > 0 {
+		for _, rtType := range r.routeTypes {
+			rtList := rtType.NewRouteList()
+			if err := r.Client().List(ctx, rtList); err != nil {
+				return nil, err
+			}
+			routes, err := routeListItems(rtList)
+			if err != nil {
+				return nil, err
+			}
+			for _, rt := range routes {
+				for _, parent := range routeParentGateways(rt) {
+					if gateways[parent] && (sectionName == nil || routeReferencesListener(rt, parent, *sectionName)) {
+						affected = append(affected, gwcapi.PolicyAffectedObject{
+							Group: rtType.GroupVersion.Group, Kind: rtType.Kind, Namespace: rt.GetNamespace(), Name: rt.GetName()})
+						break
+					}
+				}
+			}
+		}
+	}
+
+	sort.Slice(affected, func(i, j int) bool {
+		a, b := affected[i], affected[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	if len(affected) > gwcapi.PolicyStatusMaxAffected {
+		affected = affected[:gwcapi.PolicyStatusMaxAffected]
+	}
+	return affected, nil
+}
+
+// Compute the 'ValuesValid' policy condition, i.e. whether values
+// supplied by the policy are valid according to the values schema of
+// the blueprints of affected Gateways. Returns nil if no affected
+// Gateway has a blueprint with a values schema.
+func (r *PolicyReconciler) valuesValidCondition(ctx context.Context, policy valuesPolicy,
+	affected []gwcapi.PolicyAffectedObject) (*metav1.Condition, error) {
+	self := objectRefOf(policy)
+	hasSchema := false
+	msgs := []string{}
+	for _, obj := range affected {
+		if obj.Kind != "Gateway" {
+			continue
+		}
+		gw, err := lookupGateway(ctx, r, gatewayapi.ObjectName(obj.Name), obj.Namespace)
+		if err != nil {
+			return nil, client.IgnoreNotFound(err)
+		}
+		gwc, err := lookupGatewayClass(ctx, r, gw.Spec.GatewayClassName)
+		if err != nil {
+			return nil, client.IgnoreNotFound(err)
+		}
+		gwcb, err := lookupGatewayClassBlueprint(ctx, r, gwc)
+		if err != nil || gwcb.Spec.ValuesSchema == nil {
+			continue
+		}
+		hasSchema = true
+		values, sources, err := lookupValuesWithSources(ctx, r, gwc.Name, gwcb, gw.Namespace, gw.Name, nil)
+		if err != nil {
+			return nil, err
+		}
+		violations, err := validateValuesSchema(gwcb.Spec.ValuesSchema, values, sources)
+		if err != nil {
+			continue // Invalid schema is reported on the blueprint
+		}
+		for idx := range violations {
+			for _, src := range violations[idx].Sources {
+				if src == self {
+					msgs = append(msgs, fmt.Sprintf("Gateway %s/%s: %s: %s", gw.Namespace, gw.Name,
+						violations[idx].Path, violations[idx].Message))
+					break
+				}
+			}
+		}
+	}
+	if !hasSchema {
+		return nil, nil
+	}
+
+	cond := &metav1.Condition{
+		Type:               gwcapi.PolicyConditionValuesValid,
+		Status:             metav1.ConditionTrue,
+		Reason:             gwcapi.PolicyReasonValuesValid,
+		ObservedGeneration: policy.GetGeneration(),
+	}
+	if len(msgs) > 0 {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = gwcapi.PolicyReasonInvalidValues
+		cond.Message = truncateMessage(strings.Join(msgs, "; "))
+	}
+	return cond, nil
+}
@@ -0,0 +1,243 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1a2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+)
+
+func helperGatewayConfig(name string, age time.Duration, override string) *gwcapi.GatewayConfig {
+	pol := &gwcapi.GatewayConfig{}
+	pol.Name = name
+	pol.Namespace = "default"
+	pol.CreationTimestamp = metav1.NewTime(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Add(-age))
+	pol.Spec.TargetRef = &gwcapi.GatewayConfigTargetReference{
+		NamespacedPolicyTargetReference: gatewayv1a2.NamespacedPolicyTargetReference{Group: gatewayapi.GroupName, Kind: "Gateway", Name: "gw"}}
+	if override != "" {
+		pol.Spec.Override = &apiextensionsv1.JSON{Raw: []byte(override)}
+	}
+	return pol
+}
+
+func TestConflictingValue(t *testing.T) {
+	testCases := []struct {
+		name     string
+		a, b     string
+		conflict string
+	}{
+		{"disjoint", `{"a": 1}`, `{"b": 1}`, ""},
+		{"same-value", `{"a": {"b": [1, 2]}}`, `{"a": {"b": [1, 2]}}`, ""},
+		{"nested-disjoint", `{"a": {"b": 1}}`, `{"a": {"c": 1}}`, ""},
+		{"empty-object", `{"a": {}}`, `{"a": {"c": 1}}`, ""},
+		{"different-value", `{"a": {"b": 1}}`, `{"a": {"b": 2}}`, "override.a.b"},
+		{"object-and-value", `{"a": {"b": 1}}`, `{"a": "foo"}`, "override.a"},
+		{"value-and-object", `{"a": "foo"}`, `{"a": {"b": 1}}`, "override.a"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			a, err := policyLeafValues(&helperGatewayConfig("a", 0, tc.a).Spec.TemplateValues)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := policyLeafValues(&helperGatewayConfig("b", 0, tc.b).Spec.TemplateValues)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if conflict := conflictingValue(a, b); conflict != tc.conflict {
+				t.Errorf("conflictingValue() got %q, expected %q", conflict, tc.conflict)
+			}
+		})
+	}
+}
+
+func TestResolvePolicyConflicts(t *testing.T) {
+	policies := []*gwcapi.GatewayConfig{
+		helperGatewayConfig("newest", 0, `{"a": 2}`),
+		helperGatewayConfig("invalid", time.Hour, `["a"]`),
+		helperGatewayConfig("oldest", 2*time.Hour, `{"a": 1}`),
+		helperGatewayConfig("other", time.Hour, `{"b": 1}`),
+	}
+
+	accepted := acceptedPolicies(policies)
+	if len(accepted) != 2 || accepted[0].Name != "oldest" || accepted[1].Name != "other" {
+		t.Errorf("unexpected accepted policies %v", accepted)
+	}
+
+	generic := []valuesPolicy{}
+	for _, pol := range policies {
+		generic = append(generic, pol)
+	}
+	resolved := resolvePolicyConflicts(generic)
+	if msg := resolved.Conflicted["newest"]; msg != `value "override.a" conflicts with policy oldest` {
+		t.Errorf("unexpected conflict %q", msg)
+	}
+	if _, found := resolved.Invalid["invalid"]; !found {
+		t.Errorf("expected invalid policy")
+	}
+
+	// Same age, precedence by name
+	accepted = acceptedPolicies([]*gwcapi.GatewayConfig{
+		helperGatewayConfig("b", 0, `{"a": 2}`),
+		helperGatewayConfig("a", 0, `{"a": 1}`),
+	})
+	if len(accepted) != 1 || accepted[0].Name != "a" {
+		t.Errorf("unexpected accepted policies %v", accepted)
+	}
+
+	// Appended lists do not conflict, lists merged by key conflict
+	// for items with the same key only. Accepted policies are
+	// ordered by precedence
+	withStrategies := func(pol *gwcapi.GatewayConfig) *gwcapi.GatewayConfig {
+		pol.Spec.MergeStrategies = []gwcapi.ValuesMergeStrategy{
+			{Path: "tags", Strategy: gwcapi.MergeStrategyAppend},
+			{Path: "listeners", Strategy: gwcapi.MergeStrategyMergeByKey, Key: "name"},
+		}
+		return pol
+	}
+	policies = []*gwcapi.GatewayConfig{
+		withStrategies(helperGatewayConfig("c", 0, `{"tags": ["c"], "listeners": [{"name": "http", "port": 80}]}`)),
+		withStrategies(helperGatewayConfig("b", time.Hour, `{"tags": ["b"], "listeners": [{"name": "https", "port": 443}]}`)),
+		withStrategies(helperGatewayConfig("a", 2*time.Hour, `{"tags": ["a"], "listeners": [{"name": "http", "port": 8080}]}`)),
+	}
+	accepted = acceptedPolicies(policies)
+	if len(accepted) != 2 || accepted[0].Name != "a" || accepted[1].Name != "b" {
+		t.Errorf("unexpected accepted policies %v", accepted)
+	}
+}
+
+func TestValidatePolicyTarget(t *testing.T) {
+	namespace := gatewayapi.Namespace("other")
+	selector := metav1.LabelSelector{MatchLabels: map[string]string{"tier": "edge"}}
+	testCases := []struct {
+		name   string
+		policy valuesPolicy
+		valid  bool
+	}{
+		{"gatewayclassconfig-class", &gwcapi.GatewayClassConfig{Spec: gwcapi.GatewayClassConfigSpec{
+			TargetRef: &gatewayv1a2.NamespacedPolicyTargetReference{Group: gatewayapi.GroupName, Kind: "GatewayClass", Name: "gwc"}}}, true},
+		{"gatewayclassconfig-gateway", &gwcapi.GatewayClassConfig{Spec: gwcapi.GatewayClassConfigSpec{
+			TargetRef: &gatewayv1a2.NamespacedPolicyTargetReference{Group: gatewayapi.GroupName, Kind: "Gateway", Name: "gw"}}}, false},
+		{"gatewayconfig-class", &gwcapi.GatewayConfig{Spec: gwcapi.GatewayConfigSpec{
+			TargetRef: &gwcapi.GatewayConfigTargetReference{NamespacedPolicyTargetReference: gatewayv1a2.NamespacedPolicyTargetReference{Group: gatewayapi.GroupName, Kind: "GatewayClass", Name: "gwc"}}}}, false},
+		{"gatewayconfig-gateway", helperGatewayConfig("gw", 0, ""), true},
+		{"gatewayconfig-other-namespace", &gwcapi.GatewayConfig{Spec: gwcapi.GatewayConfigSpec{
+			TargetRef: &gwcapi.GatewayConfigTargetReference{NamespacedPolicyTargetReference: gatewayv1a2.NamespacedPolicyTargetReference{Group: gatewayapi.GroupName, Kind: "Gateway", Name: "gw", Namespace: &namespace}}}}, false},
+		{"own-namespace", &gwcapi.GatewayConfig{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}, Spec: gwcapi.GatewayConfigSpec{
+			TargetRef: &gwcapi.GatewayConfigTargetReference{NamespacedPolicyTargetReference: gatewayv1a2.NamespacedPolicyTargetReference{Kind: "Namespace", Name: "default"}}}}, true},
+		{"gatewayconfig-route", &gwcapi.GatewayConfig{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}, Spec: gwcapi.GatewayConfigSpec{
+			TargetRef: &gwcapi.GatewayConfigTargetReference{NamespacedPolicyTargetReference: gatewayv1a2.NamespacedPolicyTargetReference{Group: gatewayapi.GroupName, Kind: "HTTPRoute", Name: "rt"}}}}, true},
+		{"gatewayconfig-route-other-namespace", &gwcapi.GatewayConfig{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}, Spec: gwcapi.GatewayConfigSpec{
+			TargetRef: &gwcapi.GatewayConfigTargetReference{NamespacedPolicyTargetReference: gatewayv1a2.NamespacedPolicyTargetReference{Group: gatewayapi.GroupName, Kind: "GRPCRoute", Name: "rt", Namespace: &namespace}}}}, false},
+		{"gatewayclassconfig-route", &gwcapi.GatewayClassConfig{Spec: gwcapi.GatewayClassConfigSpec{
+			TargetRef: &gatewayv1a2.NamespacedPolicyTargetReference{Group: gatewayapi.GroupName, Kind: "HTTPRoute", Name: "rt"}}}, false},
+		{"gatewayconfig-listener", &gwcapi.GatewayConfig{Spec: gwcapi.GatewayConfigSpec{
+			TargetRef: &gwcapi.GatewayConfigTargetReference{NamespacedPolicyTargetReference: gatewayv1a2.NamespacedPolicyTargetReference{Group: gatewayapi.GroupName, Kind: "Gateway", Name: "gw"},
+				SectionName: PtrTo(gatewayapi.SectionName("http"))}}}, true},
+		{"gatewayconfig-namespace-section", &gwcapi.GatewayConfig{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}, Spec: gwcapi.GatewayConfigSpec{
+			TargetRef: &gwcapi.GatewayConfigTargetReference{NamespacedPolicyTargetReference: gatewayv1a2.NamespacedPolicyTargetReference{Kind: "Namespace", Name: "default"},
+				SectionName: PtrTo(gatewayapi.SectionName("http"))}}}, false},
+		{"other-namespace", &gwcapi.GatewayConfig{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}, Spec: gwcapi.GatewayConfigSpec{
+			TargetRef: &gwcapi.GatewayConfigTargetReference{NamespacedPolicyTargetReference: gatewayv1a2.NamespacedPolicyTargetReference{Kind: "Namespace", Name: "other"}}}}, false},
+		{"gatewayconfig-gateway-selector", &gwcapi.GatewayConfig{Spec: gwcapi.GatewayConfigSpec{
+			TargetSelector: &gwcapi.PolicyTargetSelector{Group: gatewayapi.GroupName, Kind: "Gateway", Selector: selector}}}, true},
+		{"gatewayconfig-namespace-selector", &gwcapi.GatewayConfig{Spec: gwcapi.GatewayConfigSpec{
+			TargetSelector: &gwcapi.PolicyTargetSelector{Kind: "Namespace", Selector: selector}}}, false},
+		{"global-namespace-selector", &gwcapi.GatewayClassConfig{ObjectMeta: metav1.ObjectMeta{Namespace: "controller-ns"}, Spec: gwcapi.GatewayClassConfigSpec{
+			TargetSelector: &gwcapi.PolicyTargetSelector{Kind: "Namespace", Selector: selector}}}, true},
+		{"local-namespace-selector", &gwcapi.GatewayClassConfig{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}, Spec: gwcapi.GatewayClassConfigSpec{
+			TargetSelector: &gwcapi.PolicyTargetSelector{Kind: "Namespace", Selector: selector}}}, false},
+		{"invalid-selector", &gwcapi.GatewayConfig{Spec: gwcapi.GatewayConfigSpec{
+			TargetSelector: &gwcapi.PolicyTargetSelector{Group: gatewayapi.GroupName, Kind: "Gateway", Selector: metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "tier", Operator: "Foo"}}}}}}, false},
+		{"ref-and-selector", &gwcapi.GatewayConfig{Spec: gwcapi.GatewayConfigSpec{
+			TargetRef:      helperGatewayConfig("gw", 0, "").Spec.TargetRef,
+			TargetSelector: &gwcapi.PolicyTargetSelector{Group: gatewayapi.GroupName, Kind: "Gateway", Selector: selector}}}, false},
+		{"no-target", &gwcapi.GatewayConfig{}, false},
+	}
+
+	defer func(ns string) { ControllerNamespace = ns }(ControllerNamespace)
+	ControllerNamespace = "controller-ns"
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := validatePolicyTarget(tc.policy); (err == nil) != tc.valid {
+				t.Errorf("validatePolicyTarget() got %v, expected valid %v", err, tc.valid)
+			}
+		})
+	}
+}
+
+func TestValidateBlueprint(t *testing.T) {
+	gwcb := &gwcapi.GatewayClassBlueprint{}
+	if reason, err := validateBlueprint(gwcb, parseBlueprint(gwcb)); err != nil {
+		t.Errorf("unexpected error %v, reason %s", err, reason)
+	}
+	gwcb.Spec.Values.Default = &apiextensionsv1.JSON{Raw: []byte(`"foo"`)}
+	if reason, _ := validateBlueprint(gwcb, parseBlueprint(gwcb)); reason != gwcapi.GatewayClassBlueprintReasonInvalidValues {
+		t.Errorf("unexpected reason %s", reason)
+	}
+	gwcb.Spec.Values.Default = nil
+	gwcb.Spec.Values.MergeStrategies = []gwcapi.ValuesMergeStrategy{{Path: "listeners", Strategy: gwcapi.MergeStrategyMergeByKey}}
+	if reason, _ := validateBlueprint(gwcb, parseBlueprint(gwcb)); reason != gwcapi.GatewayClassBlueprintReasonInvalidValues {
+		t.Errorf("unexpected reason %s", reason)
+	}
+	gwcb.Spec.Values.MergeStrategies = nil
+	gwcb.Spec.HTTPRouteTemplate.Status.ParentConditions = "{{ .foo"
+	if reason, _ := validateBlueprint(gwcb, parseBlueprint(gwcb)); reason != gwcapi.GatewayClassBlueprintReasonInvalidTemplates {
+		t.Errorf("unexpected reason %s", reason)
+	}
+	gwcb.Spec.HTTPRouteTemplate.Status.ParentConditions = ""
+
+	// Templates are parsed once for validation and parse errors are
+	// only counted when parsing templates for rendering
+	gwcb.Spec.GatewayTemplate.ResourceTemplates = map[string]string{"broken": "{{ .foo"}
+	parseErrs := testutil.ToFloat64(metricTemplateParseErrs)
+	sections := parseBlueprint(gwcb)
+	if reason, _ := validateBlueprint(gwcb, sections); reason != gwcapi.GatewayClassBlueprintReasonInvalidTemplates {
+		t.Errorf("unexpected reason %s", reason)
+	}
+	if reason, _ := validateTemplateDependencies(sections); reason != gwcapi.GatewayClassBlueprintReasonInvalidTemplates {
+		t.Errorf("unexpected dependencies reason %s", reason)
+	}
+	if count := testutil.ToFloat64(metricTemplateParseErrs); count != parseErrs {
+		t.Errorf("validation counted %v parse errors", count-parseErrs)
+	}
+	if _, err := parseTemplates(gwcb.Spec.GatewayTemplate.ResourceTemplates); err == nil ||
+		testutil.ToFloat64(metricTemplateParseErrs) != parseErrs+1 {
+		t.Errorf("unexpected parse error %v, count %v", err, testutil.ToFloat64(metricTemplateParseErrs)-parseErrs)
+	}
+}
+
+func TestPolicySelects(t *testing.T) {
+	defer func(ns string) { ControllerNamespace = ns }(ControllerNamespace)
+	ControllerNamespace = "controller-ns"
+	edge := labels.Set{"tier": "edge"}
+
+	pol := helperGatewayConfig("p", 0, "")
+	pol.Spec.TargetRef = nil
+	pol.Spec.TargetSelector = &gwcapi.PolicyTargetSelector{Group: gatewayapi.GroupName, Kind: "Gateway",
+		Selector: metav1.LabelSelector{MatchLabels: edge}}
+	if !policySelects(pol, "Gateway", "default", edge) {
+		t.Errorf("expected policy to select Gateway with labels")
+	}
+	if policySelects(pol, "Gateway", "default", labels.Set{"tier": "internal"}) || policySelects(pol, "Namespace", "default", edge) {
+		t.Errorf("unexpected selection of other labels or kind")
+	}
+	if policySelects(pol, "Gateway", "other", edge) {
+		t.Errorf("unexpected selection of Gateway in other namespace")
+	}
+
+	global := &gwcapi.GatewayClassConfig{ObjectMeta: metav1.ObjectMeta{Namespace: "controller-ns", Name: "p"},
+		Spec: gwcapi.GatewayClassConfigSpec{TargetSelector: pol.Spec.TargetSelector}}
+	if !policySelects(global, "Gateway", "other", edge) {
+		t.Errorf("expected global policy to select Gateway in other namespace")
+	}
+}
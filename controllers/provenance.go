@@ -0,0 +1,236 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+
+	selfapi "github.com/tv2-oss/bifrost-gateway-controller/pkg/api"
+)
+
+// The blueprint or policy which supplied a value
+type valueSource struct {
+	objectRef
+
+	// Resource version of the blueprint or policy when the value was looked up
+	ResourceVersion string
+
+	// Value supplied as an override, i.e. not as a default
+	Override bool
+
+	// Value from a Secret, i.e. never included in debug output
+	Sensitive bool
+}
+
+func valueSourceOf(obj client.Object, override bool) valueSource {
+	return valueSource{objectRef: objectRefOf(obj), ResourceVersion: obj.GetResourceVersion(), Override: override}
+}
+
+// Source of values as reported through the values-sources annotation
+// and the values debug endpoint
+type valuesSourceEntry struct {
+	Group           string `json:"group"`
+	Kind            string `json:"kind"`
+	Namespace       string `json:"namespace,omitempty"`
+	Name            string `json:"name"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+
+	// Either 'default' or 'override'. Not set for the list of contributors
+	Type string `json:"type,omitempty"`
+
+	// Whether the value is from a Secret and redacted
+	Sensitive bool `json:"sensitive,omitempty"`
+}
+
+func (src *valueSource) entry(withType bool) valuesSourceEntry {
+	e := valuesSourceEntry{Group: src.Group, Kind: src.Kind, Namespace: src.Namespace, Name: src.Name,
+		ResourceVersion: src.ResourceVersion}
+	if withType {
+		e.Type = "default"
+		if src.Override {
+			e.Type = "override"
+		}
+		e.Sensitive = src.Sensitive
+	}
+	return e
+}
+
+// The blueprint and policies which supplied at least one value,
+// ordered by kind, namespace and name
+func (sources valueSources) contributors() []valuesSourceEntry {
+	found := map[valuesSourceEntry]bool{}
+	entries := []valuesSourceEntry{}
+	for _, src := range sources {
+		e := src.entry(false)
+		if !found[e] {
+			found[e] = true
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	return entries
+}
+
+// Write the blueprint and policies which supplied values to an
+// annotation of the parent resource. The parent is only updated if the
+// annotation changed
+func writeValuesSources(ctx context.Context, r ControllerClient, parent client.Object, sources valueSources) error {
+	raw, err := json.Marshal(sources.contributors())
+	if err != nil {
+		return fmt.Errorf("cannot encode values sources: %w", err)
+	}
+	data := string(raw)
+
+	if parent.GetAnnotations()[selfapi.ValuesSourcesAnnotation] == data {
+		return nil
+	}
+
+	return patchParentMetadata(ctx, r, parent, func(obj client.Object) {
+		annotations := obj.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[selfapi.ValuesSourcesAnnotation] = data
+		obj.SetAnnotations(annotations)
+	})
+}
+
+// Values of a Gateway as served by the values debug endpoint
+type valuesDebugResponse struct {
+	Values       map[string]any               `json:"values"`
+	Sources      map[string]valuesSourceEntry `json:"sources"`
+	Contributors []valuesSourceEntry          `json:"contributors"`
+}
+
+// HTTP handler serving the merged values of a Gateway together with
+// the blueprint or policy which supplied each value. The Gateway is
+// given by the 'namespace' and 'name' query parameters. Values from
+// Secrets are redacted
+type ValuesDebugHandler struct {
+	client client.Client
+	scheme *runtime.Scheme
+}
+
+func NewValuesDebugHandler(mgr manager.Manager) *ValuesDebugHandler {
+	return &ValuesDebugHandler{
+		client: mgr.GetClient(),
+		scheme: mgr.GetScheme(),
+	}
+}
+
+func (h *ValuesDebugHandler) Client() client.Client {
+	return h.client
+}
+
+func (h *ValuesDebugHandler) Scheme() *runtime.Scheme {
+	return h.scheme
+}
+
+func (h *ValuesDebugHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	namespace, name := req.URL.Query().Get("namespace"), req.URL.Query().Get("name")
+	if namespace == "" || name == "" {
+		http.Error(w, "query parameters 'namespace' and 'name' are required", http.StatusBadRequest)
+		return
+	}
+
+	resp, status, err := h.lookup(req.Context(), namespace, name)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(resp)
+}
+
+func (h *ValuesDebugHandler) lookup(ctx context.Context, namespace, name string) (*valuesDebugResponse, int, error) {
+	errStatus := func(err error) int {
+		if apierrors.IsNotFound(err) {
+			return http.StatusNotFound
+		}
+		return http.StatusInternalServerError
+	}
+
+	gw, err := lookupGateway(ctx, h, gatewayapi.ObjectName(name), namespace)
+	if err != nil {
+		return nil, errStatus(err), fmt.Errorf("gateway %s/%s: %w", namespace, name, err)
+	}
+	gwc, err := lookupGatewayClass(ctx, h, gw.Spec.GatewayClassName)
+	if err != nil {
+		return nil, errStatus(err), fmt.Errorf("gatewayclass %s: %w", gw.Spec.GatewayClassName, err)
+	}
+	if !isOurGatewayClass(gwc) {
+		return nil, http.StatusNotFound, fmt.Errorf("gatewayclass %s not handled by this controller", gwc.Name)
+	}
+	gwcb, err := lookupGatewayClassBlueprint(ctx, h, gwc)
+	if err != nil {
+		return nil, errStatus(err), fmt.Errorf("blueprint of gatewayclass %s: %w", gwc.Name, err)
+	}
+	values, sources, err := lookupValuesWithSources(ctx, h, gwc.Name, gwcb, gw.Namespace, gw.Name, nil)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("cannot lookup values: %w", err)
+	}
+
+	resp := &valuesDebugResponse{
+		Values:       redactSensitiveValues(values, sources),
+		Sources:      map[string]valuesSourceEntry{},
+		Contributors: sources.contributors(),
+	}
+	for key, src := range sources {
+		resp.Sources[dottedValuePath(key)] = src.entry(true)
+	}
+	return resp, http.StatusOK, nil
+}
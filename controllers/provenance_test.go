@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+	selfapi "github.com/tv2-oss/bifrost-gateway-controller/pkg/api"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+func helperProvenanceHandler() *ValuesDebugHandler {
+	scheme := runtime.NewScheme()
+	_ = gatewayapi.Install(scheme)
+	_ = gwcapi.AddToScheme(scheme)
+
+	gwc := &gatewayapi.GatewayClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "gwc"},
+		Spec: gatewayapi.GatewayClassSpec{
+			ControllerName: selfapi.SelfControllerName,
+			ParametersRef:  &gatewayapi.ParametersReference{Group: "gateway.tv2.dk", Kind: "GatewayClassBlueprint", Name: "blueprint"},
+		},
+	}
+	gw := &gatewayapi.Gateway{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "gw"},
+		Spec:       gatewayapi.GatewaySpec{GatewayClassName: "gwc"},
+	}
+	return &ValuesDebugHandler{
+		client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(gwc, gw,
+			helperValuesBlueprint(`{"vpcId": "vpc-0", "healthCheck": {"path": "/"}}`),
+			helperGatewayConfig("gw-config", time.Hour, `{"vpcId": "vpc-1"}`)).Build(),
+		scheme: scheme,
+	}
+}
+
+func TestValueSourcesContributors(t *testing.T) {
+	a := valueSource{objectRef: objectRef{Kind: "GatewayConfig", Namespace: "default", Name: "a"}, ResourceVersion: "1"}
+	b := valueSource{objectRef: objectRef{Kind: "GatewayClassConfig", Namespace: "default", Name: "b"}, ResourceVersion: "2", Override: true}
+	sources := valueSources{"foo": a, "bar": b, "baz": a}
+	expected := []valuesSourceEntry{
+		{Kind: "GatewayClassConfig", Namespace: "default", Name: "b", ResourceVersion: "2"},
+		{Kind: "GatewayConfig", Namespace: "default", Name: "a", ResourceVersion: "1"},
+	}
+	if got := sources.contributors(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("contributors() got %v, expected %v", got, expected)
+	}
+}
+
+func TestWriteValuesSources(t *testing.T) {
+	h := helperProvenanceHandler()
+	gw := &gatewayapi.Gateway{}
+	if err := h.client.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "gw"}, gw); err != nil {
+		t.Fatal(err)
+	}
+	sources := valueSources{"foo": {objectRef: objectRef{Kind: "GatewayConfig", Namespace: "default", Name: "a"}, ResourceVersion: "1"}}
+	if err := writeValuesSources(context.Background(), h, gw, sources); err != nil {
+		t.Fatalf("writeValuesSources() failed: %v", err)
+	}
+	expected := `[{"group":"","kind":"GatewayConfig","namespace":"default","name":"a","resourceVersion":"1"}]`
+	if got := gw.Annotations[selfapi.ValuesSourcesAnnotation]; got != expected {
+		t.Errorf("got annotation %q, expected %q", got, expected)
+	}
+	resourceVersion := gw.ResourceVersion
+	if err := writeValuesSources(context.Background(), h, gw, sources); err != nil || gw.ResourceVersion != resourceVersion {
+		t.Errorf("expected no update for unchanged sources, error %v", err)
+	}
+}
+
+func TestValuesDebugHandler(t *testing.T) {
+	h := helperProvenanceHandler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/values?namespace=default&name=gw", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body.String())
+	}
+	resp := valuesDebugResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Values["vpcId"] != "vpc-1" {
+		t.Errorf("unexpected values %v", resp.Values)
+	}
+	if src := resp.Sources["vpcId"]; src.Kind != "GatewayConfig" || src.Name != "gw-config" || src.Type != "override" || src.ResourceVersion == "" {
+		t.Errorf("unexpected source %+v", src)
+	}
+	if src := resp.Sources["healthCheck.path"]; src.Kind != "GatewayClassBlueprint" || src.Type != "default" {
+		t.Errorf("unexpected source %+v", src)
+	}
+	if len(resp.Contributors) != 2 {
+		t.Errorf("unexpected contributors %v", resp.Contributors)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/values?namespace=default&name=missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("got status %d for missing gateway", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/values", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("got status %d without query parameters", rec.Code)
+	}
+}
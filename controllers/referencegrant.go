@@ -0,0 +1,242 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1b1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+// Cross-namespace references, e.g. from a route in one namespace to a
+// Service in another namespace, must be permitted by a ReferenceGrant
+// in the namespace of the referenced object. See
+// https://gateway-api.sigs.k8s.io/api-types/referencegrant/
+
+// Group, kind, namespace and name of an object. Name is not used for the referencing object
+type objectRef struct {
+	Group     string
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+// ReferenceGrants by namespace
+type referenceGrants map[string][]gatewayv1b1.ReferenceGrant
+
+// Lookup all ReferenceGrants
+func lookupReferenceGrants(ctx context.Context, r ControllerClient) (referenceGrants, error) {
+	var rgList gatewayv1b1.ReferenceGrantList
+	if err := r.Client().List(ctx, &rgList); err != nil {
+		return nil, fmt.Errorf("cannot list referencegrants: %w", err)
+	}
+	grants := referenceGrants{}
+	for _, rg := range rgList.Items {
+		grants[rg.Namespace] = append(grants[rg.Namespace], rg)
+	}
+	return grants, nil
+}
+
+// Whether a reference is permitted, i.e. the referenced object is in
+// the same namespace or a ReferenceGrant in the namespace of the
+// referenced object permits the reference
+func (grants referenceGrants) isPermitted(from, to objectRef) bool {
+	if from.Namespace == to.Namespace {
+		return true
+	}
+	for _, rg := range grants[to.Namespace] {
+		fromOK, toOK := false, false
+		for _, f := range rg.Spec.From {
+			fromOK = fromOK || (string(f.Group) == from.Group && string(f.Kind) == from.Kind && string(f.Namespace) == from.Namespace)
+		}
+		for _, t := range rg.Spec.To {
+			toOK = toOK || (string(t.Group) == to.Group && string(t.Kind) == to.Kind && (t.Name == nil || string(*t.Name) == to.Name))
+		}
+		if fromOK && toOK {
+			return true
+		}
+	}
+	return false
+}
+
+// Backend references of a route permitted by ReferenceGrants
+type resolvedBackends struct {
+	// Permitted backend references by route rule index, with namespace, group and kind set
+	Rules [][]gatewayapi.BackendRef
+
+	// Backend references not permitted
+	NotPermitted []gatewayapi.BackendRef
+
+	// Whether each backend reference is permitted, by route rule index
+	permitted [][]bool
+}
+
+// Resolve backend references of a route against ReferenceGrants
+func (grants referenceGrants) resolveBackends(rtType *routeType, rt client.Object) *resolvedBackends {
+	resolved := &resolvedBackends{Rules: [][]gatewayapi.BackendRef{}}
+	from := objectRef{Group: rtType.GroupVersion.Group, Kind: rtType.Kind, Namespace: rt.GetNamespace()}
+	for _, rule := range routeBackendRefs(rt) {
+		permitted := []gatewayapi.BackendRef{}
+		isPermitted := []bool{}
+		for _, ref := range rule {
+			ref = *ref.DeepCopy()
+			if ref.Group == nil {
+				ref.Group = PtrTo(gatewayapi.Group(""))
+			}
+			if ref.Kind == nil {
+				ref.Kind = PtrTo(gatewayapi.Kind("Service"))
+			}
+			if ref.Namespace == nil {
+				ref.Namespace = PtrTo(gatewayapi.Namespace(rt.GetNamespace()))
+			}
+			to := objectRef{Group: string(*ref.Group), Kind: string(*ref.Kind), Namespace: string(*ref.Namespace), Name: string(ref.Name)}
+			ok := grants.isPermitted(from, to)
+			if ok {
+				permitted = append(permitted, ref)
+			} else {
+				resolved.NotPermitted = append(resolved.NotPermitted, ref)
+			}
+			isPermitted = append(isPermitted, ok)
+		}
+		resolved.Rules = append(resolved.Rules, permitted)
+		resolved.permitted = append(resolved.permitted, isPermitted)
+	}
+	return resolved
+}
+
+// Remove backend references not permitted by ReferenceGrants from a
+// route converted to a map, such that templates copying rules of the
+// route, e.g. '.HTTPRoute.spec.rules', cannot reference backends which
+// are not permitted
+func (resolved *resolvedBackends) removeNotPermitted(rtMap map[string]any) {
+	spec, _ := rtMap["spec"].(map[string]any)
+	rules, _ := spec["rules"].([]any)
+	for ruleIdx, r := range rules {
+		rule, ok := r.(map[string]any)
+		if !ok || ruleIdx >= len(resolved.permitted) {
+			continue
+		}
+		refs, _ := rule["backendRefs"].([]any)
+		if len(refs) != len(resolved.permitted[ruleIdx]) {
+			continue
+		}
+		kept := []any{}
+		for refIdx, ref := range refs {
+			if resolved.permitted[ruleIdx][refIdx] {
+				kept = append(kept, ref)
+			}
+		}
+		rule["backendRefs"] = kept
+	}
+}
+
+// Certificate references of Gateway listeners permitted by ReferenceGrants
+type resolvedCertificateRefs struct {
+	// Permitted certificate references by listener name, with namespace, group and kind set
+	Listeners map[gatewayapi.SectionName][]gatewayapi.SecretObjectReference
+
+	// Certificate references not permitted by listener name
+	NotPermitted map[gatewayapi.SectionName][]gatewayapi.SecretObjectReference
+}
+
+// Resolve listener TLS certificate references of a Gateway against ReferenceGrants
+func (grants referenceGrants) resolveCertificateRefs(gw *gatewayapi.Gateway) *resolvedCertificateRefs {
+	resolved := &resolvedCertificateRefs{
+		Listeners:    map[gatewayapi.SectionName][]gatewayapi.SecretObjectReference{},
+		NotPermitted: map[gatewayapi.SectionName][]gatewayapi.SecretObjectReference{},
+	}
+	from := objectRef{Group: gatewayapi.GroupName, Kind: "Gateway", Namespace: gw.Namespace}
+	for _, l := range gw.Spec.Listeners {
+		if l.TLS == nil {
+			continue
+		}
+		permitted := []gatewayapi.SecretObjectReference{}
+		for _, ref := range l.TLS.CertificateRefs {
+			ref = *ref.DeepCopy()
+			if ref.Group == nil {
+				ref.Group = PtrTo(gatewayapi.Group(""))
+			}
+			if ref.Kind == nil {
+				ref.Kind = PtrTo(gatewayapi.Kind("Secret"))
+			}
+			if ref.Namespace == nil {
+				ref.Namespace = PtrTo(gatewayapi.Namespace(gw.Namespace))
+			}
+			to := objectRef{Group: string(*ref.Group), Kind: string(*ref.Kind), Namespace: string(*ref.Namespace), Name: string(ref.Name)}
+			if grants.isPermitted(from, to) {
+				permitted = append(permitted, ref)
+			} else {
+				resolved.NotPermitted[l.Name] = append(resolved.NotPermitted[l.Name], ref)
+			}
+		}
+		resolved.Listeners[l.Name] = permitted
+	}
+	return resolved
+}
+
+// Convert backend references to template values, i.e. a list of backend references per route rule
+func (resolved *resolvedBackends) templateValue() ([][]map[string]any, error) {
+	rules := [][]map[string]any{}
+	for _, refs := range resolved.Rules {
+		rule := []map[string]any{}
+		for idx := range refs {
+			m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&refs[idx])
+			if err != nil {
+				return nil, fmt.Errorf("cannot convert backendRef: %w", err)
+			}
+			rule = append(rule, m)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Convert certificate references to template values, i.e. a list of certificate references per listener name
+func (resolved *resolvedCertificateRefs) templateValue() (map[string][]map[string]any, error) {
+	listeners := map[string][]map[string]any{}
+	for name, refs := range resolved.Listeners {
+		listener := []map[string]any{}
+		for idx := range refs {
+			m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&refs[idx])
+			if err != nil {
+				return nil, fmt.Errorf("cannot convert certificateRef: %w", err)
+			}
+			listener = append(listener, m)
+		}
+		listeners[string(name)] = listener
+	}
+	return listeners, nil
+}
@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1b1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+func helperReferenceGrants() referenceGrants {
+	return referenceGrants{
+		"backends": {{
+			ObjectMeta: metav1.ObjectMeta{Name: "allow-routes", Namespace: "backends"},
+			Spec: gatewayv1b1.ReferenceGrantSpec{
+				From: []gatewayv1b1.ReferenceGrantFrom{{Group: gatewayapi.GroupName, Kind: "HTTPRoute", Namespace: "default"}},
+				To:   []gatewayv1b1.ReferenceGrantTo{{Group: "", Kind: "Service", Name: PtrTo(gatewayapi.ObjectName("permitted"))}},
+			},
+		}},
+		"certs": {{
+			ObjectMeta: metav1.ObjectMeta{Name: "allow-gateways", Namespace: "certs"},
+			Spec: gatewayv1b1.ReferenceGrantSpec{
+				From: []gatewayv1b1.ReferenceGrantFrom{{Group: gatewayapi.GroupName, Kind: "Gateway", Namespace: "default"}},
+				To:   []gatewayv1b1.ReferenceGrantTo{{Group: "", Kind: "Secret"}},
+			},
+		}},
+	}
+}
+
+func TestReferenceGrantIsPermitted(t *testing.T) {
+	grants := helperReferenceGrants()
+	route := objectRef{Group: gatewayapi.GroupName, Kind: "HTTPRoute", Namespace: "default"}
+	testCases := []struct {
+		name     string
+		from     objectRef
+		to       objectRef
+		expected bool
+	}{
+		{"same-namespace", route, objectRef{Kind: "Service", Namespace: "default", Name: "foo"}, true},
+		{"granted", route, objectRef{Kind: "Service", Namespace: "backends", Name: "permitted"}, true},
+		{"name-not-granted", route, objectRef{Kind: "Service", Namespace: "backends", Name: "foo"}, false},
+		{"kind-not-granted", route, objectRef{Kind: "Secret", Namespace: "backends", Name: "permitted"}, false},
+		{"namespace-not-granted", objectRef{Group: gatewayapi.GroupName, Kind: "HTTPRoute", Namespace: "other"},
+			objectRef{Kind: "Service", Namespace: "backends", Name: "permitted"}, false},
+		{"from-kind-not-granted", objectRef{Group: gatewayapi.GroupName, Kind: "GRPCRoute", Namespace: "default"},
+			objectRef{Kind: "Service", Namespace: "backends", Name: "permitted"}, false},
+		{"no-grants-in-namespace", route, objectRef{Kind: "Service", Namespace: "other", Name: "permitted"}, false},
+		{"any-name-granted", objectRef{Group: gatewayapi.GroupName, Kind: "Gateway", Namespace: "default"},
+			objectRef{Kind: "Secret", Namespace: "certs", Name: "foo"}, true},
+	}
+
+	for _, tc := range testCases {
+		if permitted := grants.isPermitted(tc.from, tc.to); permitted != tc.expected {
+			t.Errorf("%s: isPermitted() got %v, expected %v", tc.name, permitted, tc.expected)
+		}
+	}
+}
+
+func TestResolveBackends(t *testing.T) {
+	backendRef := func(name string, namespace *gatewayapi.Namespace) gatewayapi.HTTPBackendRef {
+		return gatewayapi.HTTPBackendRef{BackendRef: gatewayapi.BackendRef{
+			BackendObjectReference: gatewayapi.BackendObjectReference{Name: gatewayapi.ObjectName(name), Namespace: namespace}}}
+	}
+	backends := PtrTo(gatewayapi.Namespace("backends"))
+	rt := &gatewayapi.HTTPRoute{ObjectMeta: metav1.ObjectMeta{Name: "rt", Namespace: "default"}}
+	rt.Spec.Rules = []gatewayapi.HTTPRouteRule{
+		{BackendRefs: []gatewayapi.HTTPBackendRef{backendRef("local", nil), backendRef("permitted", backends)}},
+		{BackendRefs: []gatewayapi.HTTPBackendRef{backendRef("not-permitted", backends)}},
+	}
+
+	resolved := helperReferenceGrants().resolveBackends(httpRouteType, rt)
+
+	names := func(refs []gatewayapi.BackendRef) []string {
+		out := []string{}
+		for _, ref := range refs {
+			out = append(out, string(*ref.Namespace)+"/"+string(ref.Name))
+		}
+		return out
+	}
+	if len(resolved.Rules) != 2 {
+		t.Fatalf("expected 2 rules, got %d", len(resolved.Rules))
+	}
+	if got := names(resolved.Rules[0]); !reflect.DeepEqual(got, []string{"default/local", "backends/permitted"}) {
+		t.Errorf("rule 0 permitted got %v", got)
+	}
+	if got := names(resolved.Rules[1]); !reflect.DeepEqual(got, []string{}) {
+		t.Errorf("rule 1 permitted got %v", got)
+	}
+	if got := names(resolved.NotPermitted); !reflect.DeepEqual(got, []string{"backends/not-permitted"}) {
+		t.Errorf("not permitted got %v", got)
+	}
+	if cond := resolvedRefsCondition(resolved); cond.Status != metav1.ConditionFalse ||
+		cond.Reason != string(gatewayapi.RouteReasonRefNotPermitted) {
+		t.Errorf("unexpected condition %+v", cond)
+	}
+
+	values, err := resolved.templateValue()
+	if err != nil {
+		t.Fatalf("templateValue() failed: %v", err)
+	}
+	if values[0][0]["kind"] != "Service" || values[0][0]["namespace"] != "default" {
+		t.Errorf("unexpected template value %v", values[0][0])
+	}
+
+	// Backends not permitted are removed from the route given to templates
+	rtMap, err := objectToMap(rt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolved.removeNotPermitted(rtMap)
+	rules := rtMap["spec"].(map[string]any)["rules"].([]any)
+	if refs := rules[0].(map[string]any)["backendRefs"].([]any); len(refs) != 2 {
+		t.Errorf("unexpected rule 0 backendRefs %v", refs)
+	}
+	if refs := rules[1].(map[string]any)["backendRefs"].([]any); len(refs) != 0 {
+		t.Errorf("unexpected rule 1 backendRefs %v", refs)
+	}
+}
+
+func TestResolveCertificateRefs(t *testing.T) {
+	gw := &gatewayapi.Gateway{
+		ObjectMeta: metav1.ObjectMeta{Name: "gw", Namespace: "default"},
+		Spec: gatewayapi.GatewaySpec{
+			Listeners: []gatewayapi.Listener{
+				{Name: "http", Protocol: gatewayapi.HTTPProtocolType},
+				{Name: "https", Protocol: gatewayapi.HTTPSProtocolType, TLS: &gatewayapi.GatewayTLSConfig{
+					CertificateRefs: []gatewayapi.SecretObjectReference{
+						{Name: "local"},
+						{Name: "granted", Namespace: PtrTo(gatewayapi.Namespace("certs"))},
+						{Name: "not-granted", Namespace: PtrTo(gatewayapi.Namespace("other"))},
+					}}},
+			},
+		},
+	}
+
+	resolved := helperReferenceGrants().resolveCertificateRefs(gw)
+
+	names := func(refs []gatewayapi.SecretObjectReference) []string {
+		out := []string{}
+		for _, ref := range refs {
+			out = append(out, string(*ref.Namespace)+"/"+string(ref.Name))
+		}
+		return out
+	}
+	if _, found := resolved.Listeners["http"]; found {
+		t.Errorf("unexpected certificate refs for listener without TLS")
+	}
+	if got := names(resolved.Listeners["https"]); !reflect.DeepEqual(got, []string{"default/local", "certs/granted"}) {
+		t.Errorf("permitted got %v", got)
+	}
+	if got := names(resolved.NotPermitted["https"]); !reflect.DeepEqual(got, []string{"other/not-granted"}) {
+		t.Errorf("not permitted got %v", got)
+	}
+}
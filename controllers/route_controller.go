@@ -0,0 +1,522 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1b1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+	selfapi "github.com/tv2-oss/bifrost-gateway-controller/pkg/api"
+)
+
+// RouteReconciler reconciles route objects, e.g. HTTPRoute, GRPCRoute and TLSRoute
+type RouteReconciler struct {
+	client    client.Client
+	scheme    *runtime.Scheme
+	dynClient dynamic.Interface
+	watches   *childWatches
+	rtType    *routeType
+}
+
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes/finalizers,verbs=update
+
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=grpcroutes,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=grpcroutes/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=grpcroutes/finalizers,verbs=update
+
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=tlsroutes,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=tlsroutes/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=tlsroutes/finalizers,verbs=update
+
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=tcproutes,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=tcproutes/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=tcproutes/finalizers,verbs=update
+
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=udproutes,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=udproutes/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=udproutes/finalizers,verbs=update
+
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=referencegrants,verbs=get;list;watch
+
+func (r *RouteReconciler) Client() client.Client {
+	return r.client
+}
+
+func (r *RouteReconciler) Scheme() *runtime.Scheme {
+	return r.scheme
+}
+
+func (r *RouteReconciler) DynamicClient() dynamic.Interface {
+	return r.dynClient
+}
+
+func newRouteController(mgr ctrl.Manager, config *rest.Config, rtType *routeType) *RouteReconciler {
+	r := &RouteReconciler{
+		client:    mgr.GetClient(),
+		scheme:    mgr.GetScheme(),
+		dynClient: dynamic.NewForConfigOrDie(config),
+		rtType:    rtType,
+	}
+	return r
+}
+
+func NewHTTPRouteController(mgr ctrl.Manager, config *rest.Config) *RouteReconciler {
+	return newRouteController(mgr, config, httpRouteType)
+}
+
+func NewGRPCRouteController(mgr ctrl.Manager, config *rest.Config) *RouteReconciler {
+	return newRouteController(mgr, config, grpcRouteType)
+}
+
+func NewTLSRouteController(mgr ctrl.Manager, config *rest.Config) *RouteReconciler {
+	return newRouteController(mgr, config, tlsRouteType)
+}
+
+func NewTCPRouteController(mgr ctrl.Manager, config *rest.Config) *RouteReconciler {
+	return newRouteController(mgr, config, tcpRouteType)
+}
+
+func NewUDPRouteController(mgr ctrl.Manager, config *rest.Config) *RouteReconciler {
+	return newRouteController(mgr, config, udpRouteType)
+}
+
+func (r *RouteReconciler) watchChildKind(gvk schema.GroupVersionKind) error {
+	return r.watches.watchChildKind(gvk)
+}
+
+func (r *RouteReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if !isKindAvailable(mgr.GetRESTMapper(), r.rtType.GroupVersionKind()) {
+		mgr.GetLogger().Info("route kind not available, controller disabled", "kind", r.rtType.Kind)
+		return nil
+	}
+	c, err := ctrl.NewControllerManagedBy(mgr).
+		For(r.rtType.NewRoute()).
+		// Gateways, including status, are available to route templates
+		Watches(&gatewayapi.Gateway{}, handler.EnqueueRequestsFromMapFunc(mapGatewaysToRoutes(mgr.GetClient(), r.rtType, mapGatewayToSelf))).
+		Watches(&gatewayapi.GatewayClass{}, handler.EnqueueRequestsFromMapFunc(
+			mapGatewaysToRoutes(mgr.GetClient(), r.rtType, mapGatewayClassToGateways(mgr.GetClient())))).
+		Watches(&gwcapi.GatewayClassBlueprint{}, handler.EnqueueRequestsFromMapFunc(
+			mapGatewaysToRoutes(mgr.GetClient(), r.rtType, mapBlueprintToGateways(mgr.GetClient())))).
+		Watches(&gwcapi.GatewayClassConfig{}, handler.EnqueueRequestsFromMapFunc(
+			mapGatewaysToRoutes(mgr.GetClient(), r.rtType, mapPolicyToGateways(mgr.GetClient()))),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&gwcapi.GatewayConfig{}, handler.EnqueueRequestsFromMapFunc(
+			mapPolicyToRoutes(mgr.GetClient(), r.rtType)),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(
+			mapValuesFromReferrers(mgr.GetClient(), gwcapi.ValuesFromConfigMap, r.mapValuesReferrer))).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(
+			mapValuesFromReferrers(mgr.GetClient(), gwcapi.ValuesFromSecret, r.mapValuesReferrer))).
+		Watches(&corev1.Namespace{}, handler.EnqueueRequestsFromMapFunc(
+			mapGatewaysToRoutes(mgr.GetClient(), r.rtType, mapNamespaceToGateways(mgr.GetClient()))),
+			builder.WithPredicates(predicate.LabelChangedPredicate{})).
+		Watches(&gatewayv1b1.ReferenceGrant{}, handler.EnqueueRequestsFromMapFunc(
+			mapReferenceGrantToReferrers(mgr.GetClient(), r.rtType.GroupVersion.Group, r.rtType.Kind, r.rtType.NewRouteList))).
+		Build(r)
+	if err != nil {
+		return err
+	}
+	r.watches = newChildWatches(c, mgr.GetCache(), r.rtType.Kind)
+	return nil
+}
+
+// Compare values referenced by pointers. Both a and b must be pointers to the same type
+func derefCmp[T comparable](a, b *T) bool {
+	if (a != nil && b == nil) || (a == nil && b != nil) {
+		return false // Different since one has nil pointer while other has non-nil
+	}
+	if a != nil && b != nil && (*a != *b) {
+		return false // Actual value is different
+	}
+	return true
+}
+
+// Compare parentRefs, return true if same parent is referenced
+func parentRefCmp(a, b gatewayapi.ParentReference) bool {
+	// Group, Kind and Namespace, SectionName and Port are pointers and optional (may be nil)
+	if !derefCmp(a.Group, b.Group) || !derefCmp(a.Kind, b.Kind) || !derefCmp(a.Namespace, b.Namespace) ||
+		!derefCmp(a.SectionName, b.SectionName) || !derefCmp(a.Port, b.Port) {
+		return false
+	}
+	return a.Name == b.Name
+}
+
+// Route ResolvedRefs condition from backend references resolved against ReferenceGrants
+func resolvedRefsCondition(backends *resolvedBackends) *metav1.Condition {
+	if len(backends.NotPermitted) == 0 {
+		return &metav1.Condition{
+			Type:   string(gatewayapi.RouteConditionResolvedRefs),
+			Status: metav1.ConditionTrue,
+			Reason: string(gatewayapi.RouteReasonResolvedRefs),
+		}
+	}
+	refs := []string{}
+	for _, ref := range backends.NotPermitted {
+		refs = append(refs, fmt.Sprintf("%s/%s/%s", *ref.Kind, *ref.Namespace, ref.Name))
+	}
+	return &metav1.Condition{
+		Type:    string(gatewayapi.RouteConditionResolvedRefs),
+		Status:  metav1.ConditionFalse,
+		Reason:  string(gatewayapi.RouteReasonRefNotPermitted),
+		Message: fmt.Sprintf("references not permitted by ReferenceGrants: %s", strings.Join(refs, ",")),
+	}
+}
+
+// Lookup Gateway from parentRef of a route
+func lookupParent(ctx context.Context, r ControllerClient, rt client.Object, p gatewayapi.ParentReference) (*gatewayapi.Gateway, error) {
+	if p.Namespace == nil {
+		return lookupGateway(ctx, r, p.Name, rt.GetNamespace())
+	}
+	return lookupGateway(ctx, r, p.Name, string(*p.Namespace))
+}
+
+// Find statuscondition for a specific parentRef
+func findParentRouteStatus(rtStatus *gatewayapi.RouteStatus, parent gatewayapi.ParentReference) *gatewayapi.RouteParentStatus {
+	for i := range rtStatus.Parents {
+		pStat := &rtStatus.Parents[i]
+		if parentRefCmp(pStat.ParentRef, parent) && pStat.ControllerName == selfapi.SelfControllerName {
+			return pStat
+		}
+	}
+	return nil
+}
+
+// Set status condition for a specific parentRef
+func setRouteStatusCondition(rtStatus *gatewayapi.RouteStatus, parent gatewayapi.ParentReference, newCondition *metav1.Condition) {
+	if newCondition.LastTransitionTime.IsZero() {
+		newCondition.LastTransitionTime = metav1.NewTime(time.Now())
+	}
+
+	existingParentRouteStat := findParentRouteStatus(rtStatus, parent)
+	if existingParentRouteStat == nil {
+		newStatus := gatewayapi.RouteParentStatus{
+			ParentRef:      parent,
+			ControllerName: selfapi.SelfControllerName,
+			Conditions:     []metav1.Condition{*newCondition},
+		}
+		rtStatus.Parents = append(rtStatus.Parents, newStatus)
+		return
+	}
+
+	meta.SetStatusCondition(&existingParentRouteStat.Conditions, *newCondition)
+}
+
+// Remove status conditions for a specific parentRef
+func removeRouteStatusConditions(rtStatus *gatewayapi.RouteStatus, parent gatewayapi.ParentReference, conditionTypes ...string) {
+	if pStat := findParentRouteStatus(rtStatus, parent); pStat != nil {
+		for _, condType := range conditionTypes {
+			meta.RemoveStatusCondition(&pStat.Conditions, condType)
+		}
+	}
+}
+
+// Map a blueprint or policy using a ConfigMap or Secret for values to
+// routes of our kind, i.e. routes attached to affected Gateways and
+// routes targeted by GatewayConfigs
+func (r *RouteReconciler) mapValuesReferrer(referrer client.Object) handler.MapFunc {
+	switch referrer.(type) {
+	case *gwcapi.GatewayClassBlueprint:
+		return mapGatewaysToRoutes(r.Client(), r.rtType, mapBlueprintToGateways(r.Client()))
+	case *gwcapi.GatewayConfig:
+		return mapPolicyToRoutes(r.Client(), r.rtType)
+	}
+	return mapGatewaysToRoutes(r.Client(), r.rtType, mapPolicyToGateways(r.Client()))
+}
+
+func (r *RouteReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	var doStatusUpdate = false
+	var requeue = false
+	rt := r.rtType.NewRoute()
+	if err := r.Client().Get(ctx, req.NamespacedName, rt); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	logger.Info(r.rtType.Kind)
+
+	if !rt.GetDeletionTimestamp().IsZero() {
+		return finalizeParent(ctx, r, rt)
+	}
+
+	// Only backends permitted by ReferenceGrants are available to templates
+	grants, err := lookupReferenceGrants(ctx, r)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	backends := grants.resolveBackends(r.rtType, rt)
+
+	// Prepare route resource for use in templates by converting to map[string]any
+	rtMap, err := objectToMap(rt)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("cannot convert %s to map: %w", r.rtType.Kind, err)
+	}
+	backends.removeNotPermitted(rtMap)
+
+	templateValues := TemplateValues{}
+	r.rtType.SetTemplateValue(&templateValues, rtMap)
+	if templateValues.ResolvedBackends, err = backends.templateValue(); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Prepare for setting status in parentRef loop
+	status := routeStatus(rt)
+	beforeStatusUpdate := status.DeepCopy()
+	if status.Parents == nil {
+		status.Parents = []gatewayapi.RouteParentStatus{}
+	}
+
+	// Child resources rendered across all parents, used for pruning
+	rendered := []InventoryEntry{}
+	prunePolicy := gwcapi.PrunePolicyDelete
+
+	// Errors from applying templates. Reflected in the status of each parent and returned after the status update
+	var errStatus error
+
+	// Whether values for some parent are invalid, i.e. the route was not rendered for all parents
+	valuesInvalid := false
+
+	// Loop through Gateway parents, render route using templates defined by associated GatewayClassBlueprint
+	for _, parent := range routeCommonSpec(rt).ParentRefs {
+		if *parent.Kind != gatewayapi.Kind("Gateway") {
+			continue
+		}
+
+		gw, err := lookupParent(ctx, r, rt, parent)
+		if err != nil {
+			logger.Info("gateway for route not found", "route", rt.GetName(), "parent", parent)
+			requeue = true
+			continue
+		}
+
+		gwc, err := lookupGatewayClass(ctx, r, gw.Spec.GatewayClassName)
+		if err != nil {
+			logger.Info("gatewayClass not found", "gatewayclassname", gw.Spec.GatewayClassName)
+			requeue = true
+			continue
+		}
+		if !isOurGatewayClass(gwc) {
+			continue
+		}
+
+		doStatusUpdate = true
+		setRouteStatusCondition(status, parent, resolvedRefsCondition(backends))
+
+		gwcb, err := lookupGatewayClassBlueprint(ctx, r, gwc)
+		if err != nil {
+			logger.Info("parameters for GatewayClass not found", "gatewayclassparameters", gwc.Name)
+			requeue = true
+			continue
+		}
+
+		// Only render routes attached to at least one listener
+		attacher, err := newGatewayAttacher(ctx, r, gw, gwcb, []*routeType{r.rtType})
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		attachment := attacher.attachRoute(rt, &parent)
+		if !attachment.Accepted() {
+			logger.Info("route not attached to gateway", "gateway", gw.Name, "reason", attachment.Reason)
+			doStatusUpdate = true
+			setRouteStatusCondition(status, parent,
+				&metav1.Condition{
+					Type:    string(gatewayapi.RouteConditionAccepted),
+					Status:  "False",
+					Reason:  string(attachment.Reason),
+					Message: attachment.Message,
+				})
+			// Route not rendered for this parent
+			removeRouteStatusConditions(status, parent, selfapi.RouteConditionProgrammed, selfapi.RouteConditionReady,
+				selfapi.ConditionStatusRendered)
+			continue
+		}
+
+		values, sources, err := lookupValuesWithSources(ctx, r, gwc.Name, gwcb, gw.Namespace, gw.Name,
+			&routeValuesScope{route: rt, listeners: attachment.Listeners})
+		if err != nil {
+			return ctrl.Result{}, fmt.Errorf("cannot lookup values: %w", err)
+		}
+		violations, err := validateValuesSchema(gwcb.Spec.ValuesSchema, values, sources)
+		if err == nil {
+			err = violationsError(violations)
+		}
+		if err != nil {
+			// Child resources are kept, i.e. not pruned, until values are valid
+			logger.Info("invalid values", "gateway", gw.Name, "error", err)
+			valuesInvalid = true
+			setRouteStatusCondition(status, parent,
+				&metav1.Condition{
+					Type:               selfapi.RouteConditionProgrammed,
+					Status:             metav1.ConditionFalse,
+					Reason:             selfapi.RouteReasonInvalidValues,
+					Message:            err.Error(),
+					ObservedGeneration: rt.GetGeneration(),
+				})
+			removeRouteStatusConditions(status, parent, selfapi.RouteConditionReady, selfapi.ConditionStatusRendered)
+			continue
+		}
+		templateValues.Values = values
+		templateValues.sensitiveValues = sensitiveValues(values, sources)
+
+		// Prepare Gateway resource for use in templates by converting to map[string]any
+		gatewayMap, err := objectToMap(gw)
+		if err != nil {
+			return ctrl.Result{}, fmt.Errorf("cannot convert gateway to map: %w", err)
+		}
+		templateValues.Gateway = &gatewayMap
+
+		tmplSpec := r.rtType.Template(&gwcb.Spec)
+		templates, err := parseTemplates(tmplSpec.ResourceTemplates)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		templates, err = sortTemplates(templates, tmplSpec.TemplateOptions)
+		if err != nil {
+			return ctrl.Result{}, fmt.Errorf("cannot resolve template dependencies: %w", err)
+		}
+
+		// Cluster-scoped child resources are deleted through our finalizer
+		if err := ensureFinalizer(ctx, r, rt); err != nil {
+			return ctrl.Result{}, fmt.Errorf("cannot add finalizer: %w", err)
+		}
+
+		// Render and apply templates in dependency order
+		renderResult, err := renderAndApplyTemplates(ctx, r, rt, templates, &templateValues)
+		if err != nil {
+			errStatus = errors.Join(errStatus, fmt.Errorf("unable to apply templates for gateway %s/%s: %w", gw.Namespace, gw.Name, err))
+		}
+		// If we haven't already decided to requeue, then requeue if not all templates could render (possibly a missing dependency or a wave not ready)
+		requeue = requeue || (renderResult.Rendered != len(templates))
+		logger.Info("rendering done", "renderedNum", renderResult.Rendered, "existsNum", renderResult.Exists, "totalNum", len(templates), "requeue", requeue)
+
+		rendered = append(rendered, templatesToInventory(templates, rt.GetNamespace())...)
+		prunePolicy = conservativePrunePolicy(prunePolicy, gwcb.Spec.PrunePolicy)
+
+		doStatusUpdate = true
+		statusErr, err := updateRouteParentStatus(status, parent, rt.GetGeneration(), tmplSpec, templates, renderResult, &templateValues)
+		if err != nil {
+			logger.Error(err, "unable to update status condition due to sub-resource status error")
+			return ctrl.Result{}, err
+		}
+		if statusErr != nil {
+			logger.Info("unable to render status templates", "error", statusErr)
+			// Invalid status requires a change of the GatewayClassBlueprint, other errors are typically temporary
+			requeue = requeue || !errors.Is(statusErr, errInvalidStatus)
+		}
+	}
+
+	// Track child resources and prune resources no longer rendered
+	// from any parent. Pruning requires that all parents were
+	// rendered completely
+	if err := reconcileInventory(ctx, r, rt, rendered, prunePolicy, !requeue && errStatus == nil && !valuesInvalid); err != nil {
+		return ctrl.Result{}, fmt.Errorf("unable to reconcile inventory: %w", err)
+	}
+
+	if doStatusUpdate && !equality.Semantic.DeepEqual(beforeStatusUpdate, status) {
+		if err := updateStatus(ctx, r, rt); err != nil {
+			logger.Error(err, "unable to update route status", "kind", r.rtType.Kind)
+			return ctrl.Result{}, err
+		}
+	}
+
+	if requeue {
+		logger.Info("requeue - not all resources updated")
+		return ctrl.Result{RequeueAfter: dependencyMissingRequeuePeriod}, nil
+	}
+	return ctrl.Result{}, errStatus
+}
+
+// Update route status for a parent Gateway. The route is accepted,
+// while 'Programmed' and 'Ready' reflect the outcome of rendering and
+// applying templates. Conditions may also be set from the
+// parentConditions status template, e.g. from the status of child
+// resources. Returns the error from rendering status templates, if
+// any, and an error if the status of child resources cannot be
+// computed.
+func updateRouteParentStatus(status *gatewayapi.RouteStatus, parent gatewayapi.ParentReference, generation int64,
+	tmplSpec *gwcapi.ResourceSpec, templates []*ResourceTemplateState, renderResult *RenderResult,
+	templateValues *TemplateValues) (statusErr, err error) {
+	if tmplStr := tmplSpec.Status.ParentConditions; tmplStr != "" {
+		var conditions []metav1.Condition
+		conditions, statusErr = renderConditions("parentConditions", tmplStr, templateValues, routeOwnedConditions)
+		for idx := range conditions {
+			setRouteStatusCondition(status, parent, &conditions[idx])
+		}
+		cond := statusRenderedCondition(statusErr, generation)
+		setRouteStatusCondition(status, parent, &cond)
+	} else {
+		removeRouteStatusConditions(status, parent, selfapi.ConditionStatusRendered)
+	}
+
+	setRouteStatusCondition(status, parent,
+		&metav1.Condition{
+			Type:   string(gatewayapi.RouteConditionAccepted),
+			Status: "True",
+			Reason: string(gatewayapi.RouteReasonAccepted),
+		})
+	progCond := programmedCondition(templates, renderResult, generation)
+	setRouteStatusCondition(status, parent, &progCond)
+	readyCond, err := routeReadyCondition(templates, progCond.Status == metav1.ConditionTrue, generation)
+	if err != nil {
+		return statusErr, err
+	}
+	if readyCond.Status == metav1.ConditionTrue && statusErr != nil {
+		readyCond.Status = metav1.ConditionFalse
+		readyCond.Reason = selfapi.RouteReasonPending
+		readyCond.Message = "status templates not rendered"
+	}
+	setRouteStatusCondition(status, parent, &readyCond)
+	return statusErr, nil
+}
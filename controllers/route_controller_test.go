@@ -0,0 +1,178 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+	selfapi "github.com/tv2-oss/bifrost-gateway-controller/pkg/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+const routeTestGatewayClassManifest string = `
+apiVersion: gateway.networking.k8s.io/v1beta1
+kind: GatewayClass
+metadata:
+  name: route-test
+spec:
+  controllerName: "github.com/tv2-oss/bifrost-gateway-controller"
+  parametersRef:
+    group: gateway.tv2.dk
+    kind: GatewayClassBlueprint
+    name: route-test`
+
+const routeTestGatewayManifest string = `
+apiVersion: gateway.networking.k8s.io/v1beta1
+kind: Gateway
+metadata:
+  name: route-test
+  namespace: default
+spec:
+  gatewayClassName: route-test
+  listeners:
+  - name: grpc
+    port: 80
+    protocol: HTTP
+`
+
+const routeTestGatewayClassBlueprintManifest string = `
+apiVersion: gateway.tv2.dk/v1alpha1
+kind: GatewayClassBlueprint
+metadata:
+  name: route-test
+spec:
+  grpcRouteTemplate:
+    resourceTemplates:
+      configMap: |
+        apiVersion: v1
+        kind: ConfigMap
+        metadata:
+          name: {{ .GRPCRoute.metadata.name }}-grpc
+          namespace: {{ .GRPCRoute.metadata.namespace }}
+        data:
+          gateway: {{ .Gateway.metadata.name }}
+`
+
+const routeTestGRPCRouteManifest string = `
+apiVersion: gateway.networking.k8s.io/v1
+kind: GRPCRoute
+metadata:
+  name: route-test
+  namespace: default
+spec:
+  parentRefs:
+  - name: route-test
+  rules:
+  - backendRefs:
+    - name: grpc-service
+      port: 9000
+`
+
+var _ = Describe("Route controller", func() {
+
+	const (
+		timeout  = time.Second * 15
+		interval = time.Millisecond * 250
+	)
+
+	var (
+		gwc  *gatewayapi.GatewayClass
+		gwcb *gwcapi.GatewayClassBlueprint
+		gw   *gatewayapi.Gateway
+		rt   *gatewayapi.GRPCRoute
+		ctx  context.Context
+	)
+
+	BeforeEach(func() {
+		gwc = &gatewayapi.GatewayClass{}
+		gwcb = &gwcapi.GatewayClassBlueprint{}
+		gw = &gatewayapi.Gateway{}
+		rt = &gatewayapi.GRPCRoute{}
+		ctx = context.Background()
+		Expect(yaml.Unmarshal([]byte(routeTestGatewayClassManifest), gwc)).To(Succeed())
+		Expect(k8sClient.Create(ctx, gwc)).Should(Succeed())
+		Expect(yaml.Unmarshal([]byte(routeTestGatewayClassBlueprintManifest), gwcb)).To(Succeed())
+		Expect(k8sClient.Create(ctx, gwcb)).Should(Succeed())
+		Expect(yaml.Unmarshal([]byte(routeTestGatewayManifest), gw)).To(Succeed())
+		Expect(k8sClient.Create(ctx, gw)).Should(Succeed())
+		Expect(yaml.Unmarshal([]byte(routeTestGRPCRouteManifest), rt)).To(Succeed())
+		Expect(k8sClient.Create(ctx, rt)).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(k8sClient.Delete(ctx, rt)).Should(Succeed())
+		Expect(k8sClient.Delete(ctx, gw)).Should(Succeed())
+		Expect(k8sClient.Delete(ctx, gwc)).Should(Succeed())
+		Expect(k8sClient.Delete(ctx, gwcb)).Should(Succeed())
+	})
+
+	It("Should render GRPCRoute templates and set parent status", func() {
+		By("Creating resources from the grpcRouteTemplate")
+		cm := &corev1.ConfigMap{}
+		Eventually(func() bool {
+			return k8sClient.Get(ctx, types.NamespacedName{Name: "route-test-grpc", Namespace: "default"}, cm) == nil
+		}, timeout, interval).Should(BeTrue())
+		Expect(cm.Data).To(HaveKeyWithValue("gateway", "route-test"))
+
+		By("Setting the Accepted condition for the parent")
+		Eventually(func() bool {
+			if err := k8sClient.Get(ctx, types.NamespacedName{Name: rt.Name, Namespace: rt.Namespace}, rt); err != nil {
+				return false
+			}
+			if len(rt.Status.Parents) != 1 {
+				return false
+			}
+			return meta.IsStatusConditionTrue(rt.Status.Parents[0].Conditions, string(gatewayapi.RouteConditionAccepted))
+		}, timeout, interval).Should(BeTrue())
+
+		By("Setting the Programmed and Ready conditions for the parent from child resources")
+		Eventually(func() bool {
+			if err := k8sClient.Get(ctx, types.NamespacedName{Name: rt.Name, Namespace: rt.Namespace}, rt); err != nil {
+				return false
+			}
+			if len(rt.Status.Parents) != 1 {
+				return false
+			}
+			return meta.IsStatusConditionTrue(rt.Status.Parents[0].Conditions, selfapi.RouteConditionProgrammed) &&
+				meta.IsStatusConditionTrue(rt.Status.Parents[0].Conditions, selfapi.RouteConditionReady)
+		}, timeout, interval).Should(BeTrue())
+	})
+})
@@ -0,0 +1,268 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1a2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+)
+
+// A route kind handled by the controller, e.g. HTTPRoute. Route
+// kinds share the same reconciliation, i.e. routes are rendered using
+// templates from the GatewayClassBlueprint of their parent Gateways.
+type routeType struct {
+	// Kind of route, e.g. 'HTTPRoute'
+	Kind string
+
+	// API group and version of route kind
+	GroupVersion schema.GroupVersion
+
+	// Create empty route and route list objects
+	NewRoute     func() client.Object
+	NewRouteList func() client.ObjectList
+
+	// Return template section for the route kind from a blueprint
+	Template func(spec *gwcapi.GatewayClassBlueprintSpec) *gwcapi.ResourceSpec
+
+	// Set route in template values
+	SetTemplateValue func(values *TemplateValues, route map[string]any)
+
+	// Whether routes of this kind have hostnames, e.g. TLSRoute SNI hostnames
+	HasHostnames bool
+}
+
+var httpRouteType = &routeType{
+	Kind:         "HTTPRoute",
+	GroupVersion: gatewayapi.SchemeGroupVersion,
+	NewRoute:     func() client.Object { return &gatewayapi.HTTPRoute{} },
+	NewRouteList: func() client.ObjectList { return &gatewayapi.HTTPRouteList{} },
+	Template: func(spec *gwcapi.GatewayClassBlueprintSpec) *gwcapi.ResourceSpec {
+		return &spec.HTTPRouteTemplate
+	},
+	SetTemplateValue: func(values *TemplateValues, route map[string]any) { values.HTTPRoute = route },
+	HasHostnames:     true,
+}
+
+var grpcRouteType = &routeType{
+	Kind:         "GRPCRoute",
+	GroupVersion: gatewayapi.SchemeGroupVersion,
+	NewRoute:     func() client.Object { return &gatewayapi.GRPCRoute{} },
+	NewRouteList: func() client.ObjectList { return &gatewayapi.GRPCRouteList{} },
+	Template: func(spec *gwcapi.GatewayClassBlueprintSpec) *gwcapi.ResourceSpec {
+		return &spec.GRPCRouteTemplate
+	},
+	SetTemplateValue: func(values *TemplateValues, route map[string]any) { values.GRPCRoute = route },
+	HasHostnames:     true,
+}
+
+var tlsRouteType = &routeType{
+	Kind:         "TLSRoute",
+	GroupVersion: gatewayv1a2.SchemeGroupVersion,
+	NewRoute:     func() client.Object { return &gatewayv1a2.TLSRoute{} },
+	NewRouteList: func() client.ObjectList { return &gatewayv1a2.TLSRouteList{} },
+	Template: func(spec *gwcapi.GatewayClassBlueprintSpec) *gwcapi.ResourceSpec {
+		return &spec.TLSRouteTemplate
+	},
+	SetTemplateValue: func(values *TemplateValues, route map[string]any) { values.TLSRoute = route },
+	HasHostnames:     true,
+}
+
+var tcpRouteType = &routeType{
+	Kind:         "TCPRoute",
+	GroupVersion: gatewayv1a2.SchemeGroupVersion,
+	NewRoute:     func() client.Object { return &gatewayv1a2.TCPRoute{} },
+	NewRouteList: func() client.ObjectList { return &gatewayv1a2.TCPRouteList{} },
+	Template: func(spec *gwcapi.GatewayClassBlueprintSpec) *gwcapi.ResourceSpec {
+		return &spec.TCPRouteTemplate
+	},
+	SetTemplateValue: func(values *TemplateValues, route map[string]any) { values.TCPRoute = route },
+}
+
+var udpRouteType = &routeType{
+	Kind:         "UDPRoute",
+	GroupVersion: gatewayv1a2.SchemeGroupVersion,
+	NewRoute:     func() client.Object { return &gatewayv1a2.UDPRoute{} },
+	NewRouteList: func() client.ObjectList { return &gatewayv1a2.UDPRouteList{} },
+	Template: func(spec *gwcapi.GatewayClassBlueprintSpec) *gwcapi.ResourceSpec {
+		return &spec.UDPRouteTemplate
+	},
+	SetTemplateValue: func(values *TemplateValues, route map[string]any) { values.UDPRoute = route },
+}
+
+// Route kinds handled by the controller
+var routeTypes = []*routeType{httpRouteType, grpcRouteType, tlsRouteType, tcpRouteType, udpRouteType}
+
+// Route kinds which may attach to listeners of a given protocol
+var protocolRouteKinds = map[gatewayapi.ProtocolType][]string{
+	gatewayapi.HTTPProtocolType:  {"HTTPRoute", "GRPCRoute"},
+	gatewayapi.HTTPSProtocolType: {"HTTPRoute", "GRPCRoute"},
+	gatewayapi.TLSProtocolType:   {"TLSRoute"},
+	gatewayapi.TCPProtocolType:   {"TCPRoute"},
+	gatewayapi.UDPProtocolType:   {"UDPRoute"},
+}
+
+// Return the route kinds served by the API server, e.g. experimental
+// route kinds are only available when their CRDs are installed
+func availableRouteTypes(mapper meta.RESTMapper) []*routeType {
+	available := []*routeType{}
+	for _, rtType := range routeTypes {
+		if isKindAvailable(mapper, rtType.GroupVersionKind()) {
+			available = append(available, rtType)
+		}
+	}
+	return available
+}
+
+// Return the route kind of a route object, nil if not a route
+func routeTypeOf(route client.Object) *routeType {
+	for _, rtType := range routeTypes {
+		if reflect.TypeOf(rtType.NewRoute()) == reflect.TypeOf(route) {
+			return rtType
+		}
+	}
+	return nil
+}
+
+func (rtType *routeType) GroupVersionKind() schema.GroupVersionKind {
+	return rtType.GroupVersion.WithKind(rtType.Kind)
+}
+
+// Return common route spec, e.g. parentRefs, of a route
+func routeCommonSpec(route client.Object) *gatewayapi.CommonRouteSpec {
+	switch rt := route.(type) {
+	case *gatewayapi.HTTPRoute:
+		return &rt.Spec.CommonRouteSpec
+	case *gatewayapi.GRPCRoute:
+		return &rt.Spec.CommonRouteSpec
+	case *gatewayv1a2.TLSRoute:
+		return &rt.Spec.CommonRouteSpec
+	case *gatewayv1a2.TCPRoute:
+		return &rt.Spec.CommonRouteSpec
+	case *gatewayv1a2.UDPRoute:
+		return &rt.Spec.CommonRouteSpec
+	}
+	return nil
+}
+
+// Return hostnames of a route. Nil for route kinds without hostnames
+func routeHostnames(route client.Object) []gatewayapi.Hostname {
+	switch rt := route.(type) {
+	case *gatewayapi.HTTPRoute:
+		return rt.Spec.Hostnames
+	case *gatewayapi.GRPCRoute:
+		return rt.Spec.Hostnames
+	case *gatewayv1a2.TLSRoute:
+		return rt.Spec.Hostnames // SNI hostnames
+	}
+	return nil
+}
+
+// Return backend references of a route by rule index
+func routeBackendRefs(route client.Object) [][]gatewayapi.BackendRef {
+	rules := [][]gatewayapi.BackendRef{}
+	switch rt := route.(type) {
+	case *gatewayapi.HTTPRoute:
+		for _, rule := range rt.Spec.Rules {
+			refs := []gatewayapi.BackendRef{}
+			for _, ref := range rule.BackendRefs {
+				refs = append(refs, ref.BackendRef)
+			}
+			rules = append(rules, refs)
+		}
+	case *gatewayapi.GRPCRoute:
+		for _, rule := range rt.Spec.Rules {
+			refs := []gatewayapi.BackendRef{}
+			for _, ref := range rule.BackendRefs {
+				refs = append(refs, ref.BackendRef)
+			}
+			rules = append(rules, refs)
+		}
+	case *gatewayv1a2.TLSRoute:
+		for _, rule := range rt.Spec.Rules {
+			rules = append(rules, rule.BackendRefs)
+		}
+	case *gatewayv1a2.TCPRoute:
+		for _, rule := range rt.Spec.Rules {
+			rules = append(rules, rule.BackendRefs)
+		}
+	case *gatewayv1a2.UDPRoute:
+		for _, rule := range rt.Spec.Rules {
+			rules = append(rules, rule.BackendRefs)
+		}
+	}
+	return rules
+}
+
+// Return status of a route
+func routeStatus(route client.Object) *gatewayapi.RouteStatus {
+	switch rt := route.(type) {
+	case *gatewayapi.HTTPRoute:
+		return &rt.Status.RouteStatus
+	case *gatewayapi.GRPCRoute:
+		return &rt.Status.RouteStatus
+	case *gatewayv1a2.TLSRoute:
+		return &rt.Status.RouteStatus
+	case *gatewayv1a2.TCPRoute:
+		return &rt.Status.RouteStatus
+	case *gatewayv1a2.UDPRoute:
+		return &rt.Status.RouteStatus
+	}
+	return nil
+}
+
+// Return routes from a route list
+func routeListItems(list client.ObjectList) ([]client.Object, error) {
+	objs, err := meta.ExtractList(list)
+	if err != nil {
+		return nil, err
+	}
+	routes := make([]client.Object, 0, len(objs))
+	for _, obj := range objs {
+		if route, ok := obj.(client.Object); ok {
+			routes = append(routes, route)
+		}
+	}
+	return routes, nil
+}
+
+// Check whether the API server serves a kind, e.g. whether the CRD of an experimental route kind is installed
+func isKindAvailable(mapper meta.RESTMapper, gvk schema.GroupVersionKind) bool {
+	_, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	return err == nil
+}
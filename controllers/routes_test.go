@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+func helperRouteKinds(kinds []gatewayapi.RouteGroupKind) []string {
+	out := []string{}
+	for _, k := range kinds {
+		out = append(out, string(*k.Group)+"/"+string(k.Kind))
+	}
+	return out
+}
+
+func TestSupportedKinds(t *testing.T) {
+	gwcb := &gwcapi.GatewayClassBlueprint{}
+	gwcb.Spec.HTTPRouteTemplate.ResourceTemplates = map[string]string{"foo": "kind: ConfigMap"}
+	gwcb.Spec.TLSRouteTemplate.ResourceTemplates = map[string]string{"foo": "kind: ConfigMap"}
+	gwcb.Spec.TCPRouteTemplate.ResourceTemplates = map[string]string{"foo": "kind: ConfigMap"}
+
+	testCases := []struct {
+		name     string
+		listener gatewayapi.Listener
+		rtTypes  []*routeType
+		expected []string
+	}{
+		{"http-listener", gatewayapi.Listener{Protocol: gatewayapi.HTTPProtocolType}, routeTypes,
+			// No GRPCRoute templates in blueprint
+			[]string{"gateway.networking.k8s.io/HTTPRoute"}},
+		{"tls-listener", gatewayapi.Listener{Protocol: gatewayapi.TLSProtocolType}, routeTypes,
+			[]string{"gateway.networking.k8s.io/TLSRoute"}},
+		{"tls-listener-kind-not-served", gatewayapi.Listener{Protocol: gatewayapi.TLSProtocolType},
+			[]*routeType{httpRouteType, grpcRouteType}, []string{}},
+		{"udp-listener-no-templates", gatewayapi.Listener{Protocol: gatewayapi.UDPProtocolType}, routeTypes,
+			[]string{}},
+		{"tcp-listener-kind-not-allowed", gatewayapi.Listener{
+			Protocol: gatewayapi.TCPProtocolType,
+			AllowedRoutes: &gatewayapi.AllowedRoutes{
+				Kinds: []gatewayapi.RouteGroupKind{{Kind: "UDPRoute"}},
+			}}, routeTypes, []string{}},
+		{"https-listener-kind-allowed", gatewayapi.Listener{
+			Protocol: gatewayapi.HTTPSProtocolType,
+			AllowedRoutes: &gatewayapi.AllowedRoutes{
+				Kinds: []gatewayapi.RouteGroupKind{{Kind: "GRPCRoute"}, {Kind: "HTTPRoute"}},
+			}}, routeTypes, []string{"gateway.networking.k8s.io/HTTPRoute"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			kinds := helperRouteKinds(supportedKinds(&tc.listener, gwcb, tc.rtTypes))
+			if !reflect.DeepEqual(kinds, tc.expected) {
+				t.Errorf("supportedKinds() got %v, expected %v", kinds, tc.expected)
+			}
+		})
+	}
+}
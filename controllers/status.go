@@ -0,0 +1,207 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+	selfapi "github.com/tv2-oss/bifrost-gateway-controller/pkg/api"
+)
+
+// Rendered status is not valid. Other errors from rendering status
+// templates are typically temporary, e.g. child resources without
+// status
+var errInvalidStatus = errors.New("invalid status")
+
+// Gateway conditions set by the controller and which cannot be set from status templates
+var gatewayOwnedConditions = []string{
+	string(gatewayapi.GatewayConditionAccepted),
+	string(gatewayapi.GatewayConditionProgrammed),
+	string(gatewayapi.GatewayConditionReady),
+	selfapi.ConditionStatusRendered,
+}
+
+// Route parent conditions set by the controller and which cannot be set from status templates
+var routeOwnedConditions = []string{
+	string(gatewayapi.RouteConditionAccepted),
+	string(gatewayapi.RouteConditionResolvedRefs),
+	selfapi.ConditionStatusRendered,
+}
+
+// Render a status template and decode the result into 'out'. Unknown fields are not allowed
+func renderStatusTemplate(name, tmplStr string, values *TemplateValues, out any) error {
+	tmpl, err := parseSingleTemplate(name, tmplStr)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", errInvalidStatus, name, err)
+	}
+	buffer, err := templateRender(tmpl, values)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if err := sigsyaml.UnmarshalStrict(buffer.Bytes(), out); err != nil {
+		return fmt.Errorf("%w: %s: %w", errInvalidStatus, name, err)
+	}
+	return nil
+}
+
+// Render Gateway status addresses. Returns nil if no address template is defined
+func renderGatewayAddresses(spec *gwcapi.StatusTemplates, values *TemplateValues) ([]gatewayapi.GatewayStatusAddress, error) {
+	addresses := []gatewayapi.GatewayStatusAddress{}
+	switch {
+	case spec.Addresses != "":
+		if err := renderStatusTemplate("addresses", spec.Addresses, values, &addresses); err != nil {
+			return nil, err
+		}
+	case spec.Template != "":
+		// Deprecated template may hold other fields than addresses
+		tmpl, err := parseSingleTemplate("template", spec.Template)
+		if err != nil {
+			return nil, fmt.Errorf("%w: template: %w", errInvalidStatus, err)
+		}
+		statusMap, err := template2maps(tmpl, values)
+		if err != nil {
+			return nil, fmt.Errorf("template: %w", err)
+		}
+		if len(statusMap) > 0 {
+			status := gatewayapi.GatewayStatus{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(statusMap[0], &status); err != nil {
+				return nil, fmt.Errorf("%w: template: %w", errInvalidStatus, err)
+			}
+			addresses = status.Addresses
+		}
+	default:
+		return nil, nil
+	}
+	for idx, address := range addresses {
+		if err := validateAddress(&address); err != nil {
+			return nil, fmt.Errorf("%w: addresses[%d]: %w", errInvalidStatus, idx, err)
+		}
+	}
+	return addresses, nil
+}
+
+// Validate a Gateway status address, see GatewayStatusAddress
+func validateAddress(address *gatewayapi.GatewayStatusAddress) error {
+	if address.Value == "" {
+		return errors.New("missing value")
+	}
+	if address.Type == nil || *address.Type == gatewayapi.IPAddressType {
+		if net.ParseIP(address.Value) == nil {
+			return fmt.Errorf("invalid IP address %q", address.Value)
+		}
+	}
+	return nil
+}
+
+// Render conditions from a status template. Conditions must be valid
+// and must not be of the types owned by the controller
+func renderConditions(name, tmplStr string, values *TemplateValues, owned []string) ([]metav1.Condition, error) {
+	conditions := []metav1.Condition{}
+	if err := renderStatusTemplate(name, tmplStr, values, &conditions); err != nil {
+		return nil, err
+	}
+	if err := validateConditions(conditions, owned); err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", errInvalidStatus, name, err)
+	}
+	return conditions, nil
+}
+
+// Validate conditions rendered from templates. Conditions must not be of the owned types
+func validateConditions(conditions []metav1.Condition, owned []string) error {
+	for idx, cond := range conditions {
+		for _, o := range owned {
+			if cond.Type == o {
+				return fmt.Errorf("condition type %q cannot be set from template", cond.Type)
+			}
+		}
+		cond.LastTransitionTime = metav1.Now() // Set when conditions are added to status
+		if errs := metav1validation.ValidateCondition(cond, field.NewPath("conditions").Index(idx)); len(errs) > 0 {
+			return errs.ToAggregate()
+		}
+	}
+	return nil
+}
+
+// StatusRendered condition from the error of rendering status templates
+func statusRenderedCondition(err error, generation int64) metav1.Condition {
+	cond := metav1.Condition{
+		Type:               selfapi.ConditionStatusRendered,
+		Status:             metav1.ConditionTrue,
+		Reason:             selfapi.ReasonStatusRendered,
+		ObservedGeneration: generation,
+	}
+	if err != nil {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = selfapi.ReasonStatusRenderFailed
+		if errors.Is(err, errInvalidStatus) {
+			cond.Reason = selfapi.ReasonInvalidStatus
+		}
+		cond.Message = err.Error()
+	}
+	return cond
+}
+
+// Whether status templates are defined for a Gateway or route
+func hasStatusTemplates(spec *gwcapi.ResourceSpec) bool {
+	st := &spec.Status
+	return st.Template != "" || st.Addresses != "" || st.Conditions != "" || st.ParentConditions != "" || spec.ListenerStatus != ""
+}
+
+// Render Gateway status templates and update the Gateway addresses
+// and conditions. Returns the error from rendering status templates
+func updateGatewayStatusFromTemplates(gw *gatewayapi.Gateway, spec *gwcapi.StatusTemplates, values *TemplateValues) error {
+	addresses, errs := renderGatewayAddresses(spec, values)
+	if errs == nil && addresses != nil {
+		gw.Status.Addresses = addresses
+	}
+
+	if spec.Conditions != "" {
+		conditions, err := renderConditions("conditions", spec.Conditions, values, gatewayOwnedConditions)
+		errs = errors.Join(errs, err)
+		for _, cond := range conditions {
+			cond.ObservedGeneration = gw.Generation
+			meta.SetStatusCondition(&gw.Status.Conditions, cond)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+	selfapi "github.com/tv2-oss/bifrost-gateway-controller/pkg/api"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+func helperStatusValues() *TemplateValues {
+	return &TemplateValues{Resources: map[string]any{
+		"loadBalancer": []map[string]any{{"status": map[string]any{"ip": "10.0.0.1", "hostname": "lb.example.com"}}},
+	}}
+}
+
+func TestRenderGatewayAddresses(t *testing.T) {
+	ipType := PtrTo(gatewayapi.IPAddressType)
+	hostnameType := PtrTo(gatewayapi.HostnameAddressType)
+	testCases := []struct {
+		name     string
+		spec     gwcapi.StatusTemplates
+		expected []gatewayapi.GatewayStatusAddress
+		invalid  bool
+	}{
+		{"no-template", gwcapi.StatusTemplates{}, nil, false},
+		{"addresses", gwcapi.StatusTemplates{Addresses: `
+- type: IPAddress
+  value: {{ (index .Resources.loadBalancer 0).status.ip }}
+- type: Hostname
+  value: {{ (index .Resources.loadBalancer 0).status.hostname }}`},
+			[]gatewayapi.GatewayStatusAddress{{Type: ipType, Value: "10.0.0.1"}, {Type: hostnameType, Value: "lb.example.com"}}, false},
+		{"deprecated-template", gwcapi.StatusTemplates{Template: `
+addresses:
+- type: IPAddress
+  value: {{ (index .Resources.loadBalancer 0).status.ip }}`},
+			[]gatewayapi.GatewayStatusAddress{{Type: ipType, Value: "10.0.0.1"}}, false},
+		{"invalid-ip", gwcapi.StatusTemplates{Addresses: `
+- type: IPAddress
+  value: {{ (index .Resources.loadBalancer 0).status.hostname }}`}, nil, true},
+		{"unknown-field", gwcapi.StatusTemplates{Addresses: `
+- type: IPAddress
+  address: 10.0.0.1`}, nil, true},
+		{"not-a-list", gwcapi.StatusTemplates{Addresses: `type: IPAddress`}, nil, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			addresses, err := renderGatewayAddresses(&tc.spec, helperStatusValues())
+			if tc.invalid {
+				if !errors.Is(err, errInvalidStatus) {
+					t.Errorf("expected invalid status error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("renderGatewayAddresses() failed: %v", err)
+			}
+			if !reflect.DeepEqual(addresses, tc.expected) {
+				t.Errorf("renderGatewayAddresses() got %v, expected %v", addresses, tc.expected)
+			}
+		})
+	}
+}
+
+func TestRenderConditions(t *testing.T) {
+	conditions, err := renderConditions("conditions", `
+- type: CertificateIssued
+  status: "True"
+  reason: Issued
+  message: "{{ (index .Resources.loadBalancer 0).status.hostname }}"`, helperStatusValues(), gatewayOwnedConditions)
+	if err != nil {
+		t.Fatalf("renderConditions() failed: %v", err)
+	}
+	if len(conditions) != 1 || conditions[0].Type != "CertificateIssued" || conditions[0].Message != "lb.example.com" {
+		t.Errorf("unexpected conditions %+v", conditions)
+	}
+
+	for _, invalid := range []string{
+		"- type: Programmed\n  status: \"True\"\n  reason: Programmed",
+		"- type: Foo\n  status: \"Maybe\"\n  reason: Foo",
+		"- type: Foo\n  status: \"True\"\n  reason: \"not a reason\"",
+		"- type: Foo\n  status: \"True\"",
+		"- type: Foo\n  status: \"True\"\n  reason: Foo\n  foo: bar",
+	} {
+		if _, err := renderConditions("conditions", invalid, helperStatusValues(), gatewayOwnedConditions); !errors.Is(err, errInvalidStatus) {
+			t.Errorf("expected invalid status error for %q, got %v", invalid, err)
+		}
+	}
+
+	// Missing resources is a temporary error
+	_, err = renderConditions("conditions", `{{ (index .Resources.missing 0).status }}`, helperStatusValues(), gatewayOwnedConditions)
+	if err == nil || errors.Is(err, errInvalidStatus) {
+		t.Errorf("expected render error, got %v", err)
+	}
+	if cond := statusRenderedCondition(err, 1); cond.Reason != selfapi.ReasonStatusRenderFailed {
+		t.Errorf("unexpected condition %+v", cond)
+	}
+}
+
+func TestUpdateGatewayStatusFromTemplates(t *testing.T) {
+	gw := &gatewayapi.Gateway{ObjectMeta: metav1.ObjectMeta{Generation: 3}}
+	spec := &gwcapi.StatusTemplates{
+		Addresses:  "- value: {{ (index .Resources.loadBalancer 0).status.ip }}",
+		Conditions: "- type: LoadBalancerReady\n  status: \"True\"\n  reason: Ready",
+	}
+	if err := updateGatewayStatusFromTemplates(gw, spec, helperStatusValues()); err != nil {
+		t.Fatalf("updateGatewayStatusFromTemplates() failed: %v", err)
+	}
+	if len(gw.Status.Addresses) != 1 || gw.Status.Addresses[0].Value != "10.0.0.1" {
+		t.Errorf("unexpected addresses %v", gw.Status.Addresses)
+	}
+	if cond := meta.FindStatusCondition(gw.Status.Conditions, "LoadBalancerReady"); cond == nil || cond.ObservedGeneration != 3 {
+		t.Errorf("unexpected condition %+v", cond)
+	}
+
+	// Addresses are kept when status cannot be rendered
+	spec.Addresses = "- value: not-an-ip"
+	err := updateGatewayStatusFromTemplates(gw, spec, helperStatusValues())
+	if !errors.Is(err, errInvalidStatus) {
+		t.Errorf("expected invalid status error, got %v", err)
+	}
+	if len(gw.Status.Addresses) != 1 {
+		t.Errorf("unexpected addresses %v", gw.Status.Addresses)
+	}
+	if cond := statusRenderedCondition(err, 3); cond.Status != metav1.ConditionFalse || cond.Reason != selfapi.ReasonInvalidStatus {
+		t.Errorf("unexpected condition %+v", cond)
+	}
+}
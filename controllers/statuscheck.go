@@ -33,15 +33,24 @@ package controllers
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+
+	selfapi "github.com/tv2-oss/bifrost-gateway-controller/pkg/api"
 )
 
 // Given a slice of template states, compute the overall
 // health/readiness status.  The general approach is to test for a
 // `Ready` status condition, which is implemented through kstatus.
+// Templates which could not be rendered are not ready.
 func statusIsReady(templates []*ResourceTemplateState) (bool, error) {
 	for _, tmpl := range templates {
+		if tmpl.RenderError != nil {
+			return false, nil
+		}
 		for _, res := range tmpl.Resources {
 			if res.Current == nil {
 				return false, nil
@@ -58,6 +67,21 @@ func statusIsReady(templates []*ResourceTemplateState) (bool, error) {
 	return true, nil
 }
 
+// Build a list of template names for which not all resources are ready
+func statusNotReadyTemplates(templates []*ResourceTemplateState) ([]string, error) {
+	var notReady []string
+	for _, tmpl := range templates {
+		isReady, err := statusIsReady([]*ResourceTemplateState{tmpl})
+		if err != nil {
+			return nil, err
+		}
+		if !isReady {
+			notReady = append(notReady, tmpl.TemplateName)
+		}
+	}
+	return notReady, nil
+}
+
 // Build a list of template names which are not yet reconciled. Useful for status reporting
 func statusExistingTemplates(templates []*ResourceTemplateState) []string {
 	var missing []string
@@ -75,3 +99,56 @@ func statusExistingTemplates(templates []*ResourceTemplateState) []string {
 	}
 	return missing
 }
+
+// Compute 'Programmed' condition from the result of rendering and
+// applying templates. Programmed relates to templates alone, i.e. all
+// resources have been rendered and applied. The message lists
+// resources not yet applied.
+func programmedCondition(templates []*ResourceTemplateState, result *RenderResult, generation int64) metav1.Condition {
+	cond := metav1.Condition{
+		Type:               selfapi.RouteConditionProgrammed,
+		Status:             metav1.ConditionTrue,
+		Reason:             selfapi.RouteReasonProgrammed,
+		ObservedGeneration: generation,
+	}
+	if result.Exists == len(templates) {
+		return cond
+	}
+	missing := statusExistingTemplates(templates)
+	sort.Strings(missing)
+	cond.Status = metav1.ConditionFalse
+	cond.Reason = selfapi.RouteReasonPending
+	cond.Message = fmt.Sprintf("missing %v resources: %s", len(templates)-result.Exists, strings.Join(missing, ","))
+	if result.BlockingWave != nil {
+		cond.Message = fmt.Sprintf("waiting for wave %d to become ready (%s), %s", *result.BlockingWave,
+			strings.Join(result.BlockingTemplates, ","), cond.Message)
+	}
+	return cond
+}
+
+// Compute route 'Ready' condition from the status of child
+// resources. Ready requires that all resources are programmed and
+// ready. The message lists templates for which not all resources are
+// ready.
+func routeReadyCondition(templates []*ResourceTemplateState, programmed bool, generation int64) (metav1.Condition, error) {
+	cond := metav1.Condition{
+		Type:               selfapi.RouteConditionReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             selfapi.RouteReasonReady,
+		ObservedGeneration: generation,
+	}
+	notReady, err := statusNotReadyTemplates(templates)
+	if err != nil {
+		return cond, err
+	}
+	if len(notReady) > 0 {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = selfapi.RouteReasonPending
+		cond.Message = fmt.Sprintf("resources not ready: %s", strings.Join(notReady, ","))
+	} else if !programmed {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = selfapi.RouteReasonPending
+		cond.Message = "resources not programmed"
+	}
+	return cond, nil
+}
@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	selfapi "github.com/tv2-oss/bifrost-gateway-controller/pkg/api"
+)
+
+func helperTemplateState(name string, current ...*unstructured.Unstructured) *ResourceTemplateState {
+	tmpl := &ResourceTemplateState{TemplateName: name, Resources: []ResourceComposite{}}
+	for _, cur := range current {
+		tmpl.Resources = append(tmpl.Resources, ResourceComposite{Current: cur})
+	}
+	return tmpl
+}
+
+func helperConfigMap() *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]any{"name": "cm", "namespace": "default"},
+	}}
+}
+
+func helperNotReadyDeployment() *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]any{"name": "deploy", "namespace": "default", "generation": int64(2)},
+		"spec":       map[string]any{"replicas": int64(1)},
+		"status":     map[string]any{"observedGeneration": int64(1)},
+	}}
+}
+
+func TestProgrammedCondition(t *testing.T) {
+	templates := []*ResourceTemplateState{
+		helperTemplateState("configMap", helperConfigMap()),
+		helperTemplateState("renderFailed"),
+		helperTemplateState("notApplied", helperConfigMap(), nil),
+	}
+	cond := programmedCondition(templates, &RenderResult{Rendered: 2, Exists: 1}, 3)
+	if cond.Status != metav1.ConditionFalse || cond.Reason != selfapi.RouteReasonPending || cond.ObservedGeneration != 3 {
+		t.Errorf("unexpected condition %+v", cond)
+	}
+	if cond.Message != "missing 2 resources: notApplied[1],renderFailed[]" {
+		t.Errorf("unexpected message %q", cond.Message)
+	}
+
+	cond = programmedCondition(templates, &RenderResult{Rendered: 1, Exists: 1, BlockingWave: PtrTo(int32(0)),
+		BlockingTemplates: []string{"configMap"}}, 3)
+	if !strings.HasPrefix(cond.Message, "waiting for wave 0 to become ready (configMap), missing 2 resources") {
+		t.Errorf("unexpected message %q", cond.Message)
+	}
+
+	cond = programmedCondition(templates[:1], &RenderResult{Rendered: 1, Exists: 1}, 3)
+	if cond.Status != metav1.ConditionTrue || cond.Reason != selfapi.RouteReasonProgrammed {
+		t.Errorf("unexpected condition %+v", cond)
+	}
+}
+
+func TestRouteReadyCondition(t *testing.T) {
+	testCases := []struct {
+		name       string
+		templates  []*ResourceTemplateState
+		programmed bool
+		status     metav1.ConditionStatus
+		message    string
+	}{
+		{"ready", []*ResourceTemplateState{helperTemplateState("configMap", helperConfigMap())}, true,
+			metav1.ConditionTrue, ""},
+		{"not-programmed", []*ResourceTemplateState{helperTemplateState("configMap", helperConfigMap())}, false,
+			metav1.ConditionFalse, "resources not programmed"},
+		{"not-ready", []*ResourceTemplateState{helperTemplateState("configMap", helperConfigMap()),
+			helperTemplateState("deployment", helperNotReadyDeployment())}, true,
+			metav1.ConditionFalse, "resources not ready: deployment"},
+		{"not-applied", []*ResourceTemplateState{helperTemplateState("configMap", nil)}, false,
+			metav1.ConditionFalse, "resources not ready: configMap"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cond, err := routeReadyCondition(tc.templates, tc.programmed, 1)
+			if err != nil {
+				t.Fatalf("routeReadyCondition() failed: %v", err)
+			}
+			if cond.Status != tc.status || cond.Message != tc.message {
+				t.Errorf("routeReadyCondition() got %+v, expected status %s, message %q", cond, tc.status, tc.message)
+			}
+		})
+	}
+}
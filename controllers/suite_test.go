@@ -52,6 +52,8 @@ import (
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	gateway "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1a2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	gatewayv1b1 "sigs.k8s.io/gateway-api/apis/v1beta1"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -92,6 +94,12 @@ var _ = BeforeSuite(func() {
 	err = gateway.Install(scheme.Scheme)
 	Expect(err).NotTo(HaveOccurred())
 
+	err = gatewayv1a2.Install(scheme.Scheme)
+	Expect(err).NotTo(HaveOccurred())
+
+	err = gatewayv1b1.Install(scheme.Scheme)
+	Expect(err).NotTo(HaveOccurred())
+
 	err = gcapi.AddToScheme(scheme.Scheme)
 	Expect(err).NotTo(HaveOccurred())
 
@@ -123,6 +131,22 @@ var _ = BeforeSuite(func() {
 	err = httprtctrl.SetupWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
+	grpcrtctrl := NewGRPCRouteController(k8sManager, cfg)
+	err = grpcrtctrl.SetupWithManager(k8sManager)
+	Expect(err).ToNot(HaveOccurred())
+
+	gwcbctrl := NewGatewayClassBlueprintController(k8sManager)
+	err = gwcbctrl.SetupWithManager(k8sManager)
+	Expect(err).ToNot(HaveOccurred())
+
+	gwccctrl := NewGatewayClassConfigController(k8sManager)
+	err = gwccctrl.SetupWithManager(k8sManager)
+	Expect(err).ToNot(HaveOccurred())
+
+	gwconfctrl := NewGatewayConfigController(k8sManager)
+	err = gwconfctrl.SetupWithManager(k8sManager)
+	Expect(err).ToNot(HaveOccurred())
+
 	// Namespace of the gateway controller
 	ns := corev1.Namespace{}
 	ns.Name = "bifrost-gateway-controller-system"
@@ -36,22 +36,26 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
 	"sort"
 	"strings"
 	"text/template"
 
 	"github.com/Masterminds/sprig/v3"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/yaml"
-	"k8s.io/client-go/dynamic"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	sigsyaml "sigs.k8s.io/yaml"
 )
 
+// Destination of debug output from rendering templates, e.g. rendered
+// templates and template values
+var RenderDebugOutput io.Writer = os.Stdout
+
 // Information about a resource, rendered format as well as actual in API server
 type ResourceComposite struct {
 	// The rendered resource
@@ -79,8 +83,19 @@ type ResourceTemplateState struct {
 	// Raw template
 	StringTemplate string
 
+	// Names of templates this template depends on
+	Dependencies []string
+
+	// Apply wave, i.e. the wave from template options or the
+	// latest wave of the templates this template depends on
+	Wave int32
+
 	// Resource information, rendered and current
 	Resources []ResourceComposite
+
+	// Error from rendering the template. Templates which cannot be
+	// rendered have no resources and are not ready
+	RenderError error
 }
 
 // Parameters used when rendering templates
@@ -91,6 +106,31 @@ type TemplateValues struct {
 	// Parent HTTPRoute. Only set when rendering HTTPRoute templates
 	HTTPRoute map[string]any
 
+	// Parent GRPCRoute. Only set when rendering GRPCRoute templates
+	GRPCRoute map[string]any
+
+	// Parent TLSRoute. Only set when rendering TLSRoute templates
+	TLSRoute map[string]any
+
+	// Parent TCPRoute. Only set when rendering TCPRoute templates
+	TCPRoute map[string]any
+
+	// Parent UDPRoute. Only set when rendering UDPRoute templates
+	UDPRoute map[string]any
+
+	// Gateway listener. Only set when rendering listener status templates
+	Listener map[string]any
+
+	// Backend references of the parent route permitted by
+	// ReferenceGrants, as a list per route rule. Only set when
+	// rendering route templates
+	ResolvedBackends [][]map[string]any
+
+	// TLS certificate references of Gateway listeners permitted by
+	// ReferenceGrants, as a list per listener name. Only set when
+	// rendering Gateway templates
+	ResolvedCertificateRefs map[string][]map[string]any
+
 	// Template values
 	Values map[string]any
 
@@ -98,14 +138,22 @@ type TemplateValues struct {
 	Resources map[string]any
 
 	// List of all hostnames across all listeners and attached
-	// HTTPRoutes. These lists of hostnames are particularly
+	// routes. These lists of hostnames are particularly
 	// useful for TLS certificates which are not port specific.
 	Hostnames TemplateHostnameValues
+
+	// Values from Secrets, redacted from debug output
+	sensitiveValues []string
+}
+
+// Format template values for debug output with values from Secrets redacted
+func (v *TemplateValues) debugString() string {
+	return redactText(fmt.Sprintf("%+v", v), v.sensitiveValues)
 }
 
 type TemplateHostnameValues struct {
 	// Union and intersection of all hostnames across all
-	// listeners and attached HTTPRoutes (with duplicates
+	// listeners and attached routes (with duplicates
 	// removed). Intersection holds all hostnames from Union with
 	// duplicates covered by wildcards removed.
 	Union, Intersection []string
@@ -119,8 +167,19 @@ func parseSingleTemplate(tmplKey, tmpl string) (*template.Template, error) {
 	return template.New(tmplKey).Option("missingkey=error").Funcs(sprig.TxtFuncMap()).Funcs(funcs).Parse(tmpl)
 }
 
-// Initialize ResourceTemplateState slice by parsing templates
+// Initialize ResourceTemplateState slice by parsing templates for
+// rendering. Parse errors are counted in metrics
 func parseTemplates(resourceTemplates map[string]string) ([]*ResourceTemplateState, error) {
+	templates, err := parseResourceTemplates(resourceTemplates)
+	if err != nil {
+		metricTemplateParseErrs.Inc()
+	}
+	return templates, err
+}
+
+// Parse templates like parseTemplates without counting parse errors,
+// e.g. for validation of blueprints
+func parseResourceTemplates(resourceTemplates map[string]string) ([]*ResourceTemplateState, error) {
 	var err error
 
 	templates := make([]*ResourceTemplateState, 0, len(resourceTemplates))
@@ -131,7 +190,6 @@ func parseTemplates(resourceTemplates map[string]string) ([]*ResourceTemplateSta
 		r.StringTemplate = tmpl
 		r.Template, err = parseSingleTemplate(tmplKey, tmpl)
 		if err != nil {
-			metricTemplateParseErrs.Inc()
 			return nil, fmt.Errorf("cannot parse template %q: %w", tmplKey, err)
 		}
 		r.Resources = make([]ResourceComposite, 0)
@@ -144,59 +202,6 @@ func parseTemplates(resourceTemplates map[string]string) ([]*ResourceTemplateSta
 	return templates, nil
 }
 
-// Attempt to render templates and get current resource, skipping
-// resources that have already been rendered/fetched. Note that
-// fetching current resource from API server/cache require that we can
-// render the template first. Rendering errors on final attempt are
-// logged as errors.
-func renderTemplates(ctx context.Context, r ControllerDynClient, parent metav1.Object,
-	templates []*ResourceTemplateState, values *TemplateValues, isFinalAttempt bool) (rendered, exists int) {
-	var err error
-
-	logger := log.FromContext(ctx)
-	ns := parent.GetNamespace()
-
-	for tIdx := range templates {
-		tmpl := templates[tIdx]
-		if len(tmpl.Resources) == 0 {
-			tmpl.Resources, err = template2Composite(r, tmpl.Template, values)
-			if err != nil {
-				if isFinalAttempt {
-					logger.Error(err, "cannot render template", "templateName", tmpl.TemplateName)
-					// FIXME: These are convenient, but we should have a better logging design, i.e. it should be possible to enable rendering errors only
-					fmt.Printf("Template:\n%s\n", tmpl.StringTemplate)
-					fmt.Printf("Template values:\n%+v\n", values)
-					metricTemplateErrs.Inc()
-				}
-				continue
-			}
-		}
-		rendered++
-		for resIdx := range tmpl.Resources {
-			res := &tmpl.Resources[resIdx]
-			if res.Current == nil {
-				var dynamicClient dynamic.ResourceInterface
-				if res.IsNamespaced {
-					dynamicClient = r.DynamicClient().Resource(*res.GVR).Namespace(ns)
-				} else {
-					dynamicClient = r.DynamicClient().Resource(*res.GVR)
-				}
-				metricResourceGet.Inc()
-				res.Current, err = dynamicClient.Get(ctx, res.Rendered.GetName(), metav1.GetOptions{})
-				if err != nil {
-					logger.Error(err, "cannot get current resource", "templateName", tmpl.TemplateName, "resIdx", resIdx)
-					continue
-				}
-				logger.Info("update current", "templatename", tmpl.TemplateName, "idx", resIdx, "current", res.Current)
-			} else {
-				logger.Info("already have update current", "templatename", tmpl.TemplateName, "idx", resIdx, "current", res.Current)
-			}
-		}
-		exists++
-	}
-	return rendered, exists
-}
-
 // Build a map of values from current resources. Useful for
 // referencing values between resources, e.g. a status field from one
 // resource may be used to template another resource
@@ -215,48 +220,153 @@ func buildResourceValues(templates []*ResourceTemplateState) map[string]any {
 	return resourceValues
 }
 
-// Apply a list of pre-rendered templates and set owner reference for
-// namespaced resources
-func applyTemplates(ctx context.Context, r ControllerDynClient, parent metav1.Object, templates []*ResourceTemplateState) error {
-	var err error
+// Result of rendering and applying templates
+type RenderResult struct {
+	// Number of templates rendered
+	Rendered int
+
+	// Number of templates for which all resources exist
+	Exists int
+
+	// Apply wave which is not yet ready, i.e. later waves are held
+	// back. Nil if no waves are held back
+	BlockingWave *int32
+
+	// Templates from BlockingWave or earlier waves which are not ready
+	BlockingTemplates []string
+
+	// Errors from rendering templates. Templates which cannot be
+	// rendered are skipped
+	TemplateErrors []error
+}
+
+// Render and apply templates. Templates must be sorted in apply wave
+// and dependency order using sortTemplates, i.e. each template is
+// rendered exactly once with the current resources of the templates
+// it depends on available as '.Resources'. The resources resulting
+// from applying a template are used as current resources. Templates
+// which cannot be rendered, e.g. due to a missing dependency, are
+// skipped. Templates from a wave are only rendered when all resources
+// from previous waves are ready.
+func renderAndApplyTemplates(ctx context.Context, r ControllerDynClient, parent client.Object,
+	templates []*ResourceTemplateState, values *TemplateValues) (*RenderResult, error) {
+	kind, err := parentKind(r, parent)
+	if err != nil {
+		return &RenderResult{}, fmt.Errorf("cannot lookup kind of parent: %w", err)
+	}
+	return renderTemplates(ctx, r, templates, values, func(tmpl *ResourceTemplateState) int {
+		return applyTemplate(ctx, r, parent, kind, tmpl)
+	})
+}
+
+// Render templates in order as described for renderAndApplyTemplates.
+// The apply function is called for each rendered template and must
+// set current resources. It returns the number of resources which
+// could not be applied.
+func renderTemplates(ctx context.Context, r ControllerClient, templates []*ResourceTemplateState, values *TemplateValues,
+	apply func(tmpl *ResourceTemplateState) int) (*RenderResult, error) {
 	var errorCnt = 0
+	var err error
+	result := &RenderResult{}
 
 	logger := log.FromContext(ctx)
 
-	for _, tmpl := range templates {
-		for _, res := range tmpl.Resources {
-			if res.Rendered == nil || res.GVR == nil {
-				// We do not yet have enough information to render/apply this resource
-				continue
+	for tIdx, tmpl := range templates {
+		if tIdx > 0 && tmpl.Wave > templates[tIdx-1].Wave {
+			notReady, err := statusNotReadyTemplates(templates[:tIdx])
+			if err != nil {
+				return result, err
 			}
-			if res.IsNamespaced {
-				// Only namespaced objects can have namespaced object as owner
-				err = ctrl.SetControllerReference(parent, res.Rendered, r.Scheme())
-				if err != nil {
-					logger.Error(err, "cannot set owner for namespaced template", "templateName", tmpl.TemplateName)
-					errorCnt++
-				} else {
-					ns := parent.GetNamespace()
-					err = patchUnstructured(ctx, r, res.Rendered, res.GVR, &ns)
-					if err != nil {
-						logger.Error(err, "cannot apply namespaced template", "templateName", tmpl.TemplateName)
-						errorCnt++
-					}
-				}
-			} else {
-				err = patchUnstructured(ctx, r, res.Rendered, res.GVR, nil)
-				if err != nil {
-					logger.Error(err, "cannot apply cluster-scoped template", "templateName", tmpl.TemplateName)
-					errorCnt++
-				}
+			if len(notReady) > 0 {
+				result.BlockingWave = PtrTo(templates[tIdx-1].Wave)
+				result.BlockingTemplates = notReady
+				logger.Info("holding back apply waves", "blockingWave", *result.BlockingWave, "notReady", notReady)
+				break
 			}
 		}
+
+		values.Resources = buildResourceValues(templates)
+		tmpl.Resources, err = template2Composite(r, tmpl.Template, values)
+		tmpl.RenderError = err
+		if err != nil {
+			logger.Error(err, "cannot render template", "templateName", tmpl.TemplateName, "dependencies", tmpl.Dependencies)
+			// FIXME: These are convenient, but we should have a better logging design, i.e. it should be possible to enable rendering errors only
+			fmt.Fprintf(RenderDebugOutput, "Template:\n%s\n", tmpl.StringTemplate)
+			fmt.Fprintf(RenderDebugOutput, "Template values:\n%s\n", values.debugString())
+			metricTemplateErrs.Inc()
+			result.TemplateErrors = append(result.TemplateErrors, fmt.Errorf("cannot render template %q: %w", tmpl.TemplateName, err))
+			tmpl.Resources = []ResourceComposite{}
+			continue
+		}
+		result.Rendered++
+
+		tmplErrs := apply(tmpl)
+		if tmplErrs == 0 {
+			result.Exists++
+		}
+		errorCnt += tmplErrs
 	}
+	values.Resources = buildResourceValues(templates)
 
 	if errorCnt > 0 {
-		return fmt.Errorf("found %v problems while applying %v templates", errorCnt, len(templates))
+		return result, fmt.Errorf("found %v problems while applying %v templates", errorCnt, len(templates))
+	}
+	return result, nil
+}
+
+// Apply resources of a rendered template and set owner reference for
+// namespaced resources. All resources are labelled and annotated with
+// the identity of the parent. Returns the number of resources which
+// could not be applied.
+func applyTemplate(ctx context.Context, r ControllerDynClient, parent client.Object, kind string, tmpl *ResourceTemplateState) int {
+	var errorCnt = 0
+
+	logger := log.FromContext(ctx)
+
+	for resIdx := range tmpl.Resources {
+		res := &tmpl.Resources[resIdx]
+		setParentMetadata(res.Rendered, parent, kind)
+		var ns *string
+		if res.IsNamespaced {
+			// Only namespaced objects can have namespaced object as owner
+			if err := ctrl.SetControllerReference(parent, res.Rendered, r.Scheme()); err != nil {
+				logger.Error(err, "cannot set owner for namespaced template", "templateName", tmpl.TemplateName)
+				errorCnt++
+				continue
+			}
+			ns = PtrTo(parent.GetNamespace())
+		}
+		var live *unstructured.Unstructured
+		if DryRun {
+			var err error
+			if live, err = lookupChild(ctx, r, res.GVR, ns, res.Rendered.GetName()); err != nil {
+				logger.Error(err, "cannot lookup child resource", "templateName", tmpl.TemplateName, "resIdx", resIdx)
+				errorCnt++
+				continue
+			}
+		}
+		current, err := patchUnstructured(ctx, r, res.Rendered, res.GVR, ns, DryRun)
+		if err != nil {
+			logger.Error(err, "cannot apply template", "templateName", tmpl.TemplateName, "resIdx", resIdx)
+			errorCnt++
+			continue
+		}
+		if DryRun {
+			recordDryRunApply(ctx, parent, tmpl.TemplateName, live, current)
+		}
+		res.Current = current
+		if w, ok := r.(childWatcher); ok {
+			// Reconcile parent when child resources change, e.g. when status is updated
+			if err = w.watchChildKind(current.GroupVersionKind()); err != nil {
+				logger.Error(err, "cannot watch child resource kind", "gvk", current.GroupVersionKind())
+			}
+		}
+		if err = unpruneResource(ctx, r, res, ns); err != nil {
+			logger.Error(err, "cannot remove pruned annotation", "templateName", tmpl.TemplateName)
+			errorCnt++
+		}
 	}
-	return nil
+	return errorCnt
 }
 
 // This function is made available to templates as 'toYaml'
@@ -276,8 +386,8 @@ func templateRender(tmpl *template.Template, templateValues *TemplateValues) (*b
 	}
 
 	// FIXME: These are convenient, but we should have a better logging design, i.e. it should be possible to enable rendering info only
-	fmt.Printf("Rendered:\n%s\n", buffer.Bytes())
-	fmt.Printf("Values:\n%+v\n", templateValues)
+	fmt.Fprintf(RenderDebugOutput, "Rendered:\n%s\n", redactText(buffer.String(), templateValues.sensitiveValues))
+	fmt.Fprintf(RenderDebugOutput, "Values:\n%s\n", templateValues.debugString())
 
 	return &buffer, nil
 }
@@ -287,13 +397,16 @@ func template2maps(tmpl *template.Template, tmplValues *TemplateValues) ([]map[s
 	if err != nil {
 		return nil, err
 	}
+	return splitDocuments(renderBuffer.Bytes())
+}
 
-	rawSlice := bytes.SplitN(renderBuffer.Bytes(), []byte("---"), -1)
+// Split rendered YAML documents into resources. Empty documents are skipped
+func splitDocuments(rendered []byte) ([]map[string]any, error) {
+	rawSlice := bytes.SplitN(rendered, []byte("---"), -1)
 	resources := make([]map[string]any, 0, len(rawSlice))
 	for _, raw := range rawSlice {
 		r := map[string]any{}
-		err = yaml.Unmarshal(raw, &r)
-		if err != nil {
+		if err := yaml.Unmarshal(raw, &r); err != nil {
 			return nil, err
 		}
 		if len(r) == 0 {
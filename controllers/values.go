@@ -0,0 +1,199 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/util/json"
+	"k8s.io/kube-openapi/pkg/validation/errors"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	"k8s.io/kube-openapi/pkg/validation/strfmt"
+	"k8s.io/kube-openapi/pkg/validation/validate"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+)
+
+// Reference to the blueprint or policy supplying values
+func objectRefOf(obj client.Object) objectRef {
+	ref := objectRef{Group: gwcapi.GroupVersion.Group, Namespace: obj.GetNamespace(), Name: obj.GetName()}
+	switch obj.(type) {
+	case *gwcapi.GatewayClassBlueprint:
+		ref.Kind = "GatewayClassBlueprint"
+	case *gwcapi.GatewayClassConfig:
+		ref.Kind = "GatewayClassConfig"
+	case *gwcapi.GatewayConfig:
+		ref.Kind = "GatewayConfig"
+	}
+	return ref
+}
+
+func (ref objectRef) String() string {
+	if ref.Namespace == "" {
+		return fmt.Sprintf("%s %s", ref.Kind, ref.Name)
+	}
+	return fmt.Sprintf("%s %s/%s", ref.Kind, ref.Namespace, ref.Name)
+}
+
+// A value violating the values schema of a blueprint
+type valuesViolation struct {
+	// JSON path of the value, e.g. '.subnets[1]'
+	Path string
+
+	// Description of the violation
+	Message string
+
+	// Blueprint and policies supplying the value. Empty for
+	// missing values
+	Sources []objectRef
+}
+
+func (v *valuesViolation) String() string {
+	if len(v.Sources) == 0 {
+		return fmt.Sprintf("%s: %s", v.Path, v.Message)
+	}
+	srcs := []string{}
+	for _, src := range v.Sources {
+		srcs = append(srcs, src.String())
+	}
+	return fmt.Sprintf("%s: %s (from %s)", v.Path, v.Message, strings.Join(srcs, ", "))
+}
+
+// Parse the values schema of a blueprint. Returns nil if no schema is defined
+func parseValuesSchema(src *apiextensionsv1.JSON) (*spec.Schema, error) {
+	if src == nil || len(src.Raw) == 0 {
+		return nil, nil
+	}
+	schema := &spec.Schema{}
+	if err := json.Unmarshal(src.Raw, schema); err != nil {
+		return nil, fmt.Errorf("invalid values schema: %w", err)
+	}
+	return schema, nil
+}
+
+// Array indices of validation paths, e.g. '[1]' of 'subnets[1]'
+var arrayIndexRegexp = regexp.MustCompile(`\[[0-9]+\]`)
+
+// Validate values against the values schema of a blueprint. Returns
+// violations ordered by path, with the blueprint and policies which
+// supplied the violating values.
+func validateValuesSchema(schemaSrc *apiextensionsv1.JSON, values map[string]any, sources valueSources) ([]valuesViolation, error) {
+	schema, err := parseValuesSchema(schemaSrc)
+	if err != nil || schema == nil {
+		return nil, err
+	}
+	result := validate.NewSchemaValidator(schema, nil, "", strfmt.Default).Validate(values)
+	violations := []valuesViolation{}
+	for _, resErr := range result.Errors {
+		verr, ok := resErr.(*errors.Validation)
+		if !ok {
+			violations = append(violations, valuesViolation{Path: ".", Message: resErr.Error()})
+			continue
+		}
+		path := strings.TrimPrefix(verr.Name, ".")
+		if verr.Code() == errors.UnallowedPropertyCode {
+			// Name is the object holding the property
+			path = strings.TrimPrefix(fmt.Sprintf("%s.%v", path, verr.Value), ".")
+		}
+		msg := strings.TrimSpace(strings.TrimPrefix(verr.Error(), verr.Name+" in body"))
+		if verr.Code() == errors.UnallowedPropertyCode {
+			msg = "is a forbidden property"
+		}
+		violations = append(violations, valuesViolation{
+			Path:    "." + path,
+			Message: msg,
+			Sources: sources.lookup(arrayIndexRegexp.ReplaceAllString(path, "")),
+		})
+	}
+	sort.SliceStable(violations, func(i, j int) bool { return violations[i].Path < violations[j].Path })
+	return violations, nil
+}
+
+// Return the sources of a value at a dotted path. A value may be an
+// object with values supplied by several sources, or part of a list
+// or value supplied as a whole by one source.
+func (sources valueSources) lookup(path string) []objectRef {
+	dotted := make(map[string]valueSource, len(sources))
+	for key, src := range sources {
+		dotted[dottedValuePath(key)] = src
+	}
+	for prefix := path; prefix != ""; {
+		if src, found := dotted[prefix]; found {
+			return []objectRef{src.objectRef}
+		}
+		idx := strings.LastIndex(prefix, ".")
+		if idx < 0 {
+			break
+		}
+		prefix = prefix[:idx]
+	}
+	found := map[objectRef]bool{}
+	refs := []objectRef{}
+	for valPath, src := range dotted {
+		if (path == "" || strings.HasPrefix(valPath, path+".")) && !found[src.objectRef] {
+			found[src.objectRef] = true
+			refs = append(refs, src.objectRef)
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].String() < refs[j].String() })
+	return refs
+}
+
+// Format violations of the values schema as an error
+func violationsError(violations []valuesViolation) error {
+	if len(violations) == 0 {
+		return nil
+	}
+	msgs := []string{}
+	for idx := range violations {
+		msgs = append(msgs, violations[idx].String())
+	}
+	return fmt.Errorf("invalid values: %s", truncateMessage(strings.Join(msgs, "; ")))
+}
+
+// Maximum length of condition messages, see metav1.Condition
+const maxConditionMessageLength = 32768
+
+// Truncate a message to fit in a condition, e.g. when listing many violations
+func truncateMessage(msg string) string {
+	const suffix = "..."
+	const maxLength = maxConditionMessageLength - 512 // Room for prefixes
+	if len(msg) <= maxLength {
+		return msg
+	}
+	return msg[:maxLength-len(suffix)] + suffix
+}
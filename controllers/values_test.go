@@ -0,0 +1,264 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+)
+
+const valuesTestSchema = `{
+  "type": "object",
+  "required": ["vpcId", "subnets"],
+  "properties": {
+    "vpcId": {"type": "string", "pattern": "^vpc-"},
+    "subnets": {"type": "array", "minItems": 1, "items": {"type": "string"}},
+    "healthCheck": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {"port": {"type": "integer"}}
+    }
+  }
+}`
+
+func helperValuesBlueprint(defaults string) *gwcapi.GatewayClassBlueprint {
+	gwcb := &gwcapi.GatewayClassBlueprint{}
+	gwcb.Name = "blueprint"
+	gwcb.Spec.Values.Default = &apiextensionsv1.JSON{Raw: []byte(defaults)}
+	gwcb.Spec.ValuesSchema = &apiextensionsv1.JSON{Raw: []byte(valuesTestSchema)}
+	return gwcb
+}
+
+func TestLookupValuesWithSources(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := gwcapi.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	pol := helperGatewayConfig("gw-config", time.Hour, `{"vpcId": "vpc-1", "healthCheck": {"port": 80}}`)
+	r := &fakeReconciler{
+		client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(pol).Build(),
+		scheme: scheme,
+	}
+	gwcb := helperValuesBlueprint(`{"vpcId": "vpc-0", "healthCheck": {"port": 8080, "path": "/"}}`)
+
+	values, sources, err := lookupValuesWithSources(context.Background(), r, "gwc", gwcb, "default", "gw", nil)
+	if err != nil {
+		t.Fatalf("lookupValuesWithSources() failed: %v", err)
+	}
+	if values["vpcId"] != "vpc-1" {
+		t.Errorf("unexpected values %v", values)
+	}
+	if err = r.client.Get(context.Background(), client.ObjectKeyFromObject(pol), pol); err != nil {
+		t.Fatal(err)
+	}
+	blueprintSrc := valueSource{objectRef: objectRef{Group: "gateway.tv2.dk", Kind: "GatewayClassBlueprint", Name: "blueprint"}}
+	policySrc := valueSource{objectRef: objectRef{Group: "gateway.tv2.dk", Kind: "GatewayConfig", Namespace: "default", Name: "gw-config"},
+		ResourceVersion: pol.ResourceVersion, Override: true}
+	expected := valueSources{
+		"vpcId": policySrc,
+		valueSourceKey([]string{"healthCheck", "port"}): policySrc,
+		valueSourceKey([]string{"healthCheck", "path"}): blueprintSrc,
+	}
+	if !reflect.DeepEqual(sources, expected) {
+		t.Errorf("lookupValuesWithSources() got sources %v, expected %v", sources, expected)
+	}
+}
+
+func TestLookupValuesRouteScope(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := gwcapi.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	withDefault := func(pol *gwcapi.GatewayConfig, defaults string) *gwcapi.GatewayConfig {
+		pol.Spec.Default = &apiextensionsv1.JSON{Raw: []byte(defaults)}
+		return pol
+	}
+	gwPol := withDefault(helperGatewayConfig("gw-config", time.Hour, `{"waf": "gateway"}`), `{"timeout": 1}`)
+	listenerPol := withDefault(helperGatewayConfig("listener-config", time.Hour, ""), `{"timeout": 2}`)
+	listenerPol.Spec.TargetRef.SectionName = PtrTo(gatewayapi.SectionName("http"))
+	otherListenerPol := withDefault(helperGatewayConfig("other-listener-config", time.Hour, ""), `{"other": true}`)
+	otherListenerPol.Spec.TargetRef.SectionName = PtrTo(gatewayapi.SectionName("https"))
+	routePol := withDefault(helperGatewayConfig("route-config", time.Hour, ""), `{"timeout": 3, "waf": "route"}`)
+	routePol.Namespace = "apps"
+	routePol.Spec.TargetRef.Kind = "HTTPRoute"
+	routePol.Spec.TargetRef.Name = "rt"
+	r := &fakeReconciler{
+		client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(gwPol, listenerPol, otherListenerPol, routePol).Build(),
+		scheme: scheme,
+	}
+	gwcb := helperValuesBlueprint(`{"timeout": 0}`)
+
+	// Gateway values do not include listener or route values
+	values, _, err := lookupValuesWithSources(context.Background(), r, "gwc", gwcb, "default", "gw", nil)
+	if err != nil {
+		t.Fatalf("lookupValuesWithSources() failed: %v", err)
+	}
+	if !reflect.DeepEqual(values, map[string]any{"timeout": int64(1), "waf": "gateway"}) {
+		t.Errorf("unexpected gateway values %v", values)
+	}
+
+	// Route defaults have precedence over listener and Gateway defaults, but not Gateway overrides
+	rt := helperHTTPRoute("rt", "apps", gatewayapi.ParentReference{Name: "gw"})
+	values, sources, err := lookupValuesWithSources(context.Background(), r, "gwc", gwcb, "default", "gw",
+		&routeValuesScope{route: rt, listeners: []gatewayapi.SectionName{"http"}})
+	if err != nil {
+		t.Fatalf("lookupValuesWithSources() failed: %v", err)
+	}
+	if !reflect.DeepEqual(values, map[string]any{"timeout": int64(3), "waf": "gateway"}) {
+		t.Errorf("unexpected route values %v", values)
+	}
+	if sources["timeout"].Name != "route-config" {
+		t.Errorf("unexpected sources %v", sources)
+	}
+
+	// Listener defaults only for routes attached to the listener
+	values, _, err = lookupValuesWithSources(context.Background(), r, "gwc", gwcb, "default", "gw",
+		&routeValuesScope{route: helperHTTPRoute("other", "apps", gatewayapi.ParentReference{Name: "gw"}), listeners: []gatewayapi.SectionName{"http"}})
+	if err != nil {
+		t.Fatalf("lookupValuesWithSources() failed: %v", err)
+	}
+	if !reflect.DeepEqual(values, map[string]any{"timeout": int64(2), "waf": "gateway"}) {
+		t.Errorf("unexpected route values %v", values)
+	}
+}
+
+func TestLookupValuesTargetSelector(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = gatewayapi.Install(scheme)
+	_ = gwcapi.AddToScheme(scheme)
+	defer func(ns string) { ControllerNamespace = ns }(ControllerNamespace)
+	ControllerNamespace = "controller-ns"
+
+	selector := func(kind gatewayapi.Kind, group gatewayapi.Group, key, value string) *gwcapi.PolicyTargetSelector {
+		return &gwcapi.PolicyTargetSelector{Group: group, Kind: kind,
+			Selector: metav1.LabelSelector{MatchLabels: map[string]string{key: value}}}
+	}
+	withDefault := func(pol *gwcapi.GatewayConfig, defaults string) *gwcapi.GatewayConfig {
+		pol.Spec.Default = &apiextensionsv1.JSON{Raw: []byte(defaults)}
+		return pol
+	}
+	nsPol := &gwcapi.GatewayClassConfig{ObjectMeta: metav1.ObjectMeta{Namespace: "controller-ns", Name: "ns-selector"}}
+	nsPol.Spec.TargetSelector = selector("Namespace", "", "env", "prod")
+	nsPol.Spec.Default = &apiextensionsv1.JSON{Raw: []byte(`{"timeout": 1, "waf": "namespace"}`)}
+	gwPol := withDefault(helperGatewayConfig("gw-selector", time.Hour, ""), `{"timeout": 2}`)
+	gwPol.Spec.TargetRef = nil
+	gwPol.Spec.TargetSelector = selector("Gateway", gatewayapi.GroupName, "tier", "edge")
+	otherPol := withDefault(helperGatewayConfig("other-selector", time.Hour, ""), `{"other": true}`)
+	otherPol.Spec.TargetRef = nil
+	otherPol.Spec.TargetSelector = selector("Gateway", gatewayapi.GroupName, "tier", "internal")
+	namedPol := withDefault(helperGatewayConfig("gw-config", time.Hour, ""), `{"timeout": 3}`)
+	namedPol.Spec.TargetRef.Name = "other"
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default", Labels: map[string]string{"env": "prod"}}}
+	gw := &gatewayapi.Gateway{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "gw", Labels: map[string]string{"tier": "edge"}}}
+	r := &fakeReconciler{
+		client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(ns, gw, nsPol, gwPol, otherPol, namedPol).Build(),
+		scheme: scheme,
+	}
+
+	// Policies selecting the Gateway have precedence over policies selecting its namespace
+	values, sources, err := lookupValuesWithSources(context.Background(), r, "gwc", helperValuesBlueprint(`{"timeout": 0}`), "default", "gw", nil)
+	if err != nil {
+		t.Fatalf("lookupValuesWithSources() failed: %v", err)
+	}
+	if !reflect.DeepEqual(values, map[string]any{"timeout": int64(2), "waf": "namespace"}) {
+		t.Errorf("unexpected values %v", values)
+	}
+	if sources["timeout"].Name != "gw-selector" || sources["waf"].Name != "ns-selector" {
+		t.Errorf("unexpected sources %v", sources)
+	}
+
+	// Labels of missing Gateways cannot be looked up
+	if _, _, err = lookupValuesWithSources(context.Background(), r, "gwc", helperValuesBlueprint(`{}`), "default", "missing", nil); err == nil {
+		t.Errorf("expected error for missing Gateway")
+	}
+}
+
+func TestValidateValuesSchema(t *testing.T) {
+	blueprintRef := objectRef{Kind: "GatewayClassBlueprint", Name: "blueprint"}
+	policyRef := objectRef{Kind: "GatewayConfig", Namespace: "default", Name: "gw-config"}
+	sources := valueSources{
+		"vpcId":   {objectRef: policyRef},
+		"subnets": {objectRef: blueprintRef},
+		valueSourceKey([]string{"healthCheck", "port"}): {objectRef: policyRef},
+		valueSourceKey([]string{"healthCheck", "path"}): {objectRef: blueprintRef},
+	}
+	values := map[string]any{
+		"vpcId":       "subnet-1",
+		"subnets":     []any{"a", int64(1)},
+		"healthCheck": map[string]any{"port": int64(80), "path": "/"},
+	}
+
+	violations, err := validateValuesSchema(&apiextensionsv1.JSON{Raw: []byte(valuesTestSchema)}, values, sources)
+	if err != nil {
+		t.Fatalf("validateValuesSchema() failed: %v", err)
+	}
+	paths := []string{}
+	for _, v := range violations {
+		paths = append(paths, v.Path)
+	}
+	if !reflect.DeepEqual(paths, []string{".healthCheck.path", ".subnets[1]", ".vpcId"}) {
+		t.Fatalf("unexpected violations %v", violations)
+	}
+	if !reflect.DeepEqual(violations[0].Sources, []objectRef{blueprintRef}) ||
+		!reflect.DeepEqual(violations[1].Sources, []objectRef{blueprintRef}) ||
+		!reflect.DeepEqual(violations[2].Sources, []objectRef{policyRef}) {
+		t.Errorf("unexpected sources %v", violations)
+	}
+	msg := violationsError(violations).Error()
+	if !strings.Contains(msg, ".vpcId: should match '^vpc-' (from GatewayConfig default/gw-config)") {
+		t.Errorf("unexpected message %q", msg)
+	}
+
+	// Missing values have no source
+	violations, err = validateValuesSchema(&apiextensionsv1.JSON{Raw: []byte(valuesTestSchema)}, map[string]any{}, valueSources{})
+	if err != nil {
+		t.Fatalf("validateValuesSchema() failed: %v", err)
+	}
+	if len(violations) != 2 || violations[0].Path != ".subnets" || len(violations[0].Sources) != 0 {
+		t.Errorf("unexpected violations %v", violations)
+	}
+
+	// No schema
+	if violations, err = validateValuesSchema(nil, values, sources); err != nil || len(violations) != 0 {
+		t.Errorf("unexpected violations %v, error %v", violations, err)
+	}
+	if _, err = validateValuesSchema(&apiextensionsv1.JSON{Raw: []byte(`[]`)}, values, sources); err == nil {
+		t.Errorf("expected error for invalid schema")
+	}
+}
+
+func TestValueSourcesLookup(t *testing.T) {
+	a := objectRef{Kind: "GatewayConfig", Name: "a"}
+	b := objectRef{Kind: "GatewayConfig", Name: "b"}
+	sources := valueSources{
+		valueSourceKey([]string{"foo", "bar"}): {objectRef: a},
+		valueSourceKey([]string{"foo", "baz"}): {objectRef: b},
+		"list":                                 {objectRef: a, Override: true},
+	}
+	if refs := sources.lookup("foo.bar"); !reflect.DeepEqual(refs, []objectRef{a}) {
+		t.Errorf("unexpected sources %v", refs)
+	}
+	if refs := sources.lookup("foo"); !reflect.DeepEqual(refs, []objectRef{a, b}) {
+		t.Errorf("unexpected sources %v", refs)
+	}
+	if refs := sources.lookup("list.nested"); !reflect.DeepEqual(refs, []objectRef{a}) {
+		t.Errorf("unexpected sources %v", refs)
+	}
+	if refs := sources.lookup("missing"); len(refs) != 0 {
+		t.Errorf("unexpected sources %v", refs)
+	}
+}
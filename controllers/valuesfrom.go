@@ -0,0 +1,191 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+)
+
+// Replacement of values from Secrets in debug output
+const redactedValue = "<redacted>"
+
+// Values from a ConfigMap or Secret referenced through valuesFrom
+type valuesFromResult struct {
+	values map[string]any
+
+	// Values are from a Secret
+	sensitive bool
+}
+
+// Validate valuesFrom references, i.e. that references to single keys have a target path
+func validateValuesFrom(refs []gwcapi.ValuesFromSource) error {
+	for _, ref := range refs {
+		if ref.Key != "" && ref.TargetPath == "" {
+			return fmt.Errorf("valuesFrom: %s %s key %q requires a targetPath", ref.Kind, ref.Name, ref.Key)
+		}
+	}
+	return nil
+}
+
+// Lookup values from ConfigMaps and Secrets in a namespace referenced
+// through valuesFrom, either default or override values. Values are
+// returned in the order referenced. Missing objects and keys are
+// errors unless the reference is optional
+func lookupValuesFrom(ctx context.Context, c client.Client, namespace string, refs []gwcapi.ValuesFromSource,
+	override bool) ([]valuesFromResult, error) {
+	if err := validateValuesFrom(refs); err != nil {
+		return nil, err
+	}
+	results := []valuesFromResult{}
+	for _, ref := range refs {
+		if ref.Override != override {
+			continue
+		}
+		data, err := lookupValuesFromData(ctx, c, namespace, ref)
+		if apierrors.IsNotFound(err) && ref.Optional {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("valuesFrom %s %s/%s: %w", ref.Kind, namespace, ref.Name, err)
+		}
+
+		var val any
+		if ref.Key != "" {
+			str, found := data[ref.Key]
+			if !found {
+				if ref.Optional {
+					continue
+				}
+				return nil, fmt.Errorf("valuesFrom %s %s/%s: key %q not found", ref.Kind, namespace, ref.Name, ref.Key)
+			}
+			val = str
+		} else {
+			m := map[string]any{}
+			for k, v := range data {
+				m[k] = v
+			}
+			val = m
+		}
+		values, ok := valueWithPath(ref.TargetPath, val).(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("valuesFrom %s %s/%s: values must be an object", ref.Kind, namespace, ref.Name)
+		}
+		results = append(results, valuesFromResult{values: values, sensitive: ref.Kind == gwcapi.ValuesFromSecret})
+	}
+	return results, nil
+}
+
+// Lookup the data of a ConfigMap or Secret as strings
+func lookupValuesFromData(ctx context.Context, c client.Client, namespace string, ref gwcapi.ValuesFromSource) (map[string]string, error) {
+	key := types.NamespacedName{Namespace: namespace, Name: ref.Name}
+	switch ref.Kind {
+	case gwcapi.ValuesFromConfigMap:
+		var cm corev1.ConfigMap
+		if err := c.Get(ctx, key, &cm); err != nil {
+			return nil, err
+		}
+		return cm.Data, nil
+	case gwcapi.ValuesFromSecret:
+		var secret corev1.Secret
+		if err := c.Get(ctx, key, &secret); err != nil {
+			return nil, err
+		}
+		data := make(map[string]string, len(secret.Data))
+		for k, v := range secret.Data {
+			data[k] = string(v)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("unsupported kind %q", ref.Kind)
+}
+
+// Nest a value in objects following a path with keys separated by
+// dots, e.g. 'tls.certificateArn'. An empty path returns the value
+func valueWithPath(path string, val any) any {
+	if path == "" {
+		return val
+	}
+	keys := strings.Split(path, ".")
+	for idx := len(keys) - 1; idx >= 0; idx-- {
+		val = map[string]any{keys[idx]: val}
+	}
+	return val
+}
+
+// Values from Secrets, i.e. values which must not be included in debug output
+func sensitiveValues(values map[string]any, sources valueSources) []string {
+	sensitive := []string{}
+	for key, src := range sources {
+		if !src.Sensitive {
+			continue
+		}
+		val, _ := valueAtPath(values, valueSourcePath(key))
+		if str, ok := val.(string); ok && str != "" {
+			sensitive = append(sensitive, str)
+		}
+	}
+	return sensitive
+}
+
+// Copy of values with values from Secrets replaced by a placeholder
+func redactSensitiveValues(values map[string]any, sources valueSources) map[string]any {
+	redacted := runtime.DeepCopyJSON(values)
+	for key, src := range sources {
+		if !src.Sensitive {
+			continue
+		}
+		path := valueSourcePath(key)
+		val, _ := valueAtPath(redacted, path[:len(path)-1])
+		if parent, ok := val.(map[string]any); ok {
+			parent[path[len(path)-1]] = redactedValue
+		}
+	}
+	return redacted
+}
+
+// Replace values from Secrets in debug output
+func redactText(text string, sensitive []string) string {
+	for _, val := range sensitive {
+		text = strings.ReplaceAll(text, val, redactedValue)
+	}
+	return text
+}
@@ -0,0 +1,183 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+)
+
+func helperValuesFromClient(t *testing.T, objs ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := gwcapi.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cm"},
+		Data: map[string]string{"providerConfigName": "aws", "region": "eu-north-1"}}
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "secret"},
+		Data: map[string][]byte{"token": []byte("s3cr3t")}}
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(append(objs, cm, secret)...).Build()
+}
+
+func TestLookupValuesFrom(t *testing.T) {
+	c := helperValuesFromClient(t)
+	refs := []gwcapi.ValuesFromSource{
+		{Kind: gwcapi.ValuesFromConfigMap, Name: "cm", TargetPath: "provider"},
+		{Kind: gwcapi.ValuesFromSecret, Name: "secret", Key: "token", TargetPath: "api.token", Override: true},
+		{Kind: gwcapi.ValuesFromSecret, Name: "missing", Optional: true},
+		{Kind: gwcapi.ValuesFromConfigMap, Name: "cm", Key: "missing", TargetPath: "missing", Optional: true},
+	}
+
+	results, err := lookupValuesFrom(context.Background(), c, "default", refs, false)
+	if err != nil {
+		t.Fatalf("lookupValuesFrom() failed: %v", err)
+	}
+	expected := []valuesFromResult{{values: map[string]any{"provider": map[string]any{"providerConfigName": "aws", "region": "eu-north-1"}}}}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("lookupValuesFrom() got %v, expected %v", results, expected)
+	}
+
+	results, err = lookupValuesFrom(context.Background(), c, "default", refs, true)
+	if err != nil {
+		t.Fatalf("lookupValuesFrom() failed: %v", err)
+	}
+	expected = []valuesFromResult{{values: map[string]any{"api": map[string]any{"token": "s3cr3t"}}, sensitive: true}}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("lookupValuesFrom() got %v, expected %v", results, expected)
+	}
+
+	// Missing objects and keys are errors unless optional
+	if _, err = lookupValuesFrom(context.Background(), c, "default",
+		[]gwcapi.ValuesFromSource{{Kind: gwcapi.ValuesFromSecret, Name: "missing"}}, false); err == nil {
+		t.Errorf("expected error for missing secret")
+	}
+	if _, err = lookupValuesFrom(context.Background(), c, "default",
+		[]gwcapi.ValuesFromSource{{Kind: gwcapi.ValuesFromConfigMap, Name: "cm", Key: "missing", TargetPath: "x"}}, false); err == nil {
+		t.Errorf("expected error for missing key")
+	}
+	// Objects are looked up in the given namespace only
+	if _, err = lookupValuesFrom(context.Background(), c, "other",
+		[]gwcapi.ValuesFromSource{{Kind: gwcapi.ValuesFromConfigMap, Name: "cm"}}, false); err == nil {
+		t.Errorf("expected error for configmap in other namespace")
+	}
+	if err = validateValuesFrom([]gwcapi.ValuesFromSource{{Kind: gwcapi.ValuesFromSecret, Name: "secret", Key: "token"}}); err == nil {
+		t.Errorf("expected error for key without targetPath")
+	}
+}
+
+func TestLookupValuesWithValuesFrom(t *testing.T) {
+	pol := helperGatewayConfig("gw-config", time.Hour, `{"api": {"token": "inline"}}`)
+	pol.Spec.Default = &apiextensionsv1.JSON{Raw: []byte(`{"region": "inline"}`)}
+	pol.Spec.ValuesFrom = []gwcapi.ValuesFromSource{
+		{Kind: gwcapi.ValuesFromConfigMap, Name: "cm"},
+		{Kind: gwcapi.ValuesFromSecret, Name: "secret", Key: "token", TargetPath: "api.token", Override: true},
+	}
+	r := &fakeReconciler{client: helperValuesFromClient(t, pol)}
+
+	values, sources, err := lookupValuesWithSources(context.Background(), r, "gwc", helperValuesBlueprint(`{}`), "default", "gw", nil)
+	if err != nil {
+		t.Fatalf("lookupValuesWithSources() failed: %v", err)
+	}
+	expected := map[string]any{
+		"providerConfigName": "aws",
+		"region":             "eu-north-1",
+		"api":                map[string]any{"token": "s3cr3t"},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("unexpected values %v", values)
+	}
+	if src := sources[valueSourceKey([]string{"api", "token"})]; src.Name != "gw-config" || !src.Override || !src.Sensitive {
+		t.Errorf("unexpected source %+v", src)
+	}
+	if sources["region"].Sensitive {
+		t.Errorf("unexpected sensitive value from configmap")
+	}
+
+	// Values from Secrets are redacted from debug output
+	redacted := redactSensitiveValues(values, sources)
+	if redacted["api"].(map[string]any)["token"] != redactedValue || values["api"].(map[string]any)["token"] != "s3cr3t" {
+		t.Errorf("unexpected redacted values %v", redacted)
+	}
+	tmplValues := &TemplateValues{Values: values, sensitiveValues: sensitiveValues(values, sources)}
+	if out := tmplValues.debugString(); strings.Contains(out, "s3cr3t") || !strings.Contains(out, "eu-north-1") {
+		t.Errorf("unexpected debug output %s", out)
+	}
+}
+
+func TestLookupValuesWithDottedSecretKeys(t *testing.T) {
+	tlsSecret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "tls"},
+		Data: map[string][]byte{"tls.crt": []byte("cert-data"), "ca.crt": []byte("ca-data")}}
+	pol := helperGatewayConfig("gw-config", time.Hour, "")
+	pol.Spec.ValuesFrom = []gwcapi.ValuesFromSource{
+		{Kind: gwcapi.ValuesFromSecret, Name: "tls"},
+		{Kind: gwcapi.ValuesFromSecret, Name: "tls", TargetPath: "tls"},
+	}
+	r := &fakeReconciler{client: helperValuesFromClient(t, pol, tlsSecret)}
+
+	values, sources, err := lookupValuesWithSources(context.Background(), r, "gwc", helperValuesBlueprint(`{}`), "default", "gw", nil)
+	if err != nil {
+		t.Fatalf("lookupValuesWithSources() failed: %v", err)
+	}
+	paths := [][]string{{"tls.crt"}, {"ca.crt"}, {"tls", "tls.crt"}, {"tls", "ca.crt"}}
+	for _, path := range paths {
+		if src := sources[valueSourceKey(path)]; !src.Sensitive {
+			t.Errorf("value %q not sensitive, source %+v", path, src)
+		}
+	}
+
+	// Values from Secrets are redacted from debug output
+	redacted := redactSensitiveValues(values, sources)
+	for _, path := range paths {
+		if val, _ := valueAtPath(redacted, path); val != redactedValue {
+			t.Errorf("value %q not redacted: %v", path, val)
+		}
+	}
+	tmplValues := &TemplateValues{Values: values, sensitiveValues: sensitiveValues(values, sources)}
+	if out := tmplValues.debugString(); strings.Contains(out, "cert-data") || strings.Contains(out, "ca-data") {
+		t.Errorf("unexpected debug output %s", out)
+	}
+}
+
+func TestMapValuesFromReferrers(t *testing.T) {
+	defer func(ns string) { ControllerNamespace = ns }(ControllerNamespace)
+	ControllerNamespace = "controller-ns"
+
+	pol := helperGatewayConfig("gw-config", 0, "")
+	pol.Spec.ValuesFrom = []gwcapi.ValuesFromSource{{Kind: gwcapi.ValuesFromSecret, Name: "secret"}}
+	gwcb := helperValuesBlueprint(`{}`)
+	gwcb.Spec.Values.ValuesFrom = []gwcapi.ValuesFromSource{{Kind: gwcapi.ValuesFromConfigMap, Name: "cm"}}
+	c := helperValuesFromClient(t, pol, gwcb)
+
+	lookup := func(kind gwcapi.ValuesFromKind, namespace, name string) []string {
+		names := []string{}
+		obj := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+		for _, referrer := range lookupValuesFromReferrers(context.Background(), c, kind, obj) {
+			names = append(names, referrer.GetNamespace()+"/"+referrer.GetName())
+		}
+		return names
+	}
+	if names := lookup(gwcapi.ValuesFromSecret, "default", "secret"); !reflect.DeepEqual(names, []string{"default/gw-config"}) {
+		t.Errorf("Got %v", names)
+	}
+	if names := lookup(gwcapi.ValuesFromConfigMap, "default", "secret"); len(names) != 0 {
+		t.Errorf("Expected no referrers for other kind, got %v", names)
+	}
+	// Blueprints use objects in the controller namespace
+	if names := lookup(gwcapi.ValuesFromConfigMap, "controller-ns", "cm"); !reflect.DeepEqual(names, []string{"/blueprint"}) {
+		t.Errorf("Got %v", names)
+	}
+}
@@ -0,0 +1,531 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1b1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+	selfapi "github.com/tv2-oss/bifrost-gateway-controller/pkg/api"
+)
+
+// Implemented by reconcilers which watch child resources created from templates
+type childWatcher interface {
+	watchChildKind(gvk schema.GroupVersionKind) error
+}
+
+// Dynamic watches on the kinds of child resources created from
+// templates. Kinds are only known when templates have been rendered,
+// hence watches are added when child resources are applied. Only
+// metadata is cached for child resources.
+type childWatches struct {
+	mu         sync.Mutex
+	controller controller.Controller
+	cache      cache.Cache
+	parentKind string
+	watched    map[schema.GroupVersionKind]bool
+}
+
+func newChildWatches(c controller.Controller, cache cache.Cache, parentKind string) *childWatches {
+	return &childWatches{
+		controller: c,
+		cache:      cache,
+		parentKind: parentKind,
+		watched:    map[schema.GroupVersionKind]bool{},
+	}
+}
+
+// Start watching a kind of child resources, unless already watched
+func (w *childWatches) watchChildKind(gvk schema.GroupVersionKind) error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.watched[gvk] {
+		return nil
+	}
+	obj := &metav1.PartialObjectMetadata{}
+	obj.SetGroupVersionKind(gvk)
+	if err := w.controller.Watch(source.Kind(w.cache, client.Object(obj),
+		handler.EnqueueRequestsFromMapFunc(mapChildToParent(w.parentKind)))); err != nil {
+		return err
+	}
+	w.watched[gvk] = true
+	return nil
+}
+
+// Map a child resource to its parent using the parent annotation
+func mapChildToParent(parentKind string) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		parent, found := obj.GetAnnotations()[selfapi.ParentAnnotation]
+		if !found {
+			return nil
+		}
+		parts := strings.Split(parent, "/")
+		if len(parts) != 3 || parts[0] != parentKind {
+			return nil
+		}
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: parts[1], Name: parts[2]}}}
+	}
+}
+
+// Return the Gateways referenced as parents of a route
+func routeParentGateways(rt client.Object) []types.NamespacedName {
+	gateways := []types.NamespacedName{}
+	spec := routeCommonSpec(rt)
+	if spec == nil {
+		return gateways
+	}
+	for _, pRef := range spec.ParentRefs {
+		if (pRef.Group != nil && *pRef.Group != gatewayapi.Group(gatewayapi.GroupName)) ||
+			(pRef.Kind != nil && *pRef.Kind != gatewayapi.Kind("Gateway")) {
+			continue
+		}
+		nn := types.NamespacedName{Namespace: rt.GetNamespace(), Name: string(pRef.Name)}
+		if pRef.Namespace != nil {
+			nn.Namespace = string(*pRef.Namespace)
+		}
+		gateways = append(gateways, nn)
+	}
+	return gateways
+}
+
+// Whether a route may attach to a listener of a parent Gateway, i.e.
+// has a parent reference to the Gateway without section name or with
+// the name of the listener
+func routeReferencesListener(rt client.Object, gw types.NamespacedName, listener gatewayapi.SectionName) bool {
+	spec := routeCommonSpec(rt)
+	if spec == nil {
+		return false
+	}
+	for _, pRef := range spec.ParentRefs {
+		if (pRef.Group != nil && *pRef.Group != gatewayapi.Group(gatewayapi.GroupName)) ||
+			(pRef.Kind != nil && *pRef.Kind != gatewayapi.Kind("Gateway")) {
+			continue
+		}
+		nn := types.NamespacedName{Namespace: rt.GetNamespace(), Name: string(pRef.Name)}
+		if pRef.Namespace != nil {
+			nn.Namespace = string(*pRef.Namespace)
+		}
+		if nn == gw && (pRef.SectionName == nil || *pRef.SectionName == listener) {
+			return true
+		}
+	}
+	return false
+}
+
+func toRequests(names []types.NamespacedName) []reconcile.Request {
+	requests := make([]reconcile.Request, 0, len(names))
+	for _, nn := range names {
+		requests = append(requests, reconcile.Request{NamespacedName: nn})
+	}
+	return requests
+}
+
+// Map a route to the Gateways it references as parents
+func mapRouteToGateways(ctx context.Context, obj client.Object) []reconcile.Request {
+	return toRequests(routeParentGateways(obj))
+}
+
+// Lookup Gateways using one of the GatewayClasses and optionally in a specific namespace
+func lookupGatewaysForClasses(ctx context.Context, c client.Client, classNames []string, namespace string) []types.NamespacedName {
+	logger := log.FromContext(ctx)
+
+	gateways := []types.NamespacedName{}
+	if len(classNames) == 0 {
+		return gateways
+	}
+	var gwList gatewayapi.GatewayList
+	if err := c.List(ctx, &gwList, client.InNamespace(namespace)); err != nil {
+		logger.Error(err, "cannot list gateways")
+		return gateways
+	}
+	for idx := range gwList.Items {
+		gw := &gwList.Items[idx]
+		for _, className := range classNames {
+			if string(gw.Spec.GatewayClassName) == className {
+				gateways = append(gateways, types.NamespacedName{Namespace: gw.Namespace, Name: gw.Name})
+				break
+			}
+		}
+	}
+	return gateways
+}
+
+// Lookup GatewayClasses of ours which use a GatewayClassBlueprint
+func lookupClassesForBlueprint(ctx context.Context, c client.Client, blueprintName string) []string {
+	logger := log.FromContext(ctx)
+
+	classNames := []string{}
+	var gwcList gatewayapi.GatewayClassList
+	if err := c.List(ctx, &gwcList); err != nil {
+		logger.Error(err, "cannot list gatewayclasses")
+		return classNames
+	}
+	for idx := range gwcList.Items {
+		gwc := &gwcList.Items[idx]
+		if !isOurGatewayClass(gwc) || gwc.Spec.ParametersRef == nil {
+			continue
+		}
+		pRef := gwc.Spec.ParametersRef
+		if pRef.Group == "gateway.tv2.dk" && pRef.Kind == "GatewayClassBlueprint" && pRef.Name == blueprintName {
+			classNames = append(classNames, gwc.Name)
+		}
+	}
+	return classNames
+}
+
+// Lookup Gateways affected by a GatewayClassConfig or GatewayConfig
+// policy, i.e. following the targets used in lookupValues
+func lookupGatewaysForPolicy(ctx context.Context, c client.Client, policy valuesPolicy) []types.NamespacedName {
+	if policy.GetTargetSelector() != nil {
+		return lookupGatewaysSelectedByPolicy(ctx, c, policy)
+	}
+	policyNamespace := policy.GetNamespace()
+	targetRef := policy.GetTargetRef()
+	switch {
+	case targetRef.Kind == "GatewayClass" && targetRef.Group == gatewayapi.GroupName:
+		if policyNamespace == ControllerNamespace {
+			return lookupGatewaysForClasses(ctx, c, []string{string(targetRef.Name)}, "")
+		}
+		return lookupGatewaysForClasses(ctx, c, []string{string(targetRef.Name)}, policyNamespace)
+	case targetRef.Kind == "Namespace" && targetRef.Group == "":
+		if string(targetRef.Name) != policyNamespace {
+			return nil
+		}
+		var gwList gatewayapi.GatewayList
+		if err := c.List(ctx, &gwList, client.InNamespace(policyNamespace)); err != nil {
+			log.FromContext(ctx).Error(err, "cannot list gateways")
+			return nil
+		}
+		gateways := make([]types.NamespacedName, 0, len(gwList.Items))
+		for idx := range gwList.Items {
+			gateways = append(gateways, types.NamespacedName{Namespace: gwList.Items[idx].Namespace, Name: gwList.Items[idx].Name})
+		}
+		return gateways
+	case targetRef.Kind == "Gateway" && targetRef.Group == gatewayapi.GroupName:
+		nn := types.NamespacedName{Namespace: policyNamespace, Name: string(targetRef.Name)}
+		if targetRef.Namespace != nil {
+			nn.Namespace = string(*targetRef.Namespace)
+		}
+		return []types.NamespacedName{nn}
+	}
+	return nil
+}
+
+// Lookup Gateways selected by labels by a policy, either directly or
+// through the labels of their namespace
+func lookupGatewaysSelectedByPolicy(ctx context.Context, c client.Client, policy valuesPolicy) []types.NamespacedName {
+	logger := log.FromContext(ctx)
+
+	var opts []client.ListOption
+	if !isGlobalPolicy(policy) {
+		opts = append(opts, client.InNamespace(policy.GetNamespace()))
+	}
+	var gwList gatewayapi.GatewayList
+	if err := c.List(ctx, &gwList, opts...); err != nil {
+		logger.Error(err, "cannot list gateways")
+		return nil
+	}
+
+	nsLabels := map[string]labels.Set{}
+	if policy.GetTargetSelector().Kind == "Namespace" {
+		var nsList corev1.NamespaceList
+		if err := c.List(ctx, &nsList); err != nil {
+			logger.Error(err, "cannot list namespaces")
+			return nil
+		}
+		for idx := range nsList.Items {
+			nsLabels[nsList.Items[idx].Name] = nsList.Items[idx].Labels
+		}
+	}
+
+	gateways := []types.NamespacedName{}
+	for idx := range gwList.Items {
+		gw := &gwList.Items[idx]
+		selected := policySelects(policy, "Gateway", gw.Namespace, gw.Labels)
+		if lbls, found := nsLabels[gw.Namespace]; found {
+			selected = policySelects(policy, "Namespace", gw.Namespace, lbls)
+		}
+		if selected {
+			gateways = append(gateways, types.NamespacedName{Namespace: gw.Namespace, Name: gw.Name})
+		}
+	}
+	return gateways
+}
+
+// Map a GatewayClass to Gateways using the class
+func mapGatewayClassToGateways(c client.Client) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		return toRequests(lookupGatewaysForClasses(ctx, c, []string{obj.GetName()}, ""))
+	}
+}
+
+// Map a GatewayClassBlueprint to Gateways using a GatewayClass which use the blueprint
+func mapBlueprintToGateways(c client.Client) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		return toRequests(lookupGatewaysForClasses(ctx, c, lookupClassesForBlueprint(ctx, c, obj.GetName()), ""))
+	}
+}
+
+// Map a GatewayClassConfig or GatewayConfig to the Gateways affected by the policy
+func mapPolicyToGateways(c client.Client) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		if policy, ok := obj.(valuesPolicy); ok {
+			return toRequests(lookupGatewaysForPolicy(ctx, c, policy))
+		}
+		return nil
+	}
+}
+
+// Lookup blueprints and policies using a ConfigMap or Secret for
+// values through valuesFrom. Blueprints use objects in the controller
+// namespace
+func lookupValuesFromReferrers(ctx context.Context, c client.Client, kind gwcapi.ValuesFromKind, obj client.Object) []client.Object {
+	logger := log.FromContext(ctx)
+
+	references := func(values *gwcapi.TemplateValues) bool {
+		for _, ref := range values.ValuesFrom {
+			if ref.Kind == kind && ref.Name == obj.GetName() {
+				return true
+			}
+		}
+		return false
+	}
+
+	referrers := []client.Object{}
+	if obj.GetNamespace() == ControllerNamespace {
+		var gwcbList gwcapi.GatewayClassBlueprintList
+		if err := c.List(ctx, &gwcbList); err != nil {
+			logger.Error(err, "cannot list gatewayclassblueprints")
+			return nil
+		}
+		for idx := range gwcbList.Items {
+			if references(&gwcbList.Items[idx].Spec.Values) {
+				referrers = append(referrers, &gwcbList.Items[idx])
+			}
+		}
+	}
+	for _, list := range []client.ObjectList{&gwcapi.GatewayClassConfigList{}, &gwcapi.GatewayConfigList{}} {
+		if err := c.List(ctx, list, client.InNamespace(obj.GetNamespace())); err != nil {
+			logger.Error(err, "cannot list policies")
+			return nil
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			logger.Error(err, "cannot extract policies")
+			return nil
+		}
+		for _, item := range items {
+			if policy, ok := item.(valuesPolicy); ok && references(policy.GetTemplateValues()) {
+				referrers = append(referrers, policy)
+			}
+		}
+	}
+	return referrers
+}
+
+// Map a ConfigMap or Secret to the objects mapped from the blueprints
+// and policies using it for values, e.g. the Gateways affected by the
+// blueprints and policies
+func mapValuesFromReferrers(c client.Client, kind gwcapi.ValuesFromKind, mapReferrer func(referrer client.Object) handler.MapFunc) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		requests := []reconcile.Request{}
+		for _, referrer := range lookupValuesFromReferrers(ctx, c, kind, obj) {
+			requests = append(requests, mapReferrer(referrer)(ctx, referrer)...)
+		}
+		return requests
+	}
+}
+
+// Chain a mapping to Gateways with a mapping from Gateways to the routes of a given kind attached to them
+func mapGatewaysToRoutes(c client.Client, rtType *routeType, gatewayMapper handler.MapFunc) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		logger := log.FromContext(ctx)
+
+		gwRequests := gatewayMapper(ctx, obj)
+		if len(gwRequests) == 0 {
+			return nil
+		}
+		rtList := rtType.NewRouteList()
+		if err := c.List(ctx, rtList); err != nil {
+			logger.Error(err, "cannot list routes", "kind", rtType.Kind)
+			return nil
+		}
+		routes, err := routeListItems(rtList)
+		if err != nil {
+			logger.Error(err, "cannot extract routes", "kind", rtType.Kind)
+			return nil
+		}
+		requests := []reconcile.Request{}
+		for _, rt := range routes {
+		parents:
+			for _, parent := range routeParentGateways(rt) {
+				for _, gwReq := range gwRequests {
+					if gwReq.NamespacedName == parent {
+						requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: rt.GetNamespace(), Name: rt.GetName()}})
+						break parents
+					}
+				}
+			}
+		}
+		return requests
+	}
+}
+
+// Map a GatewayConfig to the routes of a given kind affected by the
+// policy, i.e. routes attached to affected Gateways and the route
+// targeted by the policy
+func mapPolicyToRoutes(c client.Client, rtType *routeType) handler.MapFunc {
+	toRoutes := mapGatewaysToRoutes(c, rtType, mapPolicyToGateways(c))
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		requests := toRoutes(ctx, obj)
+		if policy, ok := obj.(*gwcapi.GatewayConfig); ok && policyTargetRouteType(policy) == rtType {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+				Namespace: policy.Namespace, Name: string(policy.GetTargetRef().Name)}})
+		}
+		return requests
+	}
+}
+
+// Map a Gateway to itself, used for chaining with mapGatewaysToRoutes
+func mapGatewayToSelf(ctx context.Context, obj client.Object) []reconcile.Request {
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}}}
+}
+
+// Map a Namespace to Gateways with listeners selecting route
+// namespaces by labels and to Gateways in the namespace, which
+// policies may select through the labels of the namespace
+func mapNamespaceToGateways(c client.Client) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		var gwList gatewayapi.GatewayList
+		if err := c.List(ctx, &gwList); err != nil {
+			log.FromContext(ctx).Error(err, "cannot list gateways")
+			return nil
+		}
+		gateways := []types.NamespacedName{}
+		for idx := range gwList.Items {
+			if hasNamespaceSelector(&gwList.Items[idx]) || gwList.Items[idx].Namespace == obj.GetName() {
+				gateways = append(gateways, types.NamespacedName{Namespace: gwList.Items[idx].Namespace, Name: gwList.Items[idx].Name})
+			}
+		}
+		return toRequests(gateways)
+	}
+}
+
+// Map a ReferenceGrant to the objects of a given kind, e.g. Gateways
+// or HTTPRoutes, in the namespaces the grant permits references from
+func mapReferenceGrantToReferrers(c client.Client, group, kind string, newList func() client.ObjectList) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		rg, ok := obj.(*gatewayv1b1.ReferenceGrant)
+		if !ok {
+			return nil
+		}
+		requests := []reconcile.Request{}
+		for _, from := range rg.Spec.From {
+			if string(from.Group) != group || string(from.Kind) != kind {
+				continue
+			}
+			list := newList()
+			if err := c.List(ctx, list, client.InNamespace(string(from.Namespace))); err != nil {
+				log.FromContext(ctx).Error(err, "cannot list referrers", "kind", kind)
+				continue
+			}
+			objs, err := meta.ExtractList(list)
+			if err != nil {
+				continue
+			}
+			for _, o := range objs {
+				if referrer, ok := o.(client.Object); ok {
+					requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+						Namespace: referrer.GetNamespace(), Name: referrer.GetName()}})
+				}
+			}
+		}
+		return requests
+	}
+}
+
+// Map an object to policies in namespaces returned by a function. An
+// empty namespace maps to policies in all namespaces
+func mapToPolicies(c client.Client, newList func() client.ObjectList, namespaces func(obj client.Object) []string) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		logger := log.FromContext(ctx)
+
+		seen := map[types.NamespacedName]bool{}
+		requests := []reconcile.Request{}
+		for _, ns := range namespaces(obj) {
+			list := newList()
+			if err := c.List(ctx, list, client.InNamespace(ns)); err != nil {
+				logger.Error(err, "cannot list policies")
+				continue
+			}
+			items, err := meta.ExtractList(list)
+			if err != nil {
+				logger.Error(err, "cannot extract policies")
+				continue
+			}
+			for _, item := range items {
+				policy, ok := item.(client.Object)
+				if !ok {
+					continue
+				}
+				nn := types.NamespacedName{Namespace: policy.GetNamespace(), Name: policy.GetName()}
+				if !seen[nn] {
+					seen[nn] = true
+					requests = append(requests, reconcile.Request{NamespacedName: nn})
+				}
+			}
+		}
+		return requests
+	}
+}
@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	gwcapi "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
+	selfapi "github.com/tv2-oss/bifrost-gateway-controller/pkg/api"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1a2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+)
+
+func helperRequestNames(requests []reconcile.Request) []string {
+	names := []string{}
+	for _, req := range requests {
+		names = append(names, req.String())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func helperWatchesClient(t *testing.T) client.Client {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = gatewayapi.Install(scheme)
+	_ = gwcapi.AddToScheme(scheme)
+
+	gwc := func(name, blueprint string) *gatewayapi.GatewayClass {
+		return &gatewayapi.GatewayClass{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: gatewayapi.GatewayClassSpec{
+				ControllerName: selfapi.SelfControllerName,
+				ParametersRef:  &gatewayapi.ParametersReference{Group: "gateway.tv2.dk", Kind: "GatewayClassBlueprint", Name: blueprint},
+			},
+		}
+	}
+	ns := func(name string, labels map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	gw := func(namespace, name, class string, labels map[string]string) *gatewayapi.Gateway {
+		return &gatewayapi.Gateway{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels},
+			Spec:       gatewayapi.GatewaySpec{GatewayClassName: gatewayapi.ObjectName(class)},
+		}
+	}
+	rt := func(namespace, name string, parents ...gatewayapi.ParentReference) *gatewayapi.HTTPRoute {
+		return &gatewayapi.HTTPRoute{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Spec:       gatewayapi.HTTPRouteSpec{CommonRouteSpec: gatewayapi.CommonRouteSpec{ParentRefs: parents}},
+		}
+	}
+
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		gwc("class-a", "blueprint-a"), gwc("class-b", "blueprint-b"),
+		ns("ns1", nil), ns("ns2", map[string]string{"env": "prod"}), ns("ns3", nil),
+		gw("ns1", "gw1", "class-a", map[string]string{"tier": "edge"}), gw("ns2", "gw2", "class-a", nil),
+		gw("ns2", "gw3", "class-b", map[string]string{"tier": "edge"}),
+		rt("ns1", "rt1", gatewayapi.ParentReference{Name: "gw1"}),
+		rt("ns3", "rt2", gatewayapi.ParentReference{Name: "gw3", Namespace: PtrTo(gatewayapi.Namespace("ns2"))}),
+		rt("ns2", "rt3", gatewayapi.ParentReference{Name: "gw3", Kind: PtrTo(gatewayapi.Kind("Service"))}),
+	).Build()
+}
+
+func TestMapChildToParent(t *testing.T) {
+	child := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Annotations: map[string]string{selfapi.ParentAnnotation: "Gateway/ns1/gw1"},
+	}}
+	requests := mapChildToParent("Gateway")(context.Background(), child)
+	if !reflect.DeepEqual(helperRequestNames(requests), []string{"ns1/gw1"}) {
+		t.Fatalf("Got %v", requests)
+	}
+	if requests := mapChildToParent("HTTPRoute")(context.Background(), child); len(requests) != 0 {
+		t.Fatalf("Expected no requests for other parent kind, got %v", requests)
+	}
+}
+
+func TestMapBlueprintToGateways(t *testing.T) {
+	c := helperWatchesClient(t)
+	gwcb := &gwcapi.GatewayClassBlueprint{ObjectMeta: metav1.ObjectMeta{Name: "blueprint-a"}}
+	requests := mapBlueprintToGateways(c)(context.Background(), gwcb)
+	if names := helperRequestNames(requests); !reflect.DeepEqual(names, []string{"ns1/gw1", "ns2/gw2"}) {
+		t.Fatalf("Got %v", names)
+	}
+}
+
+func TestMapPolicyToGateways(t *testing.T) {
+	c := helperWatchesClient(t)
+	defer func(ns string) { ControllerNamespace = ns }(ControllerNamespace)
+	ControllerNamespace = "controller-ns"
+	cases := []struct {
+		policy client.Object
+		names  []string
+	}{
+		{&gwcapi.GatewayClassConfig{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "controller-ns", Name: "p"},
+			Spec: gwcapi.GatewayClassConfigSpec{TargetRef: &gatewayv1a2.NamespacedPolicyTargetReference{
+				Group: gatewayapi.GroupName, Kind: "GatewayClass", Name: "class-a"}}},
+			[]string{"ns1/gw1", "ns2/gw2"}},
+		{&gwcapi.GatewayClassConfig{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns2", Name: "p"},
+			Spec: gwcapi.GatewayClassConfigSpec{TargetRef: &gatewayv1a2.NamespacedPolicyTargetReference{
+				Group: gatewayapi.GroupName, Kind: "GatewayClass", Name: "class-a"}}},
+			[]string{"ns2/gw2"}},
+		{&gwcapi.GatewayConfig{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns2", Name: "p"},
+			Spec: gwcapi.GatewayConfigSpec{TargetRef: &gwcapi.GatewayConfigTargetReference{NamespacedPolicyTargetReference: gatewayv1a2.NamespacedPolicyTargetReference{
+				Group: "", Kind: "Namespace", Name: "ns2"}}}},
+			[]string{"ns2/gw2", "ns2/gw3"}},
+		{&gwcapi.GatewayConfig{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "p"},
+			Spec: gwcapi.GatewayConfigSpec{TargetRef: &gwcapi.GatewayConfigTargetReference{NamespacedPolicyTargetReference: gatewayv1a2.NamespacedPolicyTargetReference{
+				Group: gatewayapi.GroupName, Kind: "Gateway", Name: "gw1"}}}},
+			[]string{"ns1/gw1"}},
+		{&gwcapi.GatewayClassConfig{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "controller-ns", Name: "p"},
+			Spec: gwcapi.GatewayClassConfigSpec{TargetSelector: &gwcapi.PolicyTargetSelector{
+				Group: gatewayapi.GroupName, Kind: "Gateway", Selector: metav1.LabelSelector{MatchLabels: map[string]string{"tier": "edge"}}}}},
+			[]string{"ns1/gw1", "ns2/gw3"}},
+		{&gwcapi.GatewayConfig{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns2", Name: "p"},
+			Spec: gwcapi.GatewayConfigSpec{TargetSelector: &gwcapi.PolicyTargetSelector{
+				Group: gatewayapi.GroupName, Kind: "Gateway", Selector: metav1.LabelSelector{MatchLabels: map[string]string{"tier": "edge"}}}}},
+			[]string{"ns2/gw3"}},
+		{&gwcapi.GatewayClassConfig{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "controller-ns", Name: "p"},
+			Spec: gwcapi.GatewayClassConfigSpec{TargetSelector: &gwcapi.PolicyTargetSelector{
+				Kind: "Namespace", Selector: metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}}}}},
+			[]string{"ns2/gw2", "ns2/gw3"}},
+	}
+	for idx, tcase := range cases {
+		names := helperRequestNames(mapPolicyToGateways(c)(context.Background(), tcase.policy))
+		if !reflect.DeepEqual(names, tcase.names) {
+			t.Fatalf("Case %v, got %v, expected %v", idx, names, tcase.names)
+		}
+	}
+}
+
+func TestMapPolicyToRoutes(t *testing.T) {
+	c := helperWatchesClient(t)
+	policy := &gwcapi.GatewayConfig{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "p"},
+		Spec: gwcapi.GatewayConfigSpec{TargetRef: &gwcapi.GatewayConfigTargetReference{NamespacedPolicyTargetReference: gatewayv1a2.NamespacedPolicyTargetReference{
+			Group: gatewayapi.GroupName, Kind: "HTTPRoute", Name: "rt1"}}}}
+	if names := helperRequestNames(mapPolicyToRoutes(c, httpRouteType)(context.Background(), policy)); !reflect.DeepEqual(names, []string{"ns1/rt1"}) {
+		t.Fatalf("Got %v", names)
+	}
+	if requests := mapPolicyToRoutes(c, grpcRouteType)(context.Background(), policy); len(requests) != 0 {
+		t.Fatalf("Expected no requests for other route kind, got %v", requests)
+	}
+
+	// Policies targeting a listener map to routes attached to the Gateway
+	policy.Spec.TargetRef = &gwcapi.GatewayConfigTargetReference{
+		NamespacedPolicyTargetReference: gatewayv1a2.NamespacedPolicyTargetReference{Group: gatewayapi.GroupName, Kind: "Gateway", Name: "gw1"},
+		SectionName:                     PtrTo(gatewayapi.SectionName("http"))}
+	if names := helperRequestNames(mapPolicyToRoutes(c, httpRouteType)(context.Background(), policy)); !reflect.DeepEqual(names, []string{"ns1/rt1"}) {
+		t.Fatalf("Got %v", names)
+	}
+}
+
+func TestRouteReferencesListener(t *testing.T) {
+	gw := types.NamespacedName{Namespace: "default", Name: "gw"}
+	rt := helperHTTPRoute("rt", "default", gatewayapi.ParentReference{Name: "gw", SectionName: PtrTo(gatewayapi.SectionName("http"))})
+	if !routeReferencesListener(rt, gw, "http") || routeReferencesListener(rt, gw, "https") {
+		t.Errorf("unexpected listener references for section name")
+	}
+	rt = helperHTTPRoute("rt", "default", gatewayapi.ParentReference{Name: "gw"})
+	if !routeReferencesListener(rt, gw, "https") || routeReferencesListener(rt, types.NamespacedName{Namespace: "other", Name: "gw"}, "https") {
+		t.Errorf("unexpected listener references without section name")
+	}
+}
+
+func TestMapGatewaysToRoutes(t *testing.T) {
+	c := helperWatchesClient(t)
+	gwcb := &gwcapi.GatewayClassBlueprint{ObjectMeta: metav1.ObjectMeta{Name: "blueprint-b"}}
+	names := helperRequestNames(mapGatewaysToRoutes(c, httpRouteType, mapBlueprintToGateways(c))(context.Background(), gwcb))
+	if !reflect.DeepEqual(names, []string{"ns3/rt2"}) {
+		t.Fatalf("Got %v", names)
+	}
+	gw := &gatewayapi.Gateway{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "gw1"}}
+	names = helperRequestNames(mapGatewaysToRoutes(c, httpRouteType, mapGatewayToSelf)(context.Background(), gw))
+	if !reflect.DeepEqual(names, []string{"ns1/rt1"}) {
+		t.Fatalf("Got %v", names)
+	}
+}
+
+func TestMapNamespaceToGateways(t *testing.T) {
+	c := helperWatchesClient(t)
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns2"}}
+	names := helperRequestNames(mapNamespaceToGateways(c)(context.Background(), ns))
+	if !reflect.DeepEqual(names, []string{"ns2/gw2", "ns2/gw3"}) {
+		t.Fatalf("Got %v", names)
+	}
+}
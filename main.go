@@ -49,6 +49,8 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	gateway "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1a2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	gatewayv1b1 "sigs.k8s.io/gateway-api/apis/v1beta1"
 
 	gatewaytv2dkv1a1 "github.com/tv2-oss/bifrost-gateway-controller/apis/gateway.tv2.dk/v1alpha1"
 	"github.com/tv2-oss/bifrost-gateway-controller/controllers"
@@ -67,6 +69,8 @@ var (
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 	utilruntime.Must(gateway.Install(scheme))
+	utilruntime.Must(gatewayv1a2.Install(scheme))
+	utilruntime.Must(gatewayv1b1.Install(scheme))
 	utilruntime.Must(gatewaytv2dkv1a1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
@@ -75,13 +79,20 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var enableValuesDebug bool
 	var syncPeriodArg string
+	var dryRun bool
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
 	flag.StringVar(&syncPeriodArg, "sync-period", "120s", "The period between non event-driven resynchronizations")
+	flag.BoolVar(&enableValuesDebug, "enable-values-debug", false,
+		"Serve merged values of Gateways and their sources at '/debug/values' of the metrics endpoint.")
+	flag.BoolVar(&dryRun, "dry-run", false,
+		"Apply child resources using server-side dry-run and do not update status, metadata or delete resources. "+
+			"Changes not done are logged, counted in metrics and recorded as Events.")
 	flag.StringVar(&controllers.ControllerNamespace, "controller-namespace", "bifrost-gateway-controller-system", "The namespace the controller will watch for global policies")
 	opts := zap.Options{
 		Development: true,
@@ -98,6 +109,13 @@ func main() {
 		os.Exit(1)
 	}
 
+	// A dry-run controller uses its own leader election such that it
+	// can run alongside the controller managing resources
+	leaderElectionID := "71264cc8.bifrost-gateway-controller.tv2.dk"
+	if dryRun {
+		leaderElectionID = "dry-run." + leaderElectionID
+	}
+
 	config := ctrl.GetConfigOrDie()
 	mgr, err := ctrl.NewManager(config, ctrl.Options{
 		Scheme: scheme,
@@ -110,7 +128,7 @@ func main() {
 			SyncPeriod: &syncPeriod,
 		},
 		LeaderElection:   enableLeaderElection,
-		LeaderElectionID: "71264cc8.bifrost-gateway-controller.tv2.dk",
+		LeaderElectionID: leaderElectionID,
 		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
 		// when the Manager ends. This requires the binary to immediately end when the
 		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
@@ -128,6 +146,11 @@ func main() {
 		os.Exit(1)
 	}
 
+	if dryRun {
+		setupLog.Info("running in dry-run mode, resources will not be changed")
+		controllers.EnableDryRun(mgr.GetEventRecorderFor("bifrost-gateway-controller-dry-run"))
+	}
+
 	gwctrl := controllers.NewGatewayController(mgr, config)
 	if err = gwctrl.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Gateway")
@@ -143,8 +166,50 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "HTTPRoute")
 		os.Exit(1)
 	}
+	grpcrtctrl := controllers.NewGRPCRouteController(mgr, config)
+	if err = grpcrtctrl.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "GRPCRoute")
+		os.Exit(1)
+	}
+	tlsrtctrl := controllers.NewTLSRouteController(mgr, config)
+	if err = tlsrtctrl.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "TLSRoute")
+		os.Exit(1)
+	}
+	tcprtctrl := controllers.NewTCPRouteController(mgr, config)
+	if err = tcprtctrl.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "TCPRoute")
+		os.Exit(1)
+	}
+	udprtctrl := controllers.NewUDPRouteController(mgr, config)
+	if err = udprtctrl.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "UDPRoute")
+		os.Exit(1)
+	}
+	gwcbctrl := controllers.NewGatewayClassBlueprintController(mgr)
+	if err = gwcbctrl.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "GatewayClassBlueprint")
+		os.Exit(1)
+	}
+	gwccctrl := controllers.NewGatewayClassConfigController(mgr)
+	if err = gwccctrl.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "GatewayClassConfig")
+		os.Exit(1)
+	}
+	gwconfctrl := controllers.NewGatewayConfigController(mgr)
+	if err = gwconfctrl.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "GatewayConfig")
+		os.Exit(1)
+	}
 	//+kubebuilder:scaffold:builder
 
+	if enableValuesDebug {
+		if err := mgr.AddMetricsServerExtraHandler("/debug/values", controllers.NewValuesDebugHandler(mgr)); err != nil {
+			setupLog.Error(err, "unable to set up values debug endpoint")
+			os.Exit(1)
+		}
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
@@ -39,3 +39,68 @@ import (
 const (
 	SelfControllerName gatewayapi.GatewayController = "github.com/tv2-oss/bifrost-gateway-controller"
 )
+
+// Labels and annotations used to track child resources created from templates
+const (
+	// Label set on child resources, the value is the UID of the parent resource (e.g. a Gateway)
+	ParentUIDLabel = "gateway.tv2.dk/parent-uid"
+
+	// Annotation set on child resources, the value identifies the parent resource as 'kind/namespace/name'
+	ParentAnnotation = "gateway.tv2.dk/parent"
+
+	// Annotation set on parent resources, the value is a JSON encoded list of child resources
+	InventoryAnnotation = "gateway.tv2.dk/inventory"
+
+	// Annotation set on Gateways, the value is a JSON encoded list of the blueprint and policies
+	// which supplied values, with their resource version
+	ValuesSourcesAnnotation = "gateway.tv2.dk/values-sources"
+
+	// Annotation set on child resources that are no longer rendered but kept due to prune policy 'Keep'
+	PrunedAnnotation = "gateway.tv2.dk/pruned"
+
+	// Finalizer set on parent resources to delete cluster-scoped child resources before the parent is deleted
+	ChildResourcesFinalizer = "gateway.tv2.dk/child-resources"
+)
+
+// Condition set on Gateways and route parent status when status
+// templates are defined in the GatewayClassBlueprint. Indicates whether
+// status templates could be rendered and decoded.
+const (
+	ConditionStatusRendered = "StatusRendered"
+
+	// Status templates rendered and decoded
+	ReasonStatusRendered = "Rendered"
+
+	// Status templates could not be rendered, e.g. because child
+	// resources are not yet available. Typically a temporary error
+	ReasonStatusRenderFailed = "RenderFailed"
+
+	// Rendered status is not valid, e.g. holds unknown fields or invalid conditions
+	ReasonInvalidStatus = "InvalidStatus"
+)
+
+// Conditions set on route parent status from the outcome of rendering
+// and applying route templates for the parent Gateway. Route
+// conditions defined by the Gateway API, e.g. 'Accepted', do not
+// reflect child resources.
+const (
+	// Indicates whether all child resources from route templates
+	// have been rendered and applied
+	RouteConditionProgrammed = "Programmed"
+
+	// Indicates whether all child resources are ready, i.e. have
+	// status 'Current' as computed by kstatus
+	RouteConditionReady = "Ready"
+
+	// All child resources rendered and applied, or ready
+	RouteReasonProgrammed = "Programmed"
+	RouteReasonReady      = "Ready"
+
+	// Some child resources not rendered, applied or ready. The
+	// condition message lists the templates concerned
+	RouteReasonPending = "Pending"
+
+	// Route not rendered since values are invalid according to the
+	// values schema of the GatewayClassBlueprint
+	RouteReasonInvalidValues = "InvalidValues"
+)
@@ -0,0 +1,303 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package blueprinttest runs golden-file tests of
+// GatewayClassBlueprints. Templates are rendered offline for the
+// Gateways and routes of each test case, and the rendered resources and
+// status are compared with expected output files.
+//
+// Each test case is a directory with the following subdirectories:
+//
+//	input/     Gateways, routes, policies, ConfigMaps and Secrets
+//	current/   Optional current child resources, e.g. with simulated status
+//	expected/  Expected output, one file with rendered resources and one with status per Gateway and route
+//
+// Expected output files are written instead of compared when tests are
+// run with '-update'.
+package blueprinttest
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	gatewayapi "sigs.k8s.io/gateway-api/apis/v1"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	"github.com/tv2-oss/bifrost-gateway-controller/controllers"
+	"github.com/tv2-oss/bifrost-gateway-controller/pkg/manifest"
+)
+
+var update = flag.Bool("update", false, "Write expected output files of blueprint tests instead of comparing with them")
+
+// Test case subdirectories
+const (
+	InputDir    = "input"
+	CurrentDir  = "current"
+	ExpectedDir = "expected"
+)
+
+// Options for blueprint tests
+type Options struct {
+	// Manifest files with GatewayClassBlueprints and
+	// GatewayClasses, used by all test cases
+	Blueprints []string
+
+	// Directory with a subdirectory per test case
+	TestCases string
+
+	// Kinds of cluster scoped child resources in addition to the
+	// kinds known by the offline renderer
+	ClusterScoped []schema.GroupKind
+
+	// Namespace of global policies. Defaults to the default
+	// namespace of the controller
+	ControllerNamespace string
+}
+
+const defaultControllerNamespace = "bifrost-gateway-controller-system"
+
+// Run a subtest per test case
+func Run(t *testing.T, opts Options) {
+	entries, err := os.ReadDir(opts.TestCases)
+	if err != nil {
+		t.Fatalf("cannot read test cases: %v", err)
+	}
+	defer func(ns string, w io.Writer) {
+		controllers.ControllerNamespace = ns
+		controllers.RenderDebugOutput = w
+	}(controllers.ControllerNamespace, controllers.RenderDebugOutput)
+	controllers.ControllerNamespace = defaultControllerNamespace
+	if opts.ControllerNamespace != "" {
+		controllers.ControllerNamespace = opts.ControllerNamespace
+	}
+	controllers.RenderDebugOutput = io.Discard
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(opts.TestCases, entry.Name())
+		t.Run(entry.Name(), func(t *testing.T) {
+			runTestCase(t, &opts, dir)
+		})
+	}
+}
+
+func runTestCase(t *testing.T, opts *Options, dir string) {
+	blueprints, err := manifest.ReadFiles(opts.Blueprints...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs, err := readDir(filepath.Join(dir, InputDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := readDir(filepath.Join(dir, CurrentDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	renderer, err := controllers.NewOfflineRenderer(append(blueprints, inputs...), current, opts.ClusterScoped)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outputs := map[string][]byte{}
+	for _, obj := range inputs {
+		if obj.GroupVersionKind().Group != gatewayapi.GroupName {
+			continue
+		}
+		kind := obj.GetKind()
+		if kind != "Gateway" && !strings.HasSuffix(kind, "Route") {
+			continue
+		}
+		namespace := obj.GetNamespace()
+		if namespace == "" {
+			namespace = "default"
+		}
+		var results []*controllers.OfflineRenderResult
+		if kind == "Gateway" {
+			var result *controllers.OfflineRenderResult
+			result, err = renderer.RenderGateway(context.Background(), namespace, obj.GetName())
+			if result != nil {
+				results = append(results, result)
+			}
+		} else {
+			results, err = renderer.RenderRoute(context.Background(), kind, namespace, obj.GetName())
+		}
+		name := fmt.Sprintf("%s-%s-%s", strings.ToLower(kind), namespace, obj.GetName())
+		resources, status, fmtErr := format(results, err)
+		if fmtErr != nil {
+			t.Fatal(fmtErr)
+		}
+		outputs[name+".yaml"] = resources
+		outputs[name+"-status.yaml"] = status
+	}
+
+	expectedDir := filepath.Join(dir, ExpectedDir)
+	if *update {
+		if err := writeExpected(expectedDir, outputs); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	compareExpected(t, expectedDir, outputs)
+}
+
+// Read all manifest files of a directory. A missing directory has no objects
+func readDir(dir string) ([]*unstructured.Unstructured, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return manifest.ReadFiles(files...)
+}
+
+// Format rendered resources and status. Errors are included as
+// comments, i.e. they are part of the expected output
+func format(results []*controllers.OfflineRenderResult, renderErr error) ([]byte, []byte, error) {
+	var resources, status bytes.Buffer
+	writeError(&resources, renderErr)
+	parentStatus := []any{}
+	for _, result := range results {
+		writeError(&resources, result.TemplateErrors)
+		for _, tmpl := range result.Templates {
+			for _, res := range tmpl.Resources {
+				if err := manifest.Write(&resources, fmt.Sprintf("Source: %s (gateway %s)", tmpl.TemplateName, result.Gateway), res); err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+		if result.GatewayStatus != nil {
+			data, err := sigsyaml.Marshal(result.GatewayStatus)
+			if err != nil {
+				return nil, nil, err
+			}
+			status.Write(data)
+		}
+		if result.RouteParentStatus != nil {
+			parentStatus = append(parentStatus, result.RouteParentStatus)
+		}
+	}
+	if len(parentStatus) > 0 {
+		data, err := sigsyaml.Marshal(map[string]any{"parents": parentStatus})
+		if err != nil {
+			return nil, nil, err
+		}
+		status.Write(data)
+	}
+	return resources.Bytes(), status.Bytes(), nil
+}
+
+func writeError(w io.Writer, err error) {
+	if err == nil {
+		return
+	}
+	for _, line := range strings.Split(err.Error(), "\n") {
+		fmt.Fprintf(w, "# Error: %s\n", line)
+	}
+}
+
+// Write expected output files and remove expected output files no longer produced
+func writeExpected(dir string, outputs map[string][]byte) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	existing, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return err
+	}
+	for _, file := range existing {
+		if _, found := outputs[filepath.Base(file)]; !found {
+			if err := os.Remove(file); err != nil {
+				return err
+			}
+		}
+	}
+	for name, data := range outputs {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func compareExpected(t *testing.T, dir string, outputs map[string][]byte) {
+	existing, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range existing {
+		if _, found := outputs[filepath.Base(file)]; !found {
+			t.Errorf("expected output %s not produced, run with -update to remove", file)
+		}
+	}
+	for name, data := range outputs {
+		file := filepath.Join(dir, name)
+		expected, err := os.ReadFile(file)
+		if err != nil {
+			t.Errorf("cannot read expected output, run with -update to create: %v", err)
+			continue
+		}
+		if !bytes.Equal(expected, data) {
+			t.Errorf("output differs from %s, run with -update to update:\n%s", file, diff(string(expected), string(data)))
+		}
+	}
+}
+
+// Lines which differ between expected and actual output
+func diff(expected, actual string) string {
+	expLines := strings.Split(expected, "\n")
+	actLines := strings.Split(actual, "\n")
+	var out strings.Builder
+	for idx := 0; idx < len(expLines) || idx < len(actLines); idx++ {
+		var exp, act string
+		if idx < len(expLines) {
+			exp = expLines[idx]
+		}
+		if idx < len(actLines) {
+			act = actLines[idx]
+		}
+		if exp != act {
+			fmt.Fprintf(&out, "line %d:\n- %s\n+ %s\n", idx+1, exp, act)
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2023 TV 2 DANMARK A/S
+
+Licensed under the Apache License, Version 2.0 (the "License") with the
+following modification to section 6. Trademarks:
+
+Section 6. Trademarks is deleted and replaced by the following wording:
+
+6. Trademarks. This License does not grant permission to use the trademarks and
+trade names of TV 2 DANMARK A/S, including but not limited to the TV 2® logo and
+word mark, except (a) as required for reasonable and customary use in describing
+the origin of the Work, e.g. as described in section 4(c) of the License, and
+(b) to reproduce the content of the NOTICE file. Any reference to the Licensor
+must be made by making a reference to "TV 2 DANMARK A/S", written in capitalized
+letters as in this example, unless the format in which the reference is made,
+requires lower case letters.
+
+You may not use this software except in compliance with the License and the
+modifications set out above.
+
+You may obtain a copy of the license at:
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package blueprints
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/tv2-oss/bifrost-gateway-controller/pkg/blueprinttest"
+)
+
+const blueprintsDir = "../../blueprints"
+
+// Golden-file tests of the blueprints in 'blueprints/'. Run with
+// '-update' to update expected output after changing a blueprint
+func TestBlueprints(t *testing.T) {
+	for _, name := range []string{"aws-alb-crossplane", "contour-istio"} {
+		dir := filepath.Join(blueprintsDir, name)
+		t.Run(name, func(t *testing.T) {
+			blueprinttest.Run(t, blueprinttest.Options{
+				Blueprints: []string{
+					filepath.Join(dir, "gatewayclass-"+name+".yaml"),
+					filepath.Join(dir, "gatewayclassblueprint-"+name+".yaml"),
+				},
+				TestCases: filepath.Join(dir, "tests"),
+			})
+		})
+	}
+}